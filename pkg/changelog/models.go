@@ -12,13 +12,37 @@ const (
 	CreatePackage Type = iota
 	DeletePackage
 	RestorePackage
+	RenamePackage
+	UpdateName
+	UpdateDescription
+	UpdateLicense
+	AddTag
+	RemoveTag
+	AddContributor
+	RemoveContributor
+	UpdateStatus
+	UpdateDataUseAgreement
+	UpdateAutomaticallyProcessPackages
 )
 
 const (
 	createPackageString  = "CREATE_PACKAGE"
 	deletePackageString  = "DELETE_PACKAGE"
 	restorePackageString = "RESTORE_PACKAGE"
-	unknownTypeString    = "UNKNOWN"
+	renamePackageString  = "RENAME_PACKAGE"
+
+	updateNameString                         = "UPDATE_NAME"
+	updateDescriptionString                  = "UPDATE_DESCRIPTION"
+	updateLicenseString                      = "UPDATE_LICENSE"
+	addTagString                             = "ADD_TAG"
+	removeTagString                          = "REMOVE_TAG"
+	addContributorString                     = "ADD_CONTRIBUTOR"
+	removeContributorString                  = "REMOVE_CONTRIBUTOR"
+	updateStatusString                       = "UPDATE_STATUS"
+	updateDataUseAgreementString             = "UPDATE_DATA_USE_AGREEMENT"
+	updateAutomaticallyProcessPackagesString = "UPDATE_AUTOMATICALLY_PROCESS_PACKAGES"
+
+	unknownTypeString = "UNKNOWN"
 )
 
 func (s Type) MarshalText() ([]byte, error) {
@@ -33,6 +57,28 @@ func (s *Type) UnmarshalText(text []byte) error {
 		*s = DeletePackage
 	case restorePackageString:
 		*s = RestorePackage
+	case renamePackageString:
+		*s = RenamePackage
+	case updateNameString:
+		*s = UpdateName
+	case updateDescriptionString:
+		*s = UpdateDescription
+	case updateLicenseString:
+		*s = UpdateLicense
+	case addTagString:
+		*s = AddTag
+	case removeTagString:
+		*s = RemoveTag
+	case addContributorString:
+		*s = AddContributor
+	case removeContributorString:
+		*s = RemoveContributor
+	case updateStatusString:
+		*s = UpdateStatus
+	case updateDataUseAgreementString:
+		*s = UpdateDataUseAgreement
+	case updateAutomaticallyProcessPackagesString:
+		*s = UpdateAutomaticallyProcessPackages
 	default:
 		return fmt.Errorf("unknown changelog type: %s", text)
 	}
@@ -47,6 +93,28 @@ func (s Type) String() string {
 		return deletePackageString
 	case RestorePackage:
 		return restorePackageString
+	case RenamePackage:
+		return renamePackageString
+	case UpdateName:
+		return updateNameString
+	case UpdateDescription:
+		return updateDescriptionString
+	case UpdateLicense:
+		return updateLicenseString
+	case AddTag:
+		return addTagString
+	case RemoveTag:
+		return removeTagString
+	case AddContributor:
+		return addContributorString
+	case RemoveContributor:
+		return removeContributorString
+	case UpdateStatus:
+		return updateStatusString
+	case UpdateDataUseAgreement:
+		return updateDataUseAgreementString
+	case UpdateAutomaticallyProcessPackages:
+		return updateAutomaticallyProcessPackagesString
 	}
 
 	return unknownTypeString
@@ -74,6 +142,13 @@ type PackageCreateEvent struct {
 	Parent *ParentPackage `json:"parent"`
 }
 
+type PackageDeleteEvent struct {
+	Id     int64          `json:"id"`
+	Name   string         `json:"name"`
+	NodeId string         `json:"nodeId"`
+	Parent *ParentPackage `json:"parent,omitempty"`
+}
+
 type PackageRestoreEvent struct {
 	Id           int64          `json:"id"`
 	Name         string         `json:"name,omitempty"`
@@ -82,6 +157,54 @@ type PackageRestoreEvent struct {
 	Parent       *ParentPackage `json:"parent,omitempty"`
 }
 
+type PackageRenameEvent struct {
+	Id      int64          `json:"id"`
+	OldName string         `json:"oldName"`
+	NewName string         `json:"newName"`
+	NodeId  string         `json:"nodeId"`
+	Parent  *ParentPackage `json:"parent,omitempty"`
+}
+
+type DatasetUpdateNameEvent struct {
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+}
+
+type DatasetUpdateDescriptionEvent struct {
+	OldDescription string `json:"oldDescription"`
+	NewDescription string `json:"newDescription"`
+}
+
+type DatasetUpdateLicenseEvent struct {
+	OldLicense string `json:"oldLicense"`
+	NewLicense string `json:"newLicense"`
+}
+
+// DatasetTagEvent is the detail of AddTag and RemoveTag events.
+type DatasetTagEvent struct {
+	Name string `json:"name"`
+}
+
+// DatasetContributorEvent is the detail of AddContributor and RemoveContributor events.
+type DatasetContributorEvent struct {
+	Name string `json:"name"`
+}
+
+type DatasetUpdateStatusEvent struct {
+	OldStatusId int32 `json:"oldStatusId"`
+	NewStatusId int32 `json:"newStatusId"`
+}
+
+type DatasetUpdateDataUseAgreementEvent struct {
+	OldDataUseAgreementId *int32 `json:"oldDataUseAgreementId"`
+	NewDataUseAgreementId *int32 `json:"newDataUseAgreementId"`
+}
+
+type DatasetUpdateAutomaticallyProcessPackagesEvent struct {
+	OldValue bool `json:"oldValue"`
+	NewValue bool `json:"newValue"`
+}
+
 type Event struct {
 	EventType   Type        `json:"eventType"`
 	EventDetail interface{} `json:"eventDetail"`
@@ -18,6 +18,11 @@ func TestType_MarshalText(t *testing.T) {
 		{"CreatePackage", CreatePackage, "CREATE_PACKAGE"},
 		{"DeletePackage", DeletePackage, "DELETE_PACKAGE"},
 		{"RestorePackage", RestorePackage, "RESTORE_PACKAGE"},
+		{"RenamePackage", RenamePackage, "RENAME_PACKAGE"},
+		{"UpdateName", UpdateName, "UPDATE_NAME"},
+		{"AddTag", AddTag, "ADD_TAG"},
+		{"RemoveContributor", RemoveContributor, "REMOVE_CONTRIBUTOR"},
+		{"UpdateDataUseAgreement", UpdateDataUseAgreement, "UPDATE_DATA_USE_AGREEMENT"},
 	}
 
 	for _, tt := range tests {
@@ -38,6 +43,10 @@ func TestType_UnmarshalText(t *testing.T) {
 		{"CREATE_PACKAGE", "CREATE_PACKAGE", CreatePackage},
 		{"DELETE_PACKAGE", "DELETE_PACKAGE", DeletePackage},
 		{"RESTORE_PACKAGE", "RESTORE_PACKAGE", RestorePackage},
+		{"RENAME_PACKAGE", "RENAME_PACKAGE", RenamePackage},
+		{"UPDATE_STATUS", "UPDATE_STATUS", UpdateStatus},
+		{"REMOVE_TAG", "REMOVE_TAG", RemoveTag},
+		{"UPDATE_AUTOMATICALLY_PROCESS_PACKAGES", "UPDATE_AUTOMATICALLY_PROCESS_PACKAGES", UpdateAutomaticallyProcessPackages},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,184 @@
+// Package dbErrors defines the kinds of errors returned by the pgdb and dydb queries.
+//
+// Every error returned by a query matches at most one of the sentinel errors below with errors.Is.
+// The original error, such as sql.ErrNoRows or a *pq.Error, is kept and can still be matched with
+// errors.Is and errors.As, through Unwrap.
+//
+// This is a breaking change for callers that compare errors directly: queries no longer return
+// sql.ErrNoRows itself, so err == sql.ErrNoRows is false. Use errors.Is(err, ErrNotFound).
+package dbErrors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	"github.com/lib/pq"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is returned when the requested row or item does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a change conflicts with the current state, such as a duplicate key.
+	ErrConflict = errors.New("conflict")
+	// ErrPermissionDenied is returned when the database denies access.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalid is returned when the request or the data is invalid.
+	ErrInvalid = errors.New("invalid")
+	// ErrTransient is returned when the request failed but may succeed if it is retried.
+	ErrTransient = errors.New("transient")
+)
+
+var kinds = []error{ErrNotFound, ErrConflict, ErrPermissionDenied, ErrInvalid, ErrTransient}
+
+// Error is an error of a Kind with its cause.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%v (error: %s)", e.Kind, e.Message)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%v (error: %v)", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%v (error: %s: %v)", e.Kind, e.Message, e.Err)
+}
+
+// Is returns true if target is the Kind of the error. It does not match the cause, such as sql.ErrNoRows;
+// errors.Is matches the cause through Unwrap.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of kind with a message.
+func New(kind error, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns err as an error of kind. It returns nil if err is nil.
+func Wrap(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf returns the sentinel error that err matches, or nil if err does not match any.
+func KindOf(err error) error {
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// StatusCode returns the HTTP status code for err, based on its kind.
+func StatusCode(err error) int {
+	switch KindOf(err) {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrConflict:
+		return http.StatusConflict
+	case ErrPermissionDenied:
+		return http.StatusForbidden
+	case ErrInvalid:
+		return http.StatusBadRequest
+	case ErrTransient:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FromPostgres returns err as an error of the matching kind. Errors that already have a kind, and errors
+// that cannot be mapped, are returned unchanged.
+//   - sql.ErrNoRows is ErrNotFound.
+//   - *pq.Error is mapped by its SQLSTATE code, see postgresKind.
+//   - Closed connections and deadlines are ErrTransient.
+func FromPostgres(err error) error {
+	if err == nil || KindOf(err) != nil {
+		return err
+	}
+
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Wrap(ErrNotFound, err)
+	case errors.As(err, &pqErr):
+		if kind := postgresKind(pqErr.Code); kind != nil {
+			return Wrap(kind, err)
+		}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.Is(err, context.DeadlineExceeded):
+		return Wrap(ErrTransient, err)
+	}
+	return err
+}
+
+// postgresKind returns the kind of a PostgreSQL error code, or nil if the code has no kind.
+// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+func postgresKind(code pq.ErrorCode) error {
+	switch code {
+	case "23505", "23P01": // unique_violation, exclusion_violation
+		return ErrConflict
+	case "23502", "23503", "23514": // not_null_violation, foreign_key_violation, check_violation
+		return ErrInvalid
+	case "40001", "40P01", "55P03", "57014": // serialization_failure, deadlock_detected, lock_not_available, query_canceled
+		return ErrTransient
+	case "42501": // insufficient_privilege
+		return ErrPermissionDenied
+	}
+
+	switch code.Class() {
+	case "08", "53", "57": // connection_exception, insufficient_resources, operator_intervention
+		return ErrTransient
+	case "22": // data_exception
+		return ErrInvalid
+	case "28": // invalid_authorization_specification
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// FromDynamoDB returns err as an error of the matching kind. Errors that already have a kind, and errors
+// that cannot be mapped, are returned unchanged. DynamoDB exceptions are mapped by their error code.
+func FromDynamoDB(err error) error {
+	if err == nil || KindOf(err) != nil {
+		return err
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return Wrap(ErrTransient, err)
+		}
+		return err
+	}
+
+	switch apiErr.ErrorCode() {
+	case "ResourceNotFoundException":
+		return Wrap(ErrNotFound, err)
+	case "ConditionalCheckFailedException", "TransactionConflictException", "ResourceInUseException",
+		"IdempotentParameterMismatchException":
+		return Wrap(ErrConflict, err)
+	case "AccessDeniedException", "UnrecognizedClientException":
+		return Wrap(ErrPermissionDenied, err)
+	case "ValidationException", "ItemCollectionSizeLimitExceededException":
+		return Wrap(ErrInvalid, err)
+	case "ProvisionedThroughputExceededException", "RequestLimitExceeded", "ThrottlingException",
+		"InternalServerError", "TransactionInProgressException", "LimitExceededException":
+		return Wrap(ErrTransient, err)
+	}
+	return err
+}
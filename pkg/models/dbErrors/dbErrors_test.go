@@ -0,0 +1,120 @@
+package dbErrors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/aws/smithy-go"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type typedError struct{}
+
+func (typedError) Error() string { return "typed" }
+
+func (typedError) Is(target error) bool { return target == ErrConflict }
+
+func TestError(t *testing.T) {
+	err := New(ErrNotFound, "dataset 1 not found")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "not found (error: dataset 1 not found)", err.Error())
+
+	wrapped := fmt.Errorf("get dataset: %w", err)
+	assert.ErrorIs(t, wrapped, ErrNotFound)
+
+	var dbErr *Error
+	assert.ErrorAs(t, wrapped, &dbErr)
+	assert.Equal(t, ErrNotFound, dbErr.Kind)
+
+	assert.Nil(t, Wrap(ErrInvalid, nil))
+
+	cause := errors.New("boom")
+	err = Wrap(ErrTransient, cause)
+	assert.ErrorIs(t, err, ErrTransient)
+	assert.ErrorIs(t, err, cause)
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Nil(t, KindOf(nil))
+	assert.Nil(t, KindOf(errors.New("boom")))
+	assert.Equal(t, ErrInvalid, KindOf(New(ErrInvalid, "bad")))
+	assert.Equal(t, ErrConflict, KindOf(typedError{}))
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := map[error]int{
+		New(ErrNotFound, ""):         http.StatusNotFound,
+		New(ErrConflict, ""):         http.StatusConflict,
+		New(ErrPermissionDenied, ""): http.StatusForbidden,
+		New(ErrInvalid, ""):          http.StatusBadRequest,
+		New(ErrTransient, ""):        http.StatusServiceUnavailable,
+		errors.New("boom"):           http.StatusInternalServerError,
+	}
+	for err, code := range tests {
+		assert.Equal(t, code, StatusCode(err), err.Error())
+	}
+}
+
+func TestFromPostgres(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"wrapped no rows", fmt.Errorf("get: %w", sql.ErrNoRows), ErrNotFound},
+		{"unique violation", &pq.Error{Code: "23505"}, ErrConflict},
+		{"foreign key violation", &pq.Error{Code: "23503"}, ErrInvalid},
+		{"invalid text representation", &pq.Error{Code: "22P02"}, ErrInvalid},
+		{"serialization failure", &pq.Error{Code: "40001"}, ErrTransient},
+		{"connection failure", &pq.Error{Code: "08006"}, ErrTransient},
+		{"insufficient privilege", &pq.Error{Code: "42501"}, ErrPermissionDenied},
+		{"undefined table", &pq.Error{Code: "42P01"}, nil},
+		{"bad connection", driver.ErrBadConn, ErrTransient},
+		{"deadline", context.DeadlineExceeded, ErrTransient},
+		{"typed", typedError{}, ErrConflict},
+		{"unknown", errors.New("boom"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromPostgres(tt.err)
+			assert.Equal(t, tt.kind, KindOf(err))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	assert.Nil(t, FromPostgres(nil))
+	assert.NotEqual(t, sql.ErrNoRows, FromPostgres(sql.ErrNoRows), "sql.ErrNoRows is wrapped, not returned as is")
+
+	var pqErr *pq.Error
+	assert.ErrorAs(t, FromPostgres(&pq.Error{Code: "23505"}), &pqErr)
+	assert.Equal(t, pq.ErrorCode("23505"), pqErr.Code)
+}
+
+func TestFromDynamoDB(t *testing.T) {
+	tests := map[string]error{
+		"ResourceNotFoundException":              ErrNotFound,
+		"ConditionalCheckFailedException":        ErrConflict,
+		"TransactionConflictException":           ErrConflict,
+		"AccessDeniedException":                  ErrPermissionDenied,
+		"ValidationException":                    ErrInvalid,
+		"ProvisionedThroughputExceededException": ErrTransient,
+		"ThrottlingException":                    ErrTransient,
+		"SomethingElseException":                 nil,
+	}
+	for code, kind := range tests {
+		apiErr := &smithy.GenericAPIError{Code: code}
+		err := FromDynamoDB(fmt.Errorf("operation error: %w", apiErr))
+		assert.Equal(t, kind, KindOf(err), code)
+		assert.ErrorIs(t, err, apiErr)
+	}
+
+	assert.Nil(t, FromDynamoDB(nil))
+	assert.Nil(t, KindOf(FromDynamoDB(errors.New("boom"))))
+}
@@ -0,0 +1,38 @@
+package processingState
+
+import "sort"
+
+// transitions lists the states a file can move to from each processing state.
+// Processed and not processable files can be reset to Unprocessed to run processing again.
+var transitions = map[ProcessingState][]ProcessingState{
+	Unprocessed:    {Processed, NotProcessable},
+	Processed:      {Unprocessed},
+	NotProcessable: {Unprocessed},
+}
+
+// CanTransitionTo returns true if a file can move from processing state u to the target state.
+func (u ProcessingState) CanTransitionTo(target ProcessingState) bool {
+	for _, next := range transitions[u] {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal returns true if processing of the file has completed, successfully or not.
+func (u ProcessingState) IsFinal() bool {
+	return u == Processed || u == NotProcessable
+}
+
+// PreviousStates returns the processing states a file can move to the target state from, in state order.
+func PreviousStates(target ProcessingState) []ProcessingState {
+	var previous []ProcessingState
+	for from := range transitions {
+		if from.CanTransitionTo(target) {
+			previous = append(previous, from)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i] < previous[j] })
+	return previous
+}
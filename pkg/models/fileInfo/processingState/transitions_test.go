@@ -0,0 +1,20 @@
+package processingState
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	assert.True(t, Unprocessed.CanTransitionTo(Processed))
+	assert.True(t, Unprocessed.CanTransitionTo(NotProcessable))
+	assert.True(t, Processed.CanTransitionTo(Unprocessed))
+	assert.False(t, Processed.CanTransitionTo(NotProcessable))
+	assert.False(t, Unprocessed.CanTransitionTo(Unprocessed))
+	assert.False(t, ProcessingState(42).CanTransitionTo(Processed))
+}
+
+func TestPreviousStates(t *testing.T) {
+	assert.Equal(t, []ProcessingState{Processed, NotProcessable}, PreviousStates(Unprocessed))
+	assert.Equal(t, []ProcessingState{Unprocessed}, PreviousStates(Processed))
+}
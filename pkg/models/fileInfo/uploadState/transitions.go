@@ -0,0 +1,37 @@
+package uploadState
+
+import "sort"
+
+// transitions lists the states a file can move to from each upload state.
+// Uploaded is final: an uploaded file is never scanned or uploaded again.
+var transitions = map[UploadedState][]UploadedState{
+	Pending:  {Scanning, Uploaded},
+	Scanning: {Uploaded},
+}
+
+// CanTransitionTo returns true if a file can move from upload state u to the target state.
+func (u UploadedState) CanTransitionTo(target UploadedState) bool {
+	for _, next := range transitions[u] {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal returns true if the upload of the file has completed.
+func (u UploadedState) IsFinal() bool {
+	return u == Uploaded
+}
+
+// PreviousStates returns the upload states a file can move to the target state from, in state order.
+func PreviousStates(target UploadedState) []UploadedState {
+	var previous []UploadedState
+	for from := range transitions {
+		if from.CanTransitionTo(target) {
+			previous = append(previous, from)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool { return previous[i] < previous[j] })
+	return previous
+}
@@ -0,0 +1,18 @@
+package uploadState
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	assert.True(t, Pending.CanTransitionTo(Scanning))
+	assert.True(t, Scanning.CanTransitionTo(Uploaded))
+	assert.False(t, Uploaded.CanTransitionTo(Pending))
+	assert.False(t, Scanning.CanTransitionTo(Pending))
+}
+
+func TestPreviousStates(t *testing.T) {
+	assert.Equal(t, []UploadedState{Scanning, Pending}, PreviousStates(Uploaded))
+	assert.Empty(t, PreviousStates(Pending))
+}
@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"encoding/json"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"log"
 )
 
@@ -24,3 +25,10 @@ func CreateErrorMessage(message string, code int) string {
 	return string(jsonBody)
 
 }
+
+// CreateErrorMessageFromError parses a query error into body for GatewayResponse, and returns the body with
+// the HTTP status code for the kind of the error, see dbErrors.StatusCode.
+func CreateErrorMessageFromError(err error) (string, int) {
+	code := dbErrors.StatusCode(err)
+	return CreateErrorMessage(err.Error(), code), code
+}
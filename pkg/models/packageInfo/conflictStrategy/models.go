@@ -16,4 +16,8 @@ const (
 	// replaced_by_package_id. Folders (Collection type) cannot be replaced
 	// — the DB CHECK constraint enforces this.
 	Replace Strategy = "REPLACE"
-)
\ No newline at end of file
+
+	// Fail returns an error that matches dbErrors.ErrConflict instead of
+	// resolving the collision, and changes nothing.
+	Fail Strategy = "FAIL"
+)
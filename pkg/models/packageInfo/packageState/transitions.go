@@ -0,0 +1,37 @@
+package packageState
+
+// transitions lists the states a package can move to from each state.
+//   - Unavailable packages are waiting for their upload to complete.
+//   - Infected packages can only be deleted.
+//   - Deleted packages can only come back through Restoring, or directly to Ready
+//     when a restore does not require any work on the files.
+var transitions = map[State][]State{
+	Unavailable:      {Uploaded, UploadFailed, Ready, Deleting, Deleted},
+	Uploaded:         {Processing, Ready, Infected, ProcessingFailed, Deleting, Deleted},
+	UploadFailed:     {Unavailable, Uploaded, Deleting, Deleted},
+	Processing:       {Ready, ProcessingFailed, Infected, Deleting, Deleted},
+	ProcessingFailed: {Processing, Deleting, Deleted},
+	Ready:            {Processing, Deleting, Deleted},
+	Infected:         {Deleting, Deleted},
+	Deleting:         {Deleted},
+	Deleted:          {Restoring, Ready},
+	Restoring:        {Ready, Deleted},
+}
+
+// CanTransitionTo returns true if a package can move from state s to the target state.
+// Moving a package to the state it is already in is not a transition.
+func (s State) CanTransitionTo(target State) bool {
+	for _, next := range transitions[s] {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStates returns the states a package can move to from state s.
+func (s State) NextStates() []State {
+	next := make([]State, len(transitions[s]))
+	copy(next, transitions[s])
+	return next
+}
@@ -0,0 +1,39 @@
+package packageState
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from     State
+		to       State
+		expected bool
+	}{
+		{Unavailable, Uploaded, true},
+		{Uploaded, Processing, true},
+		{Processing, Ready, true},
+		{Processing, ProcessingFailed, true},
+		{ProcessingFailed, Processing, true},
+		{Ready, Deleted, true},
+		{Deleted, Restoring, true},
+		{Restoring, Ready, true},
+		{Deleted, Processing, false},
+		{Infected, Ready, false},
+		{Deleting, Ready, false},
+		{Ready, Ready, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from.String()+"->"+tt.to.String(), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.from.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestEveryStateHasTransitions(t *testing.T) {
+	for s := Unavailable; s <= Restoring; s++ {
+		assert.NotEmpty(t, s.NextStates(), s.String())
+	}
+}
@@ -0,0 +1,107 @@
+package pgdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"strings"
+	"time"
+)
+
+// ChecksumAlgorithm is the hash algorithm used to verify a file.
+type ChecksumAlgorithm string
+
+const (
+	MD5    ChecksumAlgorithm = "md5"
+	SHA256 ChecksumAlgorithm = "sha256"
+)
+
+// FileChecksum is the JSON document stored in the checksum column of the files table.
+type FileChecksum struct {
+	MD5          string                `json:"checksum"`
+	ChunkSize    ChunkSize             `json:"chunkSize"`
+	SHA256       string                `json:"sha256"`
+	Verification *ChecksumVerification `json:"verification,omitempty"`
+}
+
+// ChunkSize is the chunk size of a multipart checksum. It is written as a JSON string,
+// and also read from the JSON numbers written by other producers.
+type ChunkSize string
+
+func (s *ChunkSize) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = ChunkSize(n)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = ChunkSize(str)
+	return nil
+}
+
+// ChecksumVerification is the result of the last time the checksum of a file was verified.
+type ChecksumVerification struct {
+	Algorithm  ChecksumAlgorithm `json:"algorithm"`
+	Expected   string            `json:"expected"`
+	Actual     string            `json:"actual"`
+	Verified   bool              `json:"verified"`
+	VerifiedAt time.Time         `json:"verifiedAt"`
+}
+
+// ParseFileChecksum parses the value of the checksum column.
+// Values that are not a JSON object are treated as a bare MD5 checksum.
+func ParseFileChecksum(value string) (*FileChecksum, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return &FileChecksum{}, nil
+	}
+	if !strings.HasPrefix(value, "{") {
+		return &FileChecksum{MD5: value}, nil
+	}
+
+	var c FileChecksum
+	if err := json.Unmarshal([]byte(value), &c); err != nil {
+		return nil, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("invalid file checksum: %w", err))
+	}
+	return &c, nil
+}
+
+// String returns the value to store in the checksum column.
+func (c FileChecksum) String() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// A struct of strings, bools and times always marshals
+		return ""
+	}
+	return string(b)
+}
+
+// Expected returns the recorded checksum for an algorithm.
+func (c FileChecksum) Expected(algorithm ChecksumAlgorithm) (string, error) {
+	switch algorithm {
+	case MD5:
+		return c.MD5, nil
+	case SHA256:
+		return c.SHA256, nil
+	}
+	return "", dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown checksum algorithm: %s", algorithm))
+}
+
+// Verify compares an actual checksum with the recorded checksum for an algorithm and
+// returns the verification result. A file without a recorded checksum fails verification.
+func (c FileChecksum) Verify(algorithm ChecksumAlgorithm, actual string, verifiedAt time.Time) (*ChecksumVerification, error) {
+	expected, err := c.Expected(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksumVerification{
+		Algorithm:  algorithm,
+		Expected:   expected,
+		Actual:     actual,
+		Verified:   expected != "" && strings.EqualFold(expected, actual),
+		VerifiedAt: verifiedAt,
+	}, nil
+}
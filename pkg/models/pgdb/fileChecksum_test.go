@@ -0,0 +1,59 @@
+package pgdb
+
+import (
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseFileChecksum(t *testing.T) {
+	c, err := ParseFileChecksum(`{"checksum": "abc", "chunkSize": "32", "sha256": "def"}`)
+	require.NoError(t, err)
+	assert.Equal(t, FileChecksum{MD5: "abc", ChunkSize: "32", SHA256: "def"}, *c)
+
+	c, err = ParseFileChecksum(`{"checksum": "abc", "chunkSize": 32, "sha256": "def"}`)
+	require.NoError(t, err)
+	assert.Equal(t, FileChecksum{MD5: "abc", ChunkSize: "32", SHA256: "def"}, *c)
+
+	c, err = ParseFileChecksum("abc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", c.MD5)
+
+	c, err = ParseFileChecksum("")
+	require.NoError(t, err)
+	assert.Equal(t, FileChecksum{}, *c)
+
+	_, err = ParseFileChecksum("{not json")
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+}
+
+func TestFileChecksum_String(t *testing.T) {
+	original := FileChecksum{MD5: `a"b`, ChunkSize: "32", SHA256: "def"}
+	parsed, err := ParseFileChecksum(original.String())
+	require.NoError(t, err)
+	assert.Equal(t, original, *parsed)
+}
+
+func TestFileChecksum_Verify(t *testing.T) {
+	c := FileChecksum{MD5: "ABC", SHA256: ""}
+	now := time.Now()
+
+	v, err := c.Verify(MD5, "abc", now)
+	require.NoError(t, err)
+	assert.True(t, v.Verified)
+	assert.Equal(t, "ABC", v.Expected)
+	assert.Equal(t, now, v.VerifiedAt)
+
+	v, err = c.Verify(MD5, "xyz", now)
+	require.NoError(t, err)
+	assert.False(t, v.Verified)
+
+	v, err = c.Verify(SHA256, "", now)
+	require.NoError(t, err)
+	assert.False(t, v.Verified, "missing checksum should fail verification")
+
+	_, err = c.Verify("crc32", "abc", now)
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+}
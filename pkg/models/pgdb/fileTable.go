@@ -2,6 +2,7 @@ package pgdb
 
 import (
 	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/objectType"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/processingState"
@@ -52,6 +53,11 @@ func (m *ErrFileNotFound) Error() string {
 	return "File does not exist in postgres"
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (m *ErrFileNotFound) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 type ErrMultipleRowsAffected struct{}
 
 func (m *ErrMultipleRowsAffected) Error() string {
@@ -63,3 +69,8 @@ type ErrFileAlreadyPublished struct{}
 func (m *ErrFileAlreadyPublished) Error() string {
 	return "File is already published, skipping bucket update"
 }
+
+// Is returns true for dbErrors.ErrConflict.
+func (m *ErrFileAlreadyPublished) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
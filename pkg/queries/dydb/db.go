@@ -3,6 +3,7 @@ package dydb
 import (
 	"context"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 )
 
 // From https://dev.to/techschoolguru/a-clean-way-to-implement-database-transaction-in-golang-2ba
@@ -28,3 +29,8 @@ func New(db DB) *Queries {
 type Queries struct {
 	db DB
 }
+
+// mapError returns err as one of the dbErrors kinds, see dbErrors.FromDynamoDB.
+func mapError(err error) error {
+	return dbErrors.FromDynamoDB(err)
+}
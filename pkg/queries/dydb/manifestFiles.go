@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/manifest"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/manifest/manifestFile"
@@ -76,7 +77,7 @@ func (q *Queries) UpdateFileTableStatus(ctx context.Context, tableName string, m
 		})
 	}
 
-	return err
+	return mapError(err)
 }
 
 // GetFilesForPath returns files in path for an upload with optional filter.
@@ -97,7 +98,7 @@ func (q *Queries) GetFilesForPath(ctx context.Context, tableName string, manifes
 
 	result, err := q.db.Query(ctx, &queryInput)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return result, nil
@@ -116,16 +117,16 @@ func (q *Queries) GetManifestFile(ctx context.Context, tableName string, manifes
 	})
 
 	if err != nil {
-		return &item, fmt.Errorf("GetItem: %v\n", err)
+		return &item, mapError(fmt.Errorf("GetItem: %w", err))
 	}
 
 	if data.Item == nil {
-		return &item, fmt.Errorf("GetItem: ManifestFile not found.\n")
+		return &item, dbErrors.New(dbErrors.ErrNotFound, "GetItem: ManifestFile not found.")
 	}
 
 	err = attributevalue.UnmarshalMap(data.Item, &item)
 	if err != nil {
-		return &item, fmt.Errorf("UnmarshalMap: %v\n", err)
+		return &item, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("UnmarshalMap: %w", err))
 	}
 
 	return &item, nil
@@ -185,7 +186,7 @@ func (q *Queries) GetFilesPaginated(ctx context.Context, tableName string, manif
 
 	result, err := q.db.Query(ctx, &queryInput)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, mapError(err)
 	}
 
 	var items []dydb.ManifestFileTable
@@ -193,7 +194,7 @@ func (q *Queries) GetFilesPaginated(ctx context.Context, tableName string, manif
 		mFile := dydb.ManifestFileTable{}
 		err = attributevalue.UnmarshalMap(item, &mFile)
 		if err != nil {
-			return nil, nil, fmt.Errorf("UnmarshalMap: %v\n", err)
+			return nil, nil, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("UnmarshalMap: %w", err))
 		}
 		items = append(items, mFile)
 	}
@@ -215,7 +216,7 @@ func (q *Queries) SyncFiles(manifestId string, items []manifestFile.FileDTO, for
 				"manifest_id": manifestId,
 			},
 		).Error("Manifest does not exist.")
-		return nil, errors.New(fmt.Sprintf("manifest with id: %s does not exist. original error: %v", manifestId, err))
+		return nil, fmt.Errorf("manifest with id: %s does not exist. original error: %w", manifestId, err)
 	}
 
 	// Populate DynamoDB with concurrent workers.
@@ -285,14 +286,14 @@ func (q *Queries) statusForFileItem(ctx context.Context, tableName string, manif
 
 	result, err := q.db.GetItem(ctx, getItemInput)
 	if err != nil {
-		return -1, fmt.Errorf("error getting manifest file status from dydb: %w", err)
+		return -1, mapError(fmt.Errorf("error getting manifest file status from dydb: %w", err))
 	}
 
 	var pItem dydb.ManifestFileTable
 	if len(result.Item) > 0 {
 		err = attributevalue.UnmarshalMap(result.Item, &pItem)
 		if err != nil {
-			return -1, fmt.Errorf("error unmarshalling manifest file item from dydb: %w", err)
+			return -1, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("error unmarshalling manifest file item from dydb: %w", err))
 		}
 
 		var m manifestFile.Status
@@ -455,7 +456,7 @@ func (q *Queries) syncUpdate(ctx context.Context, fileTableName string, manifest
 					"manifest_id": manifestId,
 				},
 			).Error("Unable to Batch Write: ", err)
-			return nil, fmt.Errorf("error batch writing manifest file items: %w", err)
+			return nil, mapError(fmt.Errorf("error batch writing manifest file items: %w", err))
 		}
 
 		nrFilesUpdated += len(writeRequests) - len(data.UnprocessedItems)
@@ -480,7 +481,7 @@ func (q *Queries) syncUpdate(ctx context.Context, fileTableName string, manifest
 						"manifest_id": manifestId,
 					},
 				).Error("Unable to Batch Write: ", err)
-				return nil, fmt.Errorf("error batch writing unprocessed manifest file items: %w", err)
+				return nil, mapError(fmt.Errorf("error batch writing unprocessed manifest file items: %w", err))
 			}
 
 			nrFilesUpdated += len(unProcessedItems) - len(data.UnprocessedItems)
@@ -505,7 +506,7 @@ func (q *Queries) syncUpdate(ctx context.Context, fileTableName string, manifest
 				err = attributevalue.UnmarshalMap(item, &fileEntry)
 				if err != nil {
 					log.Error("Unable to UnMarshall unprocessed items. ", err)
-					return nil, err
+					return nil, mapError(err)
 				}
 				failedFiles = append(failedFiles, fileEntry.UploadId)
 			}
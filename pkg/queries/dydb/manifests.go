@@ -3,12 +3,12 @@ package dydb
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dydb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/manifest"
 	log "github.com/sirupsen/logrus"
@@ -27,7 +27,7 @@ func (q *Queries) CreateManifest(ctx context.Context, manifestTableName string,
 				"user_id":         item.UserId,
 			},
 		).Error(fmt.Sprintf("MarshalMap: %v\n", err))
-		return fmt.Errorf("MarshalMap: %v\n", err)
+		return dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("MarshalMap: %w", err))
 	}
 
 	getRequest := dynamodb.GetItemInput{
@@ -36,7 +36,7 @@ func (q *Queries) CreateManifest(ctx context.Context, manifestTableName string,
 	}
 	result, _ := q.db.GetItem(ctx, &getRequest)
 	if result != nil {
-		return errors.New("manifest with provided ID already exists")
+		return dbErrors.New(dbErrors.ErrConflict, "manifest with provided ID already exists")
 	}
 
 	_, err = q.db.PutItem(ctx, &dynamodb.PutItemInput{
@@ -53,7 +53,7 @@ func (q *Queries) CreateManifest(ctx context.Context, manifestTableName string,
 				"user_id":         item.UserId,
 			},
 		).Error(fmt.Sprintf("Error creating upload: %v\n", err))
-		return errors.New("error creating Manifest")
+		return mapError(fmt.Errorf("error creating Manifest: %w", err))
 	}
 
 	return nil
@@ -72,16 +72,16 @@ func (q *Queries) GetManifestById(ctx context.Context, manifestTableName string,
 	})
 
 	if err != nil {
-		return &item, fmt.Errorf("GetItem: %v\n", err)
+		return &item, mapError(fmt.Errorf("GetItem: %w", err))
 	}
 
 	if data.Item == nil {
-		return &item, fmt.Errorf("GetItem: Manifest not found.\n")
+		return &item, dbErrors.New(dbErrors.ErrNotFound, "GetItem: Manifest not found.")
 	}
 
 	err = attributevalue.UnmarshalMap(data.Item, &item)
 	if err != nil {
-		return &item, fmt.Errorf("UnmarshalMap: %v\n", err)
+		return &item, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("UnmarshalMap: %w", err))
 	}
 
 	return &item, nil
@@ -103,7 +103,7 @@ func (q *Queries) GetManifestsForDataset(ctx context.Context, manifestTableName
 
 	result, err := q.db.Query(ctx, &queryInput)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	var items []dydb.ManifestTable
@@ -111,7 +111,7 @@ func (q *Queries) GetManifestsForDataset(ctx context.Context, manifestTableName
 		m := dydb.ManifestTable{}
 		err = attributevalue.UnmarshalMap(item, &m)
 		if err != nil {
-			return nil, fmt.Errorf("UnmarshalMap: %v\n", err)
+			return nil, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("UnmarshalMap: %w", err))
 		}
 		items = append(items, m)
 	}
@@ -135,20 +135,20 @@ func (q *Queries) CheckUpdateManifestStatus(ctx context.Context, manifestFileTab
 	remaining, _, err := q.GetFilesPaginated(ctx, manifestFileTableName,
 		manifestId, reqStatus, 1, nil)
 	if err != nil {
-		return setStatus, err
+		return setStatus, mapError(err)
 	}
 
 	if len(remaining) == 0 {
 		setStatus = manifest.Completed
 		err = q.UpdateManifestStatus(ctx, manifestTableName, manifestId, setStatus)
 		if err != nil {
-			return setStatus, err
+			return setStatus, mapError(err)
 		}
 	} else if currentStatus == "Completed" {
 		setStatus = manifest.Uploading
 		err = q.UpdateManifestStatus(ctx, manifestTableName, manifestId, setStatus)
 		if err != nil {
-			return setStatus, err
+			return setStatus, mapError(err)
 		}
 	}
 
@@ -172,5 +172,5 @@ func (q *Queries) UpdateManifestStatus(ctx context.Context, tableName string, ma
 			":statusValue": &types.AttributeValueMemberS{Value: status.String()},
 		},
 	})
-	return err
+	return mapError(err)
 }
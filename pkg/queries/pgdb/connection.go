@@ -0,0 +1,163 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rdsAuthTokenLifetime is how long an RDS IAM auth token can be used to open a connection.
+	rdsAuthTokenLifetime = 15 * time.Minute
+	// rdsAuthTokenRefreshMargin is how long before expiry a new RDS IAM auth token is built.
+	rdsAuthTokenRefreshMargin = 5 * time.Minute
+)
+
+// ConnectionConfig configures the connection pool of ConnectRDSWithConfig and ConnectENVWithConfig.
+// The zero value of each field keeps the database/sql or PostgreSQL default.
+type ConnectionConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout aborts any statement that runs longer, see the PostgreSQL statement_timeout setting.
+	StatementTimeout time.Duration
+}
+
+// HealthStatus is the result of HealthCheck.
+type HealthStatus struct {
+	Healthy bool          `json:"healthy"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	Stats   sql.DBStats   `json:"stats"`
+}
+
+// HealthCheck pings the database and reports the connection pool statistics.
+// An unhealthy database is reported in the HealthStatus and as the returned error.
+func HealthCheck(ctx context.Context, db *sql.DB) (*HealthStatus, error) {
+	start := time.Now()
+	err := db.PingContext(ctx)
+	status := HealthStatus{
+		Healthy: err == nil,
+		Latency: time.Since(start),
+		Stats:   db.Stats(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+		log.Error("Database health check failed: ", err)
+		return &status, fmt.Errorf("database health check failed: %w", err)
+	}
+	return &status, nil
+}
+
+// HealthCheck pings the database of the store and reports the connection pool statistics. See HealthCheck.
+func (store *SQLStore) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	return HealthCheck(ctx, store.db)
+}
+
+// configurePool applies the pool settings of cfg to db and checks the connection.
+func configurePool(db *sql.DB, cfg ConnectionConfig) (*sql.DB, error) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	err := db.Ping()
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error pinging DB connection: %w", err)
+	}
+	return db, nil
+}
+
+// buildDSN returns the connection string for lib/pq. Values are quoted, so passwords and auth tokens
+// may contain any character.
+func buildDSN(dbHost string, dbPort string, dbUser string, authenticationToken string, dbName string, sslMode string, cfg ConnectionConfig) string {
+	params := []string{
+		"host=" + dsnValue(dbHost),
+		"port=" + dsnValue(dbPort),
+		"user=" + dsnValue(dbUser),
+		"password=" + dsnValue(authenticationToken),
+		"dbname=" + dsnValue(dbName),
+	}
+	if sslMode != "" {
+		params = append(params, "sslmode="+dsnValue(sslMode))
+	}
+	if cfg.StatementTimeout > 0 {
+		params = append(params, fmt.Sprintf("statement_timeout=%d", cfg.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(params, " ")
+}
+
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// authTokenSource caches an RDS IAM auth token and builds a new one before it expires.
+type authTokenSource struct {
+	build   func(ctx context.Context) (string, error)
+	now     func() time.Time
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newAuthTokenSource(build func(ctx context.Context) (string, error)) *authTokenSource {
+	return &authTokenSource{build: build, now: time.Now}
+}
+
+// Token returns a token that is valid for at least rdsAuthTokenRefreshMargin.
+func (s *authTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Before(s.expires.Add(-rdsAuthTokenRefreshMargin)) {
+		return s.token, nil
+	}
+
+	token, err := s.build(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error building RDS auth token: %w", err)
+	}
+	s.token = token
+	s.expires = now.Add(rdsAuthTokenLifetime)
+	return token, nil
+}
+
+// rdsConnector opens every new connection with a current RDS IAM auth token. A token is only checked
+// when a connection is opened, so open connections keep working after the token expires.
+type rdsConnector struct {
+	dsn    func(token string) string
+	tokens *authTokenSource
+}
+
+func (c *rdsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(c.dsn(token))
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *rdsConnector) Driver() driver.Driver {
+	return pq.Driver{}
+}
@@ -0,0 +1,119 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestConnection is the main Test Suite function for connection configuration.
+func TestConnection(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T,
+	){
+		"Health check":              testHealthCheck,
+		"Pool configuration":        testConnectionConfig,
+		"Statement timeout":         testStatementTimeout,
+		"DSN quoting":               testBuildDSN,
+		"Auth token refresh":        testAuthTokenRefresh,
+		"Auth token build failures": testAuthTokenBuildFailure,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			fn(t)
+		})
+	}
+}
+
+func testHealthCheck(t *testing.T) {
+	store := NewSQLStore(testDB[0])
+
+	status, err := store.HealthCheck(context.Background())
+	if assert.NoError(t, err) {
+		assert.True(t, status.Healthy)
+		assert.Empty(t, status.Error)
+		assert.GreaterOrEqual(t, status.Stats.OpenConnections, 1)
+	}
+}
+
+func testConnectionConfig(t *testing.T) {
+	db, err := ConnectENVWithConfig(ConnectionConfig{
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Minute,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	status, err := HealthCheck(context.Background(), db)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, status.Stats.MaxOpenConnections)
+	}
+
+	assert.NoError(t, db.Close())
+	status, err = HealthCheck(context.Background(), db)
+	assert.Error(t, err)
+	assert.False(t, status.Healthy)
+	assert.NotEmpty(t, status.Error)
+}
+
+func testStatementTimeout(t *testing.T) {
+	db, err := ConnectENVWithConfig(ConnectionConfig{StatementTimeout: 10 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec("SELECT pg_sleep(1)")
+	var pqErr *pq.Error
+	if assert.ErrorAs(t, err, &pqErr) {
+		assert.Equal(t, pq.ErrorCode("57014"), pqErr.Code, "expected query_canceled")
+	}
+}
+
+func testBuildDSN(t *testing.T) {
+	dsn := buildDSN("host", "5432", "user", `to ken='\x`, "db", "disable", ConnectionConfig{StatementTimeout: 2 * time.Second})
+	assert.Equal(t, `host='host' port='5432' user='user' password='to ken=\'\\x' dbname='db' sslmode='disable' statement_timeout=2000`, dsn)
+
+	_, err := pq.NewConnector(dsn)
+	assert.NoError(t, err)
+}
+
+func testAuthTokenRefresh(t *testing.T) {
+	now := time.Now()
+	builds := 0
+	tokens := newAuthTokenSource(func(ctx context.Context) (string, error) {
+		builds++
+		return "token", nil
+	})
+	tokens.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := tokens.Token(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, builds, "expected the token to be cached")
+
+	now = now.Add(rdsAuthTokenLifetime - rdsAuthTokenRefreshMargin - time.Second)
+	_, err := tokens.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, builds, "expected the token to be cached until the refresh margin")
+
+	now = now.Add(2 * time.Second)
+	_, err = tokens.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, builds, "expected the token to be refreshed before it expires")
+}
+
+func testAuthTokenBuildFailure(t *testing.T) {
+	failure := errors.New("no credentials")
+	tokens := newAuthTokenSource(func(ctx context.Context) (string, error) {
+		return "", failure
+	})
+
+	_, err := tokens.Token(context.Background())
+	assert.ErrorIs(t, err, failure)
+}
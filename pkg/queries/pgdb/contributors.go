@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 	"strings"
 )
@@ -16,6 +17,11 @@ func (e ContributorNotFoundError) Error() string {
 	return fmt.Sprintf("contributor was not found (error: %v)", e.err)
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (e ContributorNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 type NewContributor struct {
 	FirstName     string
 	MiddleInitial string
@@ -70,14 +76,14 @@ func scanContributor(row *sql.Row) (*pgdb.Contributor, error) {
 		case sql.ErrNoRows:
 			return nil, ContributorNotFoundError{err}
 		default:
-			return nil, err
+			return nil, mapError(err)
 		}
 	}
 	return &contributor, nil
 }
 
-func getContributor(ctx context.Context, db DBTX, query string) (*pgdb.Contributor, error) {
-	return scanContributor(db.QueryRowContext(ctx, query))
+func getContributor(ctx context.Context, db DBTX, query string, args ...any) (*pgdb.Contributor, error) {
+	return scanContributor(db.QueryRowContext(ctx, query, args...))
 }
 
 // AddContributor will add a Contributor to the Organization's Contributors table.
@@ -115,7 +121,7 @@ func (q *Queries) AddContributor(ctx context.Context, newContributor NewContribu
 
 	// ExecContext returned an error
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return q.GetContributorByEmail(ctx, newContributor.EmailAddress)
@@ -139,29 +145,29 @@ func (q *Queries) FindContributor(ctx context.Context, search NewContributor) (*
 		contributor, err = q.GetContributorByOrcid(ctx, search.Orcid)
 	}
 
-	return contributor, err
+	return contributor, mapError(err)
 }
 
 // GetContributor will get a Contributor by the Contributor Id (not the User Id).
 func (q *Queries) GetContributor(ctx context.Context, id int64) (*pgdb.Contributor, error) {
-	query := fmt.Sprintf("SELECT %s FROM contributors WHERE id=%d", ReadContributorColumns(), id)
-	return getContributor(ctx, q.db, query)
+	query := fmt.Sprintf("SELECT %s FROM contributors WHERE id=$1", ReadContributorColumns())
+	return getContributor(ctx, q.db, query, id)
 }
 
 // GetContributorByUserId will get a Contributor by User Id.
 func (q *Queries) GetContributorByUserId(ctx context.Context, userId int64) (*pgdb.Contributor, error) {
-	query := fmt.Sprintf("SELECT %s FROM contributors WHERE user_id=%d", ReadContributorColumns(), userId)
-	return getContributor(ctx, q.db, query)
+	query := fmt.Sprintf("SELECT %s FROM contributors WHERE user_id=$1", ReadContributorColumns())
+	return getContributor(ctx, q.db, query, userId)
 }
 
 // GetContributorByEmail will get a Contributor by Email Address.
 func (q *Queries) GetContributorByEmail(ctx context.Context, email string) (*pgdb.Contributor, error) {
-	query := fmt.Sprintf("SELECT %s FROM contributors WHERE email='%s'", ReadContributorColumns(), email)
-	return getContributor(ctx, q.db, query)
+	query := fmt.Sprintf("SELECT %s FROM contributors WHERE email=$1", ReadContributorColumns())
+	return getContributor(ctx, q.db, query, email)
 }
 
 // GetContributorByOrcid will get a Contributor by ORCID iD.
 func (q *Queries) GetContributorByOrcid(ctx context.Context, orcid string) (*pgdb.Contributor, error) {
-	query := fmt.Sprintf("SELECT %s FROM contributors WHERE orcid='%s'", ReadContributorColumns(), orcid)
-	return getContributor(ctx, q.db, query)
+	query := fmt.Sprintf("SELECT %s FROM contributors WHERE orcid=$1", ReadContributorColumns())
+	return getContributor(ctx, q.db, query, orcid)
 }
@@ -7,14 +7,18 @@ import (
 )
 
 // GetDefaultDataUseAgreement will return the default data use agreement for the organization.
-// Returns (nil, sql.ErrNoRows) if no default data use agreement is found for the organization
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no default data use agreement is found for the organization.
 func (q *Queries) GetDefaultDataUseAgreement(ctx context.Context, organizationId int) (*pgdb.DataUseAgreement, error) {
+	dataUseAgreements, err := orgTable(int64(organizationId), "data_use_agreements")
+	if err != nil {
+		return nil, mapError(err)
+	}
 	query := fmt.Sprintf("SELECT id, name, body, created_at, is_default, description"+
-		" FROM \"%d\".data_use_agreements where is_default = true;", organizationId)
+		" FROM %s where is_default = true;", dataUseAgreements)
 
 	row := q.db.QueryRowContext(ctx, query)
 	dataUseAgreement := pgdb.DataUseAgreement{}
-	err := row.Scan(
+	err = row.Scan(
 		&dataUseAgreement.Id,
 		&dataUseAgreement.Name,
 		&dataUseAgreement.Body,
@@ -23,7 +27,7 @@ func (q *Queries) GetDefaultDataUseAgreement(ctx context.Context, organizationId
 		&dataUseAgreement.Description)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &dataUseAgreement, nil
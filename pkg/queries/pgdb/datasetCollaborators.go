@@ -0,0 +1,468 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/permissions"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
+)
+
+const datasetUserColumns = "dataset_id, user_id, role, permission_bit, created_at, updated_at"
+
+const datasetTeamColumns = "dataset_id, team_id, role, created_at, updated_at"
+
+type DatasetTeamNotFoundError struct {
+	ErrorMessage string
+}
+
+func (e DatasetTeamNotFoundError) Error() string {
+	return fmt.Sprintf("dataset team was not found (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrNotFound.
+func (e DatasetTeamNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
+// DatasetOwnershipError is returned if a change would leave a dataset without exactly one owner.
+type DatasetOwnershipError struct {
+	ErrorMessage string
+}
+
+func (e DatasetOwnershipError) Error() string {
+	return fmt.Sprintf("dataset must have exactly one owner (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrConflict.
+func (e DatasetOwnershipError) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
+
+// DatasetAccess is the access of a single user to a dataset, merged from all the ways it is granted.
+type DatasetAccess struct {
+	UserId int64 `json:"user_id"`
+	// Role is the highest of the dataset role, the user role and the team roles, as in GetDatasetClaim.
+	Role          role.Role         `json:"role"`
+	PermissionBit pgdb.DbPermission `json:"permission_bit"`
+	// UserRole is the role granted to the user directly, role.None if there is none.
+	UserRole role.Role `json:"user_role"`
+	// TeamRoles are the roles granted to the teams of the user, by team id.
+	TeamRoles map[int64]role.Role `json:"team_roles"`
+}
+
+// GetDatasetUsers returns the users of a dataset, ordered by user id.
+func (q *Queries) GetDatasetUsers(ctx context.Context, datasetId int64) ([]pgdb.DatasetUser, error) {
+	query := fmt.Sprintf("SELECT %s FROM dataset_user WHERE dataset_id=$1 ORDER BY user_id", datasetUserColumns)
+	rows, err := q.db.QueryContext(ctx, query, datasetId)
+	if err != nil {
+		log.Error("Error getting dataset users: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	datasetUsers := []pgdb.DatasetUser{}
+	for rows.Next() {
+		datasetUser, err := scanDatasetUser(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		datasetUsers = append(datasetUsers, *datasetUser)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return datasetUsers, nil
+}
+
+// UpdateDatasetUserRole changes the role of a user of a dataset.
+// The role of the owner cannot be changed and no user can be made owner, use TransferOwnership instead.
+func (q *Queries) UpdateDatasetUserRole(ctx context.Context, datasetId int64, userId int64, r role.Role) (*pgdb.DatasetUser, error) {
+	if r == role.Owner {
+		return nil, DatasetOwnershipError{"use TransferOwnership to change the owner"}
+	}
+
+	query := fmt.Sprintf("UPDATE dataset_user SET role=$1, permission_bit=$2, updated_at=$3 "+
+		"WHERE dataset_id=$4 AND user_id=$5 AND role<>$6 RETURNING %s", datasetUserColumns)
+	datasetUser, err := scanDatasetUser(q.db.QueryRowContext(ctx, query,
+		datasetRoleString(r), datasetRoleToPermission(r), time.Now(), datasetId, userId, datasetRoleString(role.Owner)))
+	if err == sql.ErrNoRows {
+		return nil, q.datasetOwnerGuardError(ctx, datasetId, userId, "change the role of")
+	}
+	if err != nil {
+		log.Error("Error updating dataset user: ", err)
+		return nil, mapError(err)
+	}
+	return datasetUser, nil
+}
+
+// RemoveDatasetUser removes a user from a dataset. The owner cannot be removed.
+func (q *Queries) RemoveDatasetUser(ctx context.Context, datasetId int64, userId int64) error {
+	statement := "DELETE FROM dataset_user WHERE dataset_id=$1 AND user_id=$2 AND role<>$3"
+	result, err := q.db.ExecContext(ctx, statement, datasetId, userId, datasetRoleString(role.Owner))
+	if err != nil {
+		log.Error("Error removing dataset user: ", err)
+		return mapError(err)
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if affectedRows == 0 {
+		return q.datasetOwnerGuardError(ctx, datasetId, userId, "remove")
+	}
+	return nil
+}
+
+// singleOwnerIndex is the partial unique index that allows a single owner per dataset,
+// see schema/organization/003_dataset_user_single_owner.sql.
+const singleOwnerIndex = "dataset_user_single_owner_idx"
+
+// TransferOwnership makes toUserId the owner of a dataset, and fromUserId, the current owner, a manager.
+// The transfer is executed in a single transaction, see Queries.TransferOwnership.
+func (store *SQLStore) TransferOwnership(ctx context.Context, datasetId int64, fromUserId int64, toUserId int64) (*pgdb.DatasetUser, error) {
+	var owner *pgdb.DatasetUser
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		owner, err = q.TransferOwnership(ctx, datasetId, fromUserId, toUserId)
+		return mapError(err)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return owner, nil
+}
+
+// TransferOwnership makes toUserId the owner of a dataset, and fromUserId, the current owner, a manager.
+//   - This call must be wrapped in a Transaction, as the dataset is locked until the end of the transaction
+//     to serialize concurrent transfers; SQLStore.TransferOwnership does so.
+//   - fromUserId must hold the permissions.TransferOwnership permission, which only the owner has,
+//     otherwise a DatasetOwnershipError is returned.
+//   - toUserId is added to the dataset if it is not a user of the dataset yet.
+//
+// It returns the new owner.
+func (q *Queries) TransferOwnership(ctx context.Context, datasetId int64, fromUserId int64, toUserId int64) (*pgdb.DatasetUser, error) {
+	if fromUserId == toUserId {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("user %d already owns dataset %d", toUserId, datasetId))
+	}
+
+	// Lock the dataset so concurrent transfers are serialized.
+	var id int64
+	err := q.db.QueryRowContext(ctx, "SELECT id FROM datasets WHERE id=$1 FOR UPDATE", datasetId).Scan(&id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	from, err := q.getDatasetUser(ctx, datasetId, fromUserId)
+	if err != nil {
+		if _, ok := err.(DatasetUserNotFoundError); ok {
+			return nil, DatasetOwnershipError{fmt.Sprintf("user %d is not the owner of dataset %d", fromUserId, datasetId)}
+		}
+		return nil, mapError(err)
+	}
+	fromRole, ok := role.RoleFromString(from.Role)
+	if !ok || !permissions.HasDatasetPermission(fromRole, permissions.TransferOwnership) {
+		return nil, DatasetOwnershipError{fmt.Sprintf("user %d is not the owner of dataset %d", fromUserId, datasetId)}
+	}
+
+	currentTime := time.Now()
+	statement := "UPDATE dataset_user SET role=$1, permission_bit=$2, updated_at=$3 WHERE dataset_id=$4 AND user_id=$5"
+	if _, err := q.db.ExecContext(ctx, statement,
+		datasetRoleString(role.Manager), datasetRoleToPermission(role.Manager), currentTime, datasetId, fromUserId); err != nil {
+		log.Error("Error demoting dataset owner: ", err)
+		return nil, mapError(err)
+	}
+
+	result, err := q.db.ExecContext(ctx, statement,
+		datasetRoleString(role.Owner), datasetRoleToPermission(role.Owner), currentTime, datasetId, toUserId)
+	if err != nil {
+		log.Error("Error updating dataset owner: ", err)
+		return nil, mapError(err)
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if affectedRows == 0 {
+		statement = "INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, $2, $3, $4)"
+		if _, err := q.db.ExecContext(ctx, statement,
+			datasetId, toUserId, datasetRoleString(role.Owner), datasetRoleToPermission(role.Owner)); err != nil {
+			log.Error("Error adding dataset owner: ", err)
+			return nil, mapError(err)
+		}
+	}
+
+	return q.getDatasetUser(ctx, datasetId, toUserId)
+}
+
+// GetDatasetTeam returns the role of a team on a dataset.
+// Returns (nil, DatasetTeamNotFoundError) if the team was not added to the dataset.
+func (q *Queries) GetDatasetTeam(ctx context.Context, datasetId int64, teamId int64) (*pgdb.DatasetTeam, error) {
+	query := fmt.Sprintf("SELECT %s FROM dataset_team WHERE dataset_id=$1 AND team_id=$2", datasetTeamColumns)
+	datasetTeam, err := scanDatasetTeam(q.db.QueryRowContext(ctx, query, datasetId, teamId))
+	if err == sql.ErrNoRows {
+		return nil, DatasetTeamNotFoundError{fmt.Sprintf("team %d on dataset %d", teamId, datasetId)}
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return datasetTeam, nil
+}
+
+// GetDatasetTeams returns the teams of a dataset, ordered by team id.
+func (q *Queries) GetDatasetTeams(ctx context.Context, datasetId int64) ([]pgdb.DatasetTeam, error) {
+	query := fmt.Sprintf("SELECT %s FROM dataset_team WHERE dataset_id=$1 ORDER BY team_id", datasetTeamColumns)
+	rows, err := q.db.QueryContext(ctx, query, datasetId)
+	if err != nil {
+		log.Error("Error getting dataset teams: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	datasetTeams := []pgdb.DatasetTeam{}
+	for rows.Next() {
+		datasetTeam, err := scanDatasetTeam(rows)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		datasetTeams = append(datasetTeams, *datasetTeam)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return datasetTeams, nil
+}
+
+// AddDatasetTeam shares a dataset with a team. If the team was already added, the existing role is returned.
+// A team cannot own a dataset.
+func (q *Queries) AddDatasetTeam(ctx context.Context, datasetId int64, teamId int64, r role.Role) (*pgdb.DatasetTeam, error) {
+	if r == role.Owner {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "a team cannot own a dataset")
+	}
+
+	existing, err := q.GetDatasetTeam(ctx, datasetId, teamId)
+	if err == nil {
+		return existing, nil
+	}
+	if _, ok := err.(DatasetTeamNotFoundError); !ok {
+		return nil, mapError(err)
+	}
+
+	statement := "INSERT INTO dataset_team (dataset_id, team_id, role, permission_bit) VALUES ($1, $2, $3, $4)"
+	_, err = q.db.ExecContext(ctx, statement, datasetId, teamId, datasetRoleString(r), datasetRoleToPermission(r))
+	if err != nil {
+		log.Error("Error adding dataset team: ", err)
+		return nil, mapError(err)
+	}
+
+	return q.GetDatasetTeam(ctx, datasetId, teamId)
+}
+
+// UpdateDatasetTeamRole changes the role of a team on a dataset. A team cannot own a dataset.
+func (q *Queries) UpdateDatasetTeamRole(ctx context.Context, datasetId int64, teamId int64, r role.Role) (*pgdb.DatasetTeam, error) {
+	if r == role.Owner {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "a team cannot own a dataset")
+	}
+
+	query := fmt.Sprintf("UPDATE dataset_team SET role=$1, permission_bit=$2, updated_at=$3 "+
+		"WHERE dataset_id=$4 AND team_id=$5 RETURNING %s", datasetTeamColumns)
+	datasetTeam, err := scanDatasetTeam(q.db.QueryRowContext(ctx, query,
+		datasetRoleString(r), datasetRoleToPermission(r), time.Now(), datasetId, teamId))
+	if err == sql.ErrNoRows {
+		return nil, DatasetTeamNotFoundError{fmt.Sprintf("team %d on dataset %d", teamId, datasetId)}
+	}
+	if err != nil {
+		log.Error("Error updating dataset team: ", err)
+		return nil, mapError(err)
+	}
+	return datasetTeam, nil
+}
+
+// RemoveDatasetTeam stops sharing a dataset with a team.
+func (q *Queries) RemoveDatasetTeam(ctx context.Context, datasetId int64, teamId int64) error {
+	result, err := q.db.ExecContext(ctx, "DELETE FROM dataset_team WHERE dataset_id=$1 AND team_id=$2", datasetId, teamId)
+	if err != nil {
+		log.Error("Error removing dataset team: ", err)
+		return mapError(err)
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if affectedRows == 0 {
+		return DatasetTeamNotFoundError{fmt.Sprintf("team %d on dataset %d", teamId, datasetId)}
+	}
+	return nil
+}
+
+// GetDatasetAccess returns the effective access of every user that was added to a dataset, either directly
+// or through a team, ordered by user id. Users whose only access is the dataset role are not included.
+func (q *Queries) GetDatasetAccess(ctx context.Context, datasetId int64) ([]DatasetAccess, error) {
+	var maybeDatasetRole sql.NullString
+	err := q.db.QueryRowContext(ctx, "SELECT role FROM datasets WHERE id=$1", datasetId).Scan(&maybeDatasetRole)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	datasetRole := role.None
+	if maybeDatasetRole.Valid {
+		if datasetRole, err = parseDatasetRole(maybeDatasetRole.String); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "SELECT user_id, role, NULL::bigint FROM dataset_user WHERE dataset_id=$1 " +
+		"UNION ALL " +
+		"SELECT pennsieve.team_user.user_id, dataset_team.role, dataset_team.team_id FROM dataset_team " +
+		"JOIN pennsieve.team_user ON pennsieve.team_user.team_id = dataset_team.team_id " +
+		"WHERE dataset_team.dataset_id=$1"
+	rows, err := q.db.QueryContext(ctx, query, datasetId)
+	if err != nil {
+		log.Error("Error getting dataset access: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	accessByUser := make(map[int64]*DatasetAccess)
+	for rows.Next() {
+		var userId int64
+		var roleString string
+		var teamId sql.NullInt64
+		if err := rows.Scan(&userId, &roleString, &teamId); err != nil {
+			return nil, mapError(err)
+		}
+		r, err := parseDatasetRole(roleString)
+		if err != nil {
+			return nil, err
+		}
+
+		access, ok := accessByUser[userId]
+		if !ok {
+			access = &DatasetAccess{UserId: userId, Role: datasetRole, TeamRoles: map[int64]role.Role{}}
+			accessByUser[userId] = access
+		}
+		if teamId.Valid {
+			access.TeamRoles[teamId.Int64] = r
+		} else {
+			access.UserRole = r
+		}
+		if r > access.Role {
+			access.Role = r
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	accessList := make([]DatasetAccess, 0, len(accessByUser))
+	for _, access := range accessByUser {
+		access.PermissionBit = datasetRoleToPermission(access.Role)
+		accessList = append(accessList, *access)
+	}
+	sort.Slice(accessList, func(i, j int) bool {
+		return accessList[i].UserId < accessList[j].UserId
+	})
+	return accessList, nil
+}
+
+func (q *Queries) getDatasetUser(ctx context.Context, datasetId int64, userId int64) (*pgdb.DatasetUser, error) {
+	query := fmt.Sprintf("SELECT %s FROM dataset_user WHERE dataset_id=$1 AND user_id=$2", datasetUserColumns)
+	datasetUser, err := scanDatasetUser(q.db.QueryRowContext(ctx, query, datasetId, userId))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, DatasetUserNotFoundError{fmt.Sprintf("%+v", err)}
+		default:
+			return nil, mapError(err)
+		}
+	}
+	return datasetUser, nil
+}
+
+// getDatasetOwnerId returns the id of the owner of a dataset, or 0 if the dataset has no owner.
+func (q *Queries) getDatasetOwnerId(ctx context.Context, datasetId int64) (int64, error) {
+	var ownerId int64
+	query := "SELECT user_id FROM dataset_user WHERE dataset_id=$1 AND role=$2 ORDER BY created_at LIMIT 1"
+	err := q.db.QueryRowContext(ctx, query, datasetId, datasetRoleString(role.Owner)).Scan(&ownerId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return ownerId, nil
+}
+
+// datasetOwnerGuardError returns the error for a change of a dataset user that matched no row, either
+// because the user is the owner or because the user was not added to the dataset.
+func (q *Queries) datasetOwnerGuardError(ctx context.Context, datasetId int64, userId int64, action string) error {
+	datasetUser, err := q.getDatasetUser(ctx, datasetId, userId)
+	if err != nil {
+		return err
+	}
+	if datasetUser.Role == datasetRoleString(role.Owner) {
+		return DatasetOwnershipError{fmt.Sprintf("cannot %s the owner of dataset %d, use TransferOwnership", action, datasetId)}
+	}
+	return dbErrors.New(dbErrors.ErrConflict, fmt.Sprintf("dataset user %d changed concurrently", userId))
+}
+
+func scanDatasetUser(row rowScanner) (*pgdb.DatasetUser, error) {
+	var datasetUser pgdb.DatasetUser
+	err := row.Scan(
+		&datasetUser.DatasetId,
+		&datasetUser.UserId,
+		&datasetUser.Role,
+		&datasetUser.PermissionBit,
+		&datasetUser.CreatedAt,
+		&datasetUser.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &datasetUser, nil
+}
+
+func scanDatasetTeam(row rowScanner) (*pgdb.DatasetTeam, error) {
+	var datasetTeam pgdb.DatasetTeam
+	var roleString string
+	err := row.Scan(
+		&datasetTeam.DatasetId,
+		&datasetTeam.TeamId,
+		&roleString,
+		&datasetTeam.CreatedAt,
+		&datasetTeam.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if datasetTeam.Role, err = parseDatasetRole(roleString); err != nil {
+		return nil, err
+	}
+	return &datasetTeam, nil
+}
+
+// datasetRoleString is the representation of a role in the role columns of the dataset tables.
+func datasetRoleString(r role.Role) string {
+	return strings.ToLower(r.String())
+}
+
+func parseDatasetRole(roleString string) (role.Role, error) {
+	r, ok := role.RoleFromString(roleString)
+	if !ok {
+		return role.None, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("error mapping Dataset Role from database string: %s", roleString))
+	}
+	return r, nil
+}
+
+// isSingleOwnerViolation returns true if err is a violation of singleOwnerIndex.
+func isSingleOwnerViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == singleOwnerIndex
+}
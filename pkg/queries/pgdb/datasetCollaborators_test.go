@@ -0,0 +1,221 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestDatasetCollaborators is the main Test Suite function for the users and teams of Datasets.
+func TestDatasetCollaborators(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Manage dataset users":       testManageDatasetUsers,
+		"Dataset keeps one owner":    testDatasetKeepsOneOwner,
+		"Transfer dataset ownership": testTransferOwnership,
+		"Manage dataset teams":       testManageDatasetTeams,
+		"Get dataset access":         testGetDatasetAccess,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 3
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testManageDatasetUsers(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - ManageDatasetUsers"))
+	require.NoError(t, err)
+
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1003}, role.Owner)
+	require.NoError(t, err)
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1001}, role.Viewer)
+	require.NoError(t, err)
+
+	updated, err := store.UpdateDatasetUserRole(ctx, ds.Id, 1001, role.Editor)
+	require.NoError(t, err)
+	assert.Equal(t, "editor", updated.Role)
+	assert.Equal(t, int64(pgdb.Delete), updated.PermissionBit)
+
+	users, err := store.GetDatasetUsers(ctx, ds.Id)
+	require.NoError(t, err)
+	if assert.Len(t, users, 2) {
+		assert.Equal(t, int64(1001), users[0].UserId)
+		assert.Equal(t, int64(1003), users[1].UserId)
+	}
+
+	require.NoError(t, store.RemoveDatasetUser(ctx, ds.Id, 1001))
+	_, err = store.GetDatasetUser(ctx, ds, &pgdb.User{Id: 1001})
+	assert.IsType(t, DatasetUserNotFoundError{}, err)
+
+	err = store.RemoveDatasetUser(ctx, ds.Id, 1001)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+	_, err = store.UpdateDatasetUserRole(ctx, ds.Id, 1001, role.Viewer)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
+
+func testDatasetKeepsOneOwner(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - DatasetKeepsOneOwner"))
+	require.NoError(t, err)
+
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1003}, role.Owner)
+	require.NoError(t, err)
+
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1001}, role.Owner)
+	assert.IsType(t, DatasetOwnershipError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+
+	// The schema rejects a second owner that gets past the check, like a concurrent AddDatasetUser.
+	_, err = store.db.Exec("INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, 1001, 'owner', $2)",
+		ds.Id, pgdb.Owner)
+	assert.True(t, isSingleOwnerViolation(err), "unexpected error: %v", err)
+
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1001}, role.Manager)
+	require.NoError(t, err)
+	_, err = store.UpdateDatasetUserRole(ctx, ds.Id, 1001, role.Owner)
+	assert.IsType(t, DatasetOwnershipError{}, err)
+
+	_, err = store.UpdateDatasetUserRole(ctx, ds.Id, 1003, role.Manager)
+	assert.IsType(t, DatasetOwnershipError{}, err)
+	err = store.RemoveDatasetUser(ctx, ds.Id, 1003)
+	assert.IsType(t, DatasetOwnershipError{}, err)
+
+	owner, err := store.GetDatasetUser(ctx, ds, &pgdb.User{Id: 1003})
+	require.NoError(t, err)
+	assert.Equal(t, "owner", owner.Role)
+}
+
+func testTransferOwnership(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - TransferOwnership"))
+	require.NoError(t, err)
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1003}, role.Owner)
+	require.NoError(t, err)
+
+	// The new owner does not have to be a user of the dataset yet.
+	var owner *pgdb.DatasetUser
+	err = store.ExecTx(ctx, TxOptions{}, func(qtx *TxQueries) error {
+		owner, err = qtx.TransferOwnership(ctx, ds.Id, 1003, 1001)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), owner.UserId)
+	assert.Equal(t, "owner", owner.Role)
+	assert.Equal(t, int64(pgdb.Owner), owner.PermissionBit)
+
+	previous, err := store.GetDatasetUser(ctx, ds, &pgdb.User{Id: 1003})
+	require.NoError(t, err)
+	assert.Equal(t, "manager", previous.Role)
+
+	// Only the owner can transfer ownership.
+	_, err = store.TransferOwnership(ctx, ds.Id, 1003, 1003)
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+	_, err = store.TransferOwnership(ctx, ds.Id, 1003, 1001)
+	assert.IsType(t, DatasetOwnershipError{}, err)
+
+	// The previous owner is an existing user.
+	owner, err = store.TransferOwnership(ctx, ds.Id, 1001, 1003)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1003), owner.UserId)
+
+	users, err := store.GetDatasetUsers(ctx, ds.Id)
+	require.NoError(t, err)
+	var owners int
+	for _, u := range users {
+		if u.Role == "owner" {
+			owners++
+		}
+	}
+	assert.Equal(t, 1, owners)
+}
+
+func testManageDatasetTeams(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	datasetId := addTestDataset(store.db, "Test Dataset - ManageDatasetTeams")
+
+	added, err := store.AddDatasetTeam(ctx, datasetId, researchTeamId, role.Viewer)
+	require.NoError(t, err)
+	assert.Equal(t, role.Viewer, added.Role)
+
+	again, err := store.AddDatasetTeam(ctx, datasetId, researchTeamId, role.Editor)
+	require.NoError(t, err)
+	assert.Equal(t, role.Viewer, again.Role)
+
+	_, err = store.AddDatasetTeam(ctx, datasetId, publishingTeamId, role.Owner)
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+	_, err = store.AddDatasetTeam(ctx, datasetId, publishingTeamId, role.Manager)
+	require.NoError(t, err)
+
+	updated, err := store.UpdateDatasetTeamRole(ctx, datasetId, researchTeamId, role.Editor)
+	require.NoError(t, err)
+	assert.Equal(t, role.Editor, updated.Role)
+
+	teams, err := store.GetDatasetTeams(ctx, datasetId)
+	require.NoError(t, err)
+	assert.Len(t, teams, 2)
+
+	require.NoError(t, store.RemoveDatasetTeam(ctx, datasetId, researchTeamId))
+	_, err = store.GetDatasetTeam(ctx, datasetId, researchTeamId)
+	assert.IsType(t, DatasetTeamNotFoundError{}, err)
+	assert.ErrorIs(t, store.RemoveDatasetTeam(ctx, datasetId, researchTeamId), dbErrors.ErrNotFound)
+	_, err = store.UpdateDatasetTeamRole(ctx, datasetId, researchTeamId, role.Viewer)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
+
+func testGetDatasetAccess(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - GetDatasetAccess"))
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET role = 'viewer' WHERE id = $1", ds.Id)
+	require.NoError(t, err)
+
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1003}, role.Owner)
+	require.NoError(t, err)
+	_, err = store.AddDatasetUser(ctx, ds, &pgdb.User{Id: 1001}, role.None)
+	require.NoError(t, err)
+	_, err = store.AddDatasetTeam(ctx, ds.Id, researchTeamId, role.Editor)
+	require.NoError(t, err)
+	_, err = store.AddDatasetTeam(ctx, ds.Id, publishingTeamId, role.Manager)
+	require.NoError(t, err)
+
+	access, err := store.GetDatasetAccess(ctx, ds.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []DatasetAccess{
+		{
+			UserId:        1001,
+			Role:          role.Manager,
+			PermissionBit: pgdb.Administer,
+			UserRole:      role.None,
+			TeamRoles:     map[int64]role.Role{researchTeamId: role.Editor, publishingTeamId: role.Manager},
+		},
+		{
+			UserId:        1003,
+			Role:          role.Owner,
+			PermissionBit: pgdb.Owner,
+			UserRole:      role.Owner,
+			TeamRoles:     map[int64]role.Role{},
+		},
+	}, access)
+
+	_, err = store.GetDatasetAccess(ctx, 999999)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
@@ -16,7 +16,7 @@ func (q *Queries) GetDatasetContributor(ctx context.Context, datasetId int64, co
 		&datasetContributor.ContributorOrder)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &datasetContributor, nil
 }
@@ -26,7 +26,7 @@ func (q *Queries) AddDatasetContributor(ctx context.Context, dataset *pgdb.Datas
 	statement := "INSERT INTO dataset_contributor(dataset_id, contributor_id, contributor_order) VALUES($1, $2, $3)"
 	_, err := q.db.ExecContext(ctx, statement, dataset.Id, contributor.Id, position)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return q.GetDatasetContributor(ctx, dataset.Id, contributor.Id)
 }
@@ -0,0 +1,318 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/domain"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	defaultPurgeBatchSize = 1000
+	// maxDeleteObjectsKeys is the maximum number of keys in a single S3 DeleteObjects request.
+	maxDeleteObjectsKeys = 1000
+)
+
+type InvalidDatasetPurgeError struct {
+	ErrorMessage string
+}
+
+func (e InvalidDatasetPurgeError) Error() string {
+	return fmt.Sprintf("invalid dataset purge (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidDatasetPurgeError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// S3Object is an object in S3 that is no longer referenced by any file.
+type S3Object struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// PurgeDatasetResult describes what PurgeDataset removed.
+type PurgeDatasetResult struct {
+	DatasetId int64 `json:"dataset_id"`
+	Packages  int64 `json:"packages"`
+	Files     int64 `json:"files"`
+	// Size is the storage of the dataset that was released from the organization.
+	Size int64 `json:"size"`
+	// Objects is the number of S3 objects of the deleted files that were deleted.
+	Objects int64 `json:"objects"`
+}
+
+// DeleteObjectsFunc deletes S3 objects that are no longer referenced, see PurgeDataset and S3ObjectDeleter.
+type DeleteObjectsFunc func(ctx context.Context, objects []S3Object) error
+
+// MarkDatasetForDeletion sets the state of a dataset to DELETING, which hides it from SearchDatasets.
+// The dataset and its contents are removed by PurgeDataset. Marking a dataset twice has no effect.
+func (q *Queries) MarkDatasetForDeletion(ctx context.Context, datasetId int64) (*pgdb.Dataset, error) {
+	currentTime := time.Now()
+	queryStr := fmt.Sprintf("UPDATE datasets SET state=$1, "+
+		"updated_at=CASE WHEN state=$1 THEN updated_at ELSE $2 END, "+
+		"etag=CASE WHEN state=$1 THEN etag ELSE $2 END "+
+		"WHERE id=$3 RETURNING %s", datasetColumns)
+
+	ds, err := scanDataset(q.db.QueryRowContext(ctx, queryStr, state.DELETING, currentTime, datasetId))
+	if err != nil {
+		log.Error("Error marking dataset for deletion: ", err)
+		return nil, mapError(err)
+	}
+	return ds, nil
+}
+
+// PurgeDataset removes a dataset that was marked with MarkDatasetForDeletion, together with its files,
+// packages, users, teams, contributors and storage, and releases its storage from the organization.
+//   - Files and packages are removed in batches of batchSize rows (0 uses 1000), each batch in its own
+//     transaction. If it fails, the result holds what was removed so far, and PurgeDataset can be called
+//     again to continue.
+//   - The S3 objects of each batch of files are passed to deleteObjects before the batch is committed, so an
+//     object is never left behind without a file that references it. If deleteObjects or the commit returns
+//     an error, the batch is rolled back, but objects that were deleted stay deleted and the remaining files
+//     may reference them. After any error, PurgeDataset must be called again until it succeeds. Objects that
+//     are still referenced by another file, such as a copy, and objects of published files are not deleted.
+//   - deleteObjects is required; a nil deleteObjects returns an InvalidDatasetPurgeError.
+func (store *SQLStore) PurgeDataset(ctx context.Context, organizationId int64, datasetId int64, batchSize int, deleteObjects DeleteObjectsFunc) (*PurgeDatasetResult, error) {
+	if deleteObjects == nil {
+		return nil, InvalidDatasetPurgeError{"deleteObjects is required"}
+	}
+	if batchSize <= 0 {
+		batchSize = defaultPurgeBatchSize
+	}
+	opts := TxOptions{OrganizationId: organizationId}
+
+	err := store.ExecTx(ctx, opts, func(qtx *TxQueries) error {
+		ds, err := qtx.GetDatasetById(ctx, datasetId)
+		if err != nil {
+			return mapError(err)
+		}
+		if ds.State != state.DELETING {
+			return InvalidDatasetPurgeError{fmt.Sprintf("dataset %d is %s, not %s", datasetId, ds.State, state.DELETING)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	result := PurgeDatasetResult{DatasetId: datasetId}
+
+	for {
+		var deleted int64
+		var objects []S3Object
+		err := store.ExecTx(ctx, opts, func(qtx *TxQueries) error {
+			var err error
+			deleted, objects, err = qtx.purgeFileBatch(ctx, datasetId, batchSize)
+			if err != nil || len(objects) == 0 {
+				return mapError(err)
+			}
+			if err := deleteObjects(ctx, objects); err != nil {
+				return fmt.Errorf("deleting %d S3 objects: %w", len(objects), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return &result, mapError(fmt.Errorf("purging files of dataset %d: %w", datasetId, err))
+		}
+		result.Files += deleted
+		result.Objects += int64(len(objects))
+		if deleted < int64(batchSize) {
+			break
+		}
+	}
+
+	// Leaves are deleted first, so no package is deleted while it still has children.
+	packageQuery := "WITH batch AS (" +
+		"SELECT p.id FROM packages p WHERE p.dataset_id = $1 " +
+		"AND NOT EXISTS (SELECT 1 FROM packages c WHERE c.parent_id = p.id) LIMIT $2), " +
+		"storage AS (DELETE FROM package_storage WHERE package_id IN (SELECT id FROM batch)) " +
+		"DELETE FROM packages WHERE id IN (SELECT id FROM batch)"
+	for {
+		var deleted int64
+		err := store.ExecTx(ctx, opts, func(qtx *TxQueries) error {
+			res, err := qtx.db.ExecContext(ctx, packageQuery, datasetId, batchSize)
+			if err != nil {
+				log.Error("Error purging packages: ", err)
+				return mapError(err)
+			}
+			deleted, err = res.RowsAffected()
+			return mapError(err)
+		})
+		if err != nil {
+			return &result, mapError(fmt.Errorf("purging packages of dataset %d: %w", datasetId, err))
+		}
+		result.Packages += deleted
+		if deleted == 0 {
+			break
+		}
+	}
+
+	err = store.ExecTx(ctx, opts, func(qtx *TxQueries) error {
+		for _, table := range []string{"dataset_user", "dataset_team", "dataset_contributor"} {
+			if _, err := qtx.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE dataset_id = $1", table), datasetId); err != nil {
+				log.Error(fmt.Sprintf("Error purging %s: %v", table, err))
+				return mapError(fmt.Errorf("purging %s of dataset %d: %w", table, datasetId, err))
+			}
+		}
+
+		// The dataset storage is removed and released from the organization in a single statement, so a
+		// retry cannot release it twice.
+		queryStr := "WITH storage AS (DELETE FROM dataset_storage WHERE dataset_id = $1 RETURNING size), " +
+			"total AS (SELECT COALESCE(SUM(size), 0)::bigint AS size FROM storage), " +
+			"organization AS (UPDATE pennsieve.organization_storage " +
+			"SET size = GREATEST(COALESCE(organization_storage.size, 0) - (SELECT size FROM total), 0) " +
+			"WHERE organization_id = $2) " +
+			"SELECT size FROM total"
+		if err := qtx.db.QueryRowContext(ctx, queryStr, datasetId, organizationId).Scan(&result.Size); err != nil {
+			log.Error("Error purging dataset storage: ", err)
+			return mapError(err)
+		}
+
+		if _, err := qtx.db.ExecContext(ctx, "DELETE FROM datasets WHERE id = $1", datasetId); err != nil {
+			log.Error("Error purging dataset: ", err)
+			return mapError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return &result, mapError(err)
+	}
+
+	return &result, nil
+}
+
+// purgeFileBatch deletes up to batchSize files of a dataset. It returns the number of deleted files and the
+// S3 objects that are no longer referenced by any file.
+func (q *Queries) purgeFileBatch(ctx context.Context, datasetId int64, batchSize int) (int64, []S3Object, error) {
+	queryStr := "WITH deleted AS (DELETE FROM files WHERE id IN (" +
+		"SELECT f.id FROM files f JOIN packages p ON p.id = f.package_id WHERE p.dataset_id = $1 LIMIT $2) " +
+		"RETURNING s3_bucket, s3_key, published_s3_version_id) " +
+		"SELECT s3_bucket, s3_key, BOOL_OR(published_s3_version_id IS NOT NULL), COUNT(*) FROM deleted GROUP BY s3_bucket, s3_key"
+
+	rows, err := q.db.QueryContext(ctx, queryStr, datasetId, batchSize)
+	if err != nil {
+		log.Error("Error purging files: ", err)
+		return 0, nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var deleted int64
+	var buckets, keys []string
+	for rows.Next() {
+		var bucket, key string
+		var published bool
+		var count int64
+		if err := rows.Scan(&bucket, &key, &published, &count); err != nil {
+			return 0, nil, mapError(err)
+		}
+		deleted += count
+		if !published {
+			buckets = append(buckets, bucket)
+			keys = append(keys, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, mapError(err)
+	}
+	if len(keys) == 0 {
+		return deleted, nil, nil
+	}
+
+	// Keep objects that are shared with files that still exist.
+	queryStr = "SELECT o.bucket, o.key FROM UNNEST($1::text[], $2::text[]) AS o(bucket, key) " +
+		"WHERE NOT EXISTS (SELECT 1 FROM files f WHERE f.s3_bucket = o.bucket AND f.s3_key = o.key)"
+	rows, err = q.db.QueryContext(ctx, queryStr, pq.Array(buckets), pq.Array(keys))
+	if err != nil {
+		return 0, nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var objects []S3Object
+	for rows.Next() {
+		var o S3Object
+		if err := rows.Scan(&o.Bucket, &o.Key); err != nil {
+			return 0, nil, mapError(err)
+		}
+		objects = append(objects, o)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, mapError(err)
+	}
+
+	return deleted, objects, nil
+}
+
+// S3ObjectDeleter returns a DeleteObjectsFunc that deletes the objects with DeleteS3Objects. It fails if any
+// object could not be deleted.
+func S3ObjectDeleter(client domain.S3API) DeleteObjectsFunc {
+	return func(ctx context.Context, objects []S3Object) error {
+		failed, err := DeleteS3Objects(ctx, client, objects)
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("unable to delete %d of %d objects", len(failed), len(objects))
+		}
+		return nil
+	}
+}
+
+// DeleteS3Objects deletes objects with DeleteObjects requests of up to 1000 keys.
+// It returns the objects that could not be deleted, which can be passed to DeleteS3Objects again.
+func DeleteS3Objects(ctx context.Context, client domain.S3API, objects []S3Object) ([]S3Object, error) {
+	byBucket := make(map[string][]string)
+	var buckets []string
+	for _, o := range objects {
+		if _, ok := byBucket[o.Bucket]; !ok {
+			buckets = append(buckets, o.Bucket)
+		}
+		byBucket[o.Bucket] = append(byBucket[o.Bucket], o.Key)
+	}
+
+	var failed []S3Object
+	var errs []error
+	for _, bucket := range buckets {
+		keys := byBucket[bucket]
+		for start := 0; start < len(keys); start += maxDeleteObjectsKeys {
+			end := start + maxDeleteObjectsKeys
+			if end > len(keys) {
+				end = len(keys)
+			}
+			batch := keys[start:end]
+
+			identifiers := make([]types.ObjectIdentifier, len(batch))
+			for i, key := range batch {
+				identifiers[i] = types.ObjectIdentifier{Key: aws.String(key)}
+			}
+
+			output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &types.Delete{Objects: identifiers, Quiet: true},
+			})
+			if err != nil {
+				log.Error(fmt.Sprintf("Error deleting objects from %s: %v", bucket, err))
+				for _, key := range batch {
+					failed = append(failed, S3Object{Bucket: bucket, Key: key})
+				}
+				errs = append(errs, fmt.Errorf("error deleting objects from %s: %w", bucket, err))
+				continue
+			}
+			for _, e := range output.Errors {
+				log.Warn(fmt.Sprintf("Unable to delete %s/%s: %s", bucket, aws.ToString(e.Key), aws.ToString(e.Message)))
+				failed = append(failed, S3Object{Bucket: bucket, Key: aws.ToString(e.Key)})
+			}
+		}
+	}
+	return failed, errors.Join(errs...)
+}
@@ -0,0 +1,227 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestDatasetDeletion is the main Test Suite function for deleting Datasets.
+func TestDatasetDeletion(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Mark dataset for deletion":   testMarkDatasetForDeletion,
+		"Purge dataset":               testPurgeDataset,
+		"Purge dataset not marked":    testPurgeDatasetNotMarked,
+		"Delete S3 objects in chunks": testDeleteS3Objects,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testMarkDatasetForDeletion(t *testing.T, store *SQLStore, orgId int) {
+	ctx := context.Background()
+	datasetId := addTestDataset(store.db, "Test Dataset - MarkDatasetForDeletion")
+	defer store.db.Exec("DELETE FROM datasets WHERE id = $1", datasetId)
+
+	marked, err := store.MarkDatasetForDeletion(ctx, datasetId)
+	require.NoError(t, err)
+	assert.Equal(t, state.DELETING, marked.State)
+
+	again, err := store.MarkDatasetForDeletion(ctx, datasetId)
+	require.NoError(t, err)
+	assert.True(t, again.ETag.Equal(marked.ETag))
+
+	page, err := store.SearchDatasets(ctx, SearchDatasetsParams{Name: "MarkDatasetForDeletion"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Datasets)
+
+	// Datasets that are being deleted are not found by node id and grant no access.
+	_, err = store.GetDatasetByNodeId(ctx, marked.NodeId.String)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+	_, err = store.GetDatasetClaim(ctx, &pgdb.User{Id: 1001}, marked.NodeId.String, int64(orgId))
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+	_, err = store.GetDatasetById(ctx, datasetId)
+	assert.NoError(t, err)
+
+	_, err = store.MarkDatasetForDeletion(ctx, 999999)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
+
+func testPurgeDataset(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	ctx := context.Background()
+	datasetId := addTestDataset(store.db, "Test Dataset - PurgeDataset")
+
+	uploadId := uuid.New().String()
+	folder, err := store.AddFolder(ctx, pgdb.PackageParams{
+		Name:         "folder",
+		PackageType:  packageType.Collection,
+		PackageState: packageState.Ready,
+		NodeId:       fmt.Sprintf("N:collection:%s", uploadId),
+		ParentId:     -1,
+		DatasetId:    int(datasetId),
+		OwnerId:      1,
+		ImportId:     sql.NullString{String: uploadId, Valid: true},
+		Attributes:   []packageInfo.PackageAttribute{},
+	})
+	require.NoError(t, err)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.edf", ParentId: folder.Id},
+		{Name: "b.edf", ParentId: folder.Id},
+		{Name: "c.edf", ParentId: -1},
+	}, int(datasetId)))
+	require.NoError(t, err)
+	addTestFiles(t, store, int(packages[0].Id), "purge/a.edf")
+	addTestFiles(t, store, int(packages[1].Id), "purge/b.edf")
+	addTestFiles(t, store, int(packages[2].Id), "purge/shared.edf")
+
+	// A copy in another dataset still uses the shared object.
+	other, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "copy.edf", ParentId: -1},
+	}, 1))
+	require.NoError(t, err)
+	addTestFiles(t, store, int(other[0].Id), "purge/shared.edf")
+
+	require.NoError(t, store.IncrementDatasetStorage(ctx, datasetId, 3072))
+	require.NoError(t, store.IncrementOrganizationStorage(ctx, int64(orgId), 4096))
+	_, err = store.db.Exec("INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, 1001, 'owner', 32)", datasetId)
+	require.NoError(t, err)
+
+	_, err = store.MarkDatasetForDeletion(ctx, datasetId)
+	require.NoError(t, err)
+
+	// The files of a batch are kept if its objects cannot be deleted.
+	_, err = store.PurgeDataset(ctx, int64(orgId), datasetId, 2, func(ctx context.Context, objects []S3Object) error {
+		return errors.New("unavailable")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 4, countRows(t, store.db, "files"))
+
+	var deletedObjects []S3Object
+	result, err := store.PurgeDataset(ctx, int64(orgId), datasetId, 2, func(ctx context.Context, objects []S3Object) error {
+		// Each batch is deleted before its files are committed, so the files are still visible here.
+		var count int
+		require.NoError(t, store.db.QueryRow("SELECT COUNT(*) FROM files WHERE s3_key = $1", objects[0].Key).Scan(&count))
+		assert.Equal(t, 1, count)
+		deletedObjects = append(deletedObjects, objects...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Packages)
+	assert.Equal(t, int64(3), result.Files)
+	assert.Equal(t, int64(3072), result.Size)
+	assert.Equal(t, int64(2), result.Objects)
+	assert.ElementsMatch(t, []S3Object{
+		{Bucket: "test-bucket", Key: "purge/a.edf"},
+		{Bucket: "test-bucket", Key: "purge/b.edf"},
+	}, deletedObjects)
+
+	_, err = store.GetDatasetById(ctx, datasetId)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+	for _, table := range []string{"packages", "dataset_user"} {
+		var count int
+		require.NoError(t, store.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE dataset_id = $1", table), datasetId).Scan(&count))
+		assert.Zero(t, count, table)
+	}
+	assert.Equal(t, 1, countRows(t, store.db, "files"))
+
+	orgSize, err := store.GetOrganizationStorageById(ctx, int64(orgId))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), orgSize)
+}
+
+func testPurgeDatasetNotMarked(t *testing.T, store *SQLStore, orgId int) {
+	ctx := context.Background()
+	datasetId := addTestDataset(store.db, "Test Dataset - PurgeDatasetNotMarked")
+	defer store.db.Exec("DELETE FROM datasets WHERE id = $1", datasetId)
+
+	_, err := store.PurgeDataset(ctx, int64(orgId), datasetId, 0, func(ctx context.Context, objects []S3Object) error {
+		return nil
+	})
+	assert.IsType(t, InvalidDatasetPurgeError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+
+	_, err = store.MarkDatasetForDeletion(ctx, datasetId)
+	require.NoError(t, err)
+	_, err = store.PurgeDataset(ctx, int64(orgId), datasetId, 0, nil)
+	assert.IsType(t, InvalidDatasetPurgeError{}, err)
+
+	_, err = store.GetDatasetById(ctx, datasetId)
+	assert.NoError(t, err)
+}
+
+type fakeS3 struct {
+	requests []*s3.DeleteObjectsInput
+	failKey  string
+	err      error
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	f.requests = append(f.requests, params)
+	if f.err != nil {
+		return nil, f.err
+	}
+	output := &s3.DeleteObjectsOutput{}
+	for _, o := range params.Delete.Objects {
+		if aws.ToString(o.Key) == f.failKey {
+			output.Errors = append(output.Errors, types.Error{Key: o.Key, Message: aws.String("AccessDenied")})
+		}
+	}
+	return output, nil
+}
+
+func testDeleteS3Objects(t *testing.T, store *SQLStore, orgId int) {
+	ctx := context.Background()
+	var objects []S3Object
+	for i := 0; i < 2500; i++ {
+		objects = append(objects, S3Object{Bucket: "bucket-a", Key: fmt.Sprintf("key-%d", i)})
+	}
+	objects = append(objects, S3Object{Bucket: "bucket-b", Key: "key-b"})
+
+	client := &fakeS3{failKey: "key-42"}
+	failed, err := DeleteS3Objects(ctx, client, objects)
+	require.NoError(t, err)
+	assert.Equal(t, []S3Object{{Bucket: "bucket-a", Key: "key-42"}}, failed)
+	if assert.Len(t, client.requests, 4) {
+		assert.Len(t, client.requests[0].Delete.Objects, 1000)
+		assert.Len(t, client.requests[2].Delete.Objects, 500)
+		assert.Equal(t, "bucket-b", aws.ToString(client.requests[3].Bucket))
+	}
+
+	client = &fakeS3{err: errors.New("unavailable")}
+	failed, err = DeleteS3Objects(ctx, client, objects)
+	assert.Error(t, err)
+	assert.Len(t, failed, len(objects))
+
+	assert.NoError(t, S3ObjectDeleter(&fakeS3{})(ctx, objects))
+	assert.Error(t, S3ObjectDeleter(&fakeS3{failKey: "key-42"})(ctx, objects), "objects that were not deleted fail the batch")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 )
 
@@ -15,6 +16,11 @@ func (e DatasetReleaseNotFoundError) Error() string {
 	return fmt.Sprintf("dataset release was not found (error: %v)", e.ErrorMessage)
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (e DatasetReleaseNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 func (q *Queries) AddDatasetRelease(ctx context.Context, release pgdb.DatasetRelease) (*pgdb.DatasetRelease, error) {
 	statement := "INSERT INTO dataset_release " +
 		"(dataset_id, origin, url, label, marker, release_date, release_status, publishing_status) " +
@@ -43,7 +49,7 @@ func (q *Queries) AddDatasetRelease(ctx context.Context, release pgdb.DatasetRel
 	).Scan(&id)
 
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on insert: %v", err))
+		return nil, mapError(fmt.Errorf("database error on insert: %w", err))
 	}
 
 	return q.GetDatasetReleaseById(ctx, id)
@@ -61,29 +67,28 @@ func (q *Queries) UpdateDatasetRelease(ctx context.Context, release pgdb.Dataset
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on update: %v", err))
+		return nil, mapError(fmt.Errorf("database error on update: %w", err))
 	}
 
 	return q.GetDatasetReleaseById(ctx, release.Id)
 }
 
 func (q *Queries) GetDatasetReleaseById(ctx context.Context, id int64) (*pgdb.DatasetRelease, error) {
-	predicate := fmt.Sprintf("id = %d", id)
-	return q.getDatasetRelease(ctx, predicate)
+	return q.getDatasetRelease(ctx, "id = $1", id)
 }
 
 func (q *Queries) GetDatasetRelease(ctx context.Context, datasetId int64, label string, marker string) (*pgdb.DatasetRelease, error) {
-	predicate := fmt.Sprintf("dataset_id = %d AND label = '%s' AND marker = '%s'", datasetId, label, marker)
-	return q.getDatasetRelease(ctx, predicate)
+	return q.getDatasetRelease(ctx, "dataset_id = $1 AND label = $2 AND marker = $3", datasetId, label, marker)
 }
 
-func (q *Queries) getDatasetRelease(ctx context.Context, predicate string) (*pgdb.DatasetRelease, error) {
+// getDatasetRelease returns the dataset release matching predicate. The values of the predicate must be passed as args.
+func (q *Queries) getDatasetRelease(ctx context.Context, predicate string, args ...any) (*pgdb.DatasetRelease, error) {
 	query := fmt.Sprintf(
 		"SELECT id, dataset_id, origin, url, label, marker, release_date, release_status, publishing_status, created_at, updated_at "+
 			"FROM dataset_release WHERE %s;", predicate)
 
 	var datasetRelease pgdb.DatasetRelease
-	row := q.db.QueryRowContext(ctx, query)
+	row := q.db.QueryRowContext(ctx, query, args...)
 	err := row.Scan(
 		&datasetRelease.Id,
 		&datasetRelease.DatasetId,
@@ -100,9 +105,9 @@ func (q *Queries) getDatasetRelease(ctx context.Context, predicate string) (*pgd
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, DatasetReleaseNotFoundError{fmt.Sprintf("dataset release not found where %s", predicate)}
+			return nil, DatasetReleaseNotFoundError{fmt.Sprintf("dataset release not found where %s %v", predicate, args)}
 		} else {
-			return nil, fmt.Errorf(fmt.Sprintf("database error on query: %v", err))
+			return nil, mapError(fmt.Errorf("database error on query: %w", err))
 		}
 	}
 
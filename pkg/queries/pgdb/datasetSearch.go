@@ -0,0 +1,209 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/datasetType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// DatasetSortField is the field used to order the results of SearchDatasets.
+type DatasetSortField string
+
+const (
+	DatasetSortByName      DatasetSortField = "name"
+	DatasetSortBySize      DatasetSortField = "size"
+	DatasetSortByCreatedAt DatasetSortField = "created_at"
+	DatasetSortByUpdatedAt DatasetSortField = "updated_at"
+)
+
+const (
+	defaultDatasetSearchLimit = 25
+	maxDatasetSearchLimit     = 500
+)
+
+// datasetSortExpressions maps each sort field onto the SQL expression used for ordering.
+var datasetSortExpressions = map[DatasetSortField]string{
+	DatasetSortByName:      "d.name",
+	DatasetSortBySize:      "COALESCE(d.size, 0)",
+	DatasetSortByCreatedAt: "d.created_at",
+	DatasetSortByUpdatedAt: "d.updated_at",
+}
+
+// SearchDatasetsParams is the input for SearchDatasets. Filters that are empty match all datasets.
+type SearchDatasetsParams struct {
+	Name              string // Case-insensitive substring of the dataset name.
+	Tag               string // Matches datasets with the tag, compared after NormalizeTag.
+	Types             []datasetType.DatasetType
+	StatusIds         []int32
+	License           string
+	OwnerId           int64 // 0 matches all owners.
+	UpdatedSince      time.Time
+	SortBy            DatasetSortField // Defaults to DatasetSortByName.
+	Descending        bool
+	Limit             int    // Defaults to 25, capped at 500.
+	ContinuationToken string // Token returned by the previous page, empty for the first page.
+	// VisibleToUserId restricts the results to datasets the user has a role on, either through the dataset
+	// role, a team, or directly. 0 returns all datasets.
+	VisibleToUserId int64
+}
+
+// SearchDatasetsResponse is a single page of SearchDatasets results.
+type SearchDatasetsResponse struct {
+	Datasets          []pgdb.Dataset `json:"datasets"`
+	ContinuationToken string         `json:"continuation_token"` // Empty if this is the last page.
+}
+
+// SearchDatasets returns a page of the datasets of the organization, filtered and ordered by the
+// requested fields. Datasets that are being deleted are never returned.
+// Pages are keyed on the sort value and dataset id of the last returned dataset, so results stay
+// consistent while datasets are added or removed.
+func (q *Queries) SearchDatasets(ctx context.Context, params SearchDatasetsParams) (*SearchDatasetsResponse, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = DatasetSortByName
+	}
+	sortExpr, ok := datasetSortExpressions[sortBy]
+	if !ok {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown sort field: %s", sortBy))
+	}
+
+	page := keysetPage{
+		sortBy:     string(sortBy),
+		sortExpr:   sortExpr,
+		idExpr:     "d.id",
+		kind:       datasetSortKind(sortBy),
+		descending: params.Descending,
+		limit:      keysetLimit(params.Limit, defaultDatasetSearchLimit, maxDatasetSearchLimit),
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	predicates := []string{fmt.Sprintf("d.state <> %s", arg(state.DELETING))}
+
+	if params.Name != "" {
+		predicates = append(predicates, fmt.Sprintf("d.name ILIKE %s", arg("%"+escapeLike(params.Name)+"%")))
+	}
+
+	// Tags are compared after normalization, so legacy tags that were stored before normalization also match.
+	if tag := NormalizeTag(params.Tag); tag != "" {
+		predicates = append(predicates, fmt.Sprintf("EXISTS (SELECT 1 FROM UNNEST(d.tags) AS t WHERE %s = %s)",
+			fmt.Sprintf(normalizedTagFormat, "t"), arg(tag)))
+	}
+
+	if len(params.Types) > 0 {
+		types := make([]string, len(params.Types))
+		for i, t := range params.Types {
+			types[i] = t.String()
+		}
+		predicates = append(predicates, fmt.Sprintf("d.type = ANY(%s)", arg(pq.Array(types))))
+	}
+
+	if len(params.StatusIds) > 0 {
+		predicates = append(predicates, fmt.Sprintf("d.status_id = ANY(%s)", arg(pq.Array(params.StatusIds))))
+	}
+
+	if params.License != "" {
+		predicates = append(predicates, fmt.Sprintf("d.license = %s", arg(params.License)))
+	}
+
+	if params.OwnerId != 0 {
+		predicates = append(predicates, fmt.Sprintf("EXISTS (SELECT 1 FROM dataset_user o "+
+			"WHERE o.dataset_id = d.id AND o.user_id = %s AND o.role = %s)",
+			arg(params.OwnerId), arg(strings.ToLower(role.Owner.String()))))
+	}
+
+	if !params.UpdatedSince.IsZero() {
+		predicates = append(predicates, fmt.Sprintf("d.updated_at >= %s", arg(params.UpdatedSince)))
+	}
+
+	if params.VisibleToUserId != 0 {
+		// Same roles as GetDatasetClaim: the dataset role, the roles of the teams of the user and the user role.
+		userId := arg(params.VisibleToUserId)
+		none := arg(strings.ToLower(role.None.String()))
+		predicates = append(predicates, fmt.Sprintf("("+
+			"(d.role IS NOT NULL AND LOWER(d.role) <> %[2]s) "+
+			"OR EXISTS (SELECT 1 FROM dataset_team JOIN pennsieve.team_user ON pennsieve.team_user.team_id = dataset_team.team_id "+
+			"WHERE dataset_team.dataset_id = d.id AND pennsieve.team_user.user_id = %[1]s AND LOWER(dataset_team.role) <> %[2]s) "+
+			"OR EXISTS (SELECT 1 FROM dataset_user "+
+			"WHERE dataset_user.dataset_id = d.id AND dataset_user.user_id = %[1]s AND LOWER(dataset_user.role) <> %[2]s))",
+			userId, none))
+	}
+
+	after, err := page.after(params.ContinuationToken, arg)
+	if err != nil {
+		return nil, err
+	}
+	if after != "" {
+		predicates = append(predicates, after)
+	}
+
+	queryStr := fmt.Sprintf("SELECT %s FROM datasets d WHERE %s %s",
+		datasetColumnsWithAlias("d"), strings.Join(predicates, " AND "), page.orderBy(arg))
+
+	rows, err := q.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		log.Error("Error searching datasets: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	response := SearchDatasetsResponse{}
+	for rows.Next() {
+		ds, err := scanDataset(rows)
+		if err != nil {
+			log.Error("Error scanning dataset: ", err)
+			return nil, mapError(err)
+		}
+
+		response.Datasets = append(response.Datasets, *ds)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	if page.hasMore(len(response.Datasets)) {
+		response.Datasets = response.Datasets[:page.limit]
+		last := response.Datasets[page.limit-1]
+		var value interface{}
+		switch sortBy {
+		case DatasetSortBySize:
+			value = last.Size.Int64
+		case DatasetSortByCreatedAt:
+			value = last.CreatedAt
+		case DatasetSortByUpdatedAt:
+			value = last.UpdatedAt
+		default:
+			value = last.Name
+		}
+		response.ContinuationToken, err = page.continuationToken(last.Id, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+// datasetSortKind returns the type of the sort value of a sort field.
+func datasetSortKind(sortBy DatasetSortField) keysetValueKind {
+	switch sortBy {
+	case DatasetSortBySize:
+		return keysetInt
+	case DatasetSortByCreatedAt, DatasetSortByUpdatedAt:
+		return keysetTime
+	default:
+		return keysetString
+	}
+}
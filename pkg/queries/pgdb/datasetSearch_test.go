@@ -0,0 +1,156 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/datasetType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestDatasetSearch is the main Test Suite function for searching Datasets.
+func TestDatasetSearch(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Search datasets in pages":      testSearchDatasetsPages,
+		"Search datasets with filters":  testSearchDatasetsFilters,
+		"Search datasets visible":       testSearchDatasetsVisible,
+		"Search datasets invalid input": testSearchDatasetsInvalid,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 3
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testSearchDatasetsPages(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	for i := 0; i < 12; i++ {
+		addTestDataset(store.db, fmt.Sprintf("Search Dataset %02d", i))
+	}
+
+	var names []string
+	token := ""
+	pages := 0
+	for {
+		page, err := store.SearchDatasets(ctx, SearchDatasetsParams{
+			SortBy:            DatasetSortByName,
+			Descending:        true,
+			Limit:             5,
+			ContinuationToken: token,
+		})
+		require.NoError(t, err)
+		pages++
+		for _, ds := range page.Datasets {
+			names = append(names, ds.Name)
+		}
+		token = page.ContinuationToken
+		if token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, 3, pages)
+	if assert.Len(t, names, 12) {
+		assert.Equal(t, "Search Dataset 11", names[0])
+		assert.Equal(t, "Search Dataset 00", names[11])
+	}
+}
+
+func testSearchDatasetsFilters(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	tagged := addTestDataset(store.db, "Search Filters - Mouse Brain")
+	licensed := addTestDataset(store.db, "Search Filters - Human Heart")
+	owned := addTestDataset(store.db, "Search Filters - 100%_Mouse")
+
+	_, err := store.db.Exec("UPDATE datasets SET tags = '{neuro,\" Mouse  Brain\"}', type = 'trial' WHERE id = $1", tagged)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET license = 'MIT', updated_at = $1 WHERE id = $2",
+		time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC), licensed)
+	require.NoError(t, err)
+	_, err = store.db.Exec("INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, $2, 'owner', 32)", owned, 1003)
+	require.NoError(t, err)
+
+	search := func(params SearchDatasetsParams) []int64 {
+		page, err := store.SearchDatasets(ctx, params)
+		require.NoError(t, err)
+		var ids []int64
+		for _, ds := range page.Datasets {
+			ids = append(ids, ds.Id)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, []int64{tagged, owned}, search(SearchDatasetsParams{Name: "mouse"}))
+	assert.Equal(t, []int64{owned}, search(SearchDatasetsParams{Name: "100%_"}))
+	assert.Equal(t, []int64{tagged}, search(SearchDatasetsParams{Tag: "neuro"}))
+	assert.Equal(t, []int64{tagged}, search(SearchDatasetsParams{Tag: "NEURO "}))
+	assert.Equal(t, []int64{tagged}, search(SearchDatasetsParams{Tag: "mouse brain"}), "legacy tags are normalized")
+	assert.Equal(t, []int64{tagged}, search(SearchDatasetsParams{Types: []datasetType.DatasetType{datasetType.Trial}}))
+	assert.Equal(t, []int64{licensed}, search(SearchDatasetsParams{License: "MIT"}))
+	assert.Equal(t, []int64{owned}, search(SearchDatasetsParams{OwnerId: 1003}))
+	assert.ElementsMatch(t, []int64{tagged, owned}, search(SearchDatasetsParams{UpdatedSince: time.Now().Add(-time.Hour)}))
+	assert.Len(t, search(SearchDatasetsParams{StatusIds: []int32{1}}), 3)
+	assert.Empty(t, search(SearchDatasetsParams{Tag: "neuro", License: "MIT"}))
+
+	_, err = store.db.Exec("UPDATE datasets SET state = 'DELETING' WHERE id = $1", tagged)
+	require.NoError(t, err)
+	assert.Empty(t, search(SearchDatasetsParams{Tag: "neuro"}))
+}
+
+func testSearchDatasetsVisible(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	private := addTestDataset(store.db, "Search Visible - private")
+	shared := addTestDataset(store.db, "Search Visible - shared with org")
+	direct := addTestDataset(store.db, "Search Visible - user")
+	team := addTestDataset(store.db, "Search Visible - team")
+
+	_, err := store.db.Exec("UPDATE datasets SET role = NULL WHERE id IN ($1, $2, $3)", private, direct, team)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET role = 'viewer' WHERE id = $1", shared)
+	require.NoError(t, err)
+	_, err = store.db.Exec("INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, $2, 'editor', 8)", direct, 1001)
+	require.NoError(t, err)
+	_, err = store.db.Exec("INSERT INTO dataset_team (dataset_id, team_id, role, permission_bit) VALUES ($1, $2, 'viewer', 2)", team, researchTeamId)
+	require.NoError(t, err)
+
+	page, err := store.SearchDatasets(ctx, SearchDatasetsParams{VisibleToUserId: 1001})
+	require.NoError(t, err)
+	var ids []int64
+	for _, ds := range page.Datasets {
+		ids = append(ids, ds.Id)
+	}
+	assert.ElementsMatch(t, []int64{shared, direct, team}, ids)
+
+	page, err = store.SearchDatasets(ctx, SearchDatasetsParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Datasets, 4)
+}
+
+func testSearchDatasetsInvalid(t *testing.T, store *SQLStore, orgId int) {
+	ctx := context.Background()
+
+	_, err := store.SearchDatasets(ctx, SearchDatasetsParams{SortBy: "owner"})
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+
+	_, err = store.SearchDatasets(ctx, SearchDatasetsParams{ContinuationToken: "not a token"})
+	assert.IsType(t, InvalidContinuationTokenError{}, err)
+
+	token, err := keysetPage{sortBy: string(DatasetSortByName)}.continuationToken(1, "a")
+	require.NoError(t, err)
+	_, err = store.SearchDatasets(ctx, SearchDatasetsParams{SortBy: DatasetSortBySize, ContinuationToken: token})
+	assert.IsType(t, InvalidContinuationTokenError{}, err)
+}
@@ -8,14 +8,18 @@ import (
 
 // GetDefaultDatasetStatus will return the default dataset status for the organization.
 // This is assumed to be the dataset status row with the lowest id number.
-// Returns (nil, sql.ErrNoRows) if no dataset status is found for the organization
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no dataset status is found for the organization.
 func (q *Queries) GetDefaultDatasetStatus(ctx context.Context, organizationId int) (*pgdb.DatasetStatus, error) {
+	datasetStatusTable, err := orgTable(int64(organizationId), "dataset_status")
+	if err != nil {
+		return nil, mapError(err)
+	}
 	query := fmt.Sprintf("SELECT id, name, display_name, original_name, color, created_at, updated_at"+
-		" FROM \"%d\".dataset_status order by id limit 1;", organizationId)
+		" FROM %s order by id limit 1;", datasetStatusTable)
 
 	row := q.db.QueryRowContext(ctx, query)
 	datasetStatus := pgdb.DatasetStatus{}
-	err := row.Scan(
+	err = row.Scan(
 		&datasetStatus.Id,
 		&datasetStatus.Name,
 		&datasetStatus.DisplayName,
@@ -25,7 +29,7 @@ func (q *Queries) GetDefaultDatasetStatus(ctx context.Context, organizationId in
 		&datasetStatus.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &datasetStatus, nil
@@ -2,6 +2,8 @@ package pgdb
 
 import (
 	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,7 +20,24 @@ func (q *Queries) IncrementDatasetStorage(ctx context.Context, datasetId int64,
 		log.Println("Error incrementing dataset size: ", err)
 	}
 
-	return err
+	return mapError(err)
+}
+
+// DecrementDatasetStorage decreases the storage associated with the provided dataset.
+// The storage never goes below zero.
+func (q *Queries) DecrementDatasetStorage(ctx context.Context, datasetId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot decrement dataset storage by a negative size: %d", size))
+	}
+
+	queryStr := "UPDATE dataset_storage SET size = GREATEST(COALESCE(size, 0) - $2, 0) WHERE dataset_id = $1"
+
+	_, err := q.db.ExecContext(ctx, queryStr, datasetId, size)
+	if err != nil {
+		log.Println("Error decrementing dataset size: ", err)
+	}
+
+	return mapError(err)
 }
 
 func (q *Queries) GetDatasetStorageById(ctx context.Context, datasetId int64) (int64, error) {
@@ -30,7 +49,7 @@ func (q *Queries) GetDatasetStorageById(ctx context.Context, datasetId int64) (i
 
 	if err != nil {
 		log.Error("unable to get dataset size", err)
-		return int64(0), err
+		return int64(0), mapError(err)
 	}
 
 	return datasetSize, nil
@@ -39,3 +39,33 @@ func TestDatasetStorage(t *testing.T) {
 	assert.Equal(t, int64(10), actualSize, "Size is expected to be 10")
 
 }
+
+func TestDecrementDatasetStorage(t *testing.T) {
+
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+	datasetId := int64(1)
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+
+	err := store.IncrementDatasetStorage(context.Background(), datasetId, 10)
+	assert.NoError(t, err)
+
+	// Removing 4
+	err = store.DecrementDatasetStorage(context.Background(), datasetId, 4)
+	assert.NoError(t, err)
+
+	actualSize, err := store.GetDatasetStorageById(context.Background(), datasetId)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), actualSize, "Size is expected to be 6")
+
+	// Removing more than is left
+	err = store.DecrementDatasetStorage(context.Background(), datasetId, 100)
+	assert.NoError(t, err)
+
+	actualSize, err = store.GetDatasetStorageById(context.Background(), datasetId)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), actualSize, "Size is expected to stop at 0")
+
+	err = store.DecrementDatasetStorage(context.Background(), datasetId, -1)
+	assert.Error(t, err)
+}
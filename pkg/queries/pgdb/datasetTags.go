@@ -0,0 +1,200 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// normalizedTagFormat is the SQL equivalent of NormalizeTag for the tag expression in the format argument.
+const normalizedTagFormat = `LOWER(BTRIM(REGEXP_REPLACE(%s, '\s+', ' ', 'g')))`
+
+// TagCount is an entry of the tag catalog of an organization.
+type TagCount struct {
+	Name         string `json:"name"`
+	DatasetCount int64  `json:"dataset_count"`
+}
+
+// NormalizeTag returns the canonical form of a tag: lower case, without leading or trailing whitespace, and
+// with every run of whitespace replaced by a single space.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// NormalizeTags normalizes each tag with NormalizeTag, and drops empty and duplicate tags.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		n := NormalizeTag(tag)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	return normalized
+}
+
+// AddDatasetTags adds the normalized tags to a dataset, after its existing tags. Tags that the dataset already
+// has are ignored. The tags are read and written in a single statement, so concurrent calls do not lose tags.
+// It returns the dataset and an AddTag changelog event for each added tag. If no tag is added, the etag of
+// the dataset does not change.
+func (q *Queries) AddDatasetTags(ctx context.Context, datasetId int64, tags []string) (*pgdb.Dataset, []changelog.Event, error) {
+	tags = NormalizeTags(tags)
+	newTags := fmt.Sprintf("existing.tags || ARRAY(SELECT t FROM UNNEST($2::text[]) WITH ORDINALITY AS u(t, ord) "+
+		"WHERE t <> ALL(SELECT %s FROM UNNEST(existing.tags) AS c) ORDER BY ord)", fmt.Sprintf(normalizedTagFormat, "c"))
+	return q.updateDatasetTags(ctx, datasetId, newTags, tags)
+}
+
+// RemoveDatasetTags removes the tags from a dataset. Tags are compared after normalization, and tags that the
+// dataset does not have are ignored.
+// It returns the dataset and a RemoveTag changelog event for each removed tag. If no tag is removed, the etag
+// of the dataset does not change.
+func (q *Queries) RemoveDatasetTags(ctx context.Context, datasetId int64, tags []string) (*pgdb.Dataset, []changelog.Event, error) {
+	tags = NormalizeTags(tags)
+	newTags := fmt.Sprintf("ARRAY(SELECT c FROM UNNEST(existing.tags) WITH ORDINALITY AS u(c, ord) "+
+		"WHERE %s <> ALL($2::text[]) ORDER BY ord)", fmt.Sprintf(normalizedTagFormat, "c"))
+	return q.updateDatasetTags(ctx, datasetId, newTags, tags)
+}
+
+// GetTagCatalog returns every tag used by the datasets of the organization, with the number of datasets that
+// use it, ordered by descending count and name. Tags are normalized, and datasets that are being deleted are
+// not counted.
+func (q *Queries) GetTagCatalog(ctx context.Context) ([]TagCount, error) {
+	queryStr := fmt.Sprintf("SELECT tag, COUNT(DISTINCT d.id) FROM datasets d, "+
+		"LATERAL (SELECT %s AS tag FROM UNNEST(d.tags) AS t) AS n "+
+		"WHERE d.state <> $1 AND tag <> '' GROUP BY tag ORDER BY COUNT(DISTINCT d.id) DESC, tag",
+		fmt.Sprintf(normalizedTagFormat, "t"))
+
+	rows, err := q.db.QueryContext(ctx, queryStr, state.DELETING)
+	if err != nil {
+		log.Error("Error getting tag catalog: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	catalog := []TagCount{}
+	for rows.Next() {
+		var tagCount TagCount
+		if err := rows.Scan(&tagCount.Name, &tagCount.DatasetCount); err != nil {
+			return nil, mapError(err)
+		}
+		catalog = append(catalog, tagCount)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return catalog, nil
+}
+
+// RenameTag renames a tag on every dataset of the organization, see MergeTags.
+func (q *Queries) RenameTag(ctx context.Context, from string, to string) (map[int64][]changelog.Event, error) {
+	return q.MergeTags(ctx, []string{from}, to)
+}
+
+// MergeTags replaces the tags in sources with target on every dataset of the organization. Tags are compared
+// after normalization, and a dataset that ends up with target twice keeps it once, in its first position.
+// All datasets are changed in a single statement, so either every dataset is changed or none is.
+// It returns the changelog events of each changed dataset, by dataset id.
+func (q *Queries) MergeTags(ctx context.Context, sources []string, target string) (map[int64][]changelog.Event, error) {
+	target = NormalizeTag(target)
+	if target == "" {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "tag cannot be empty")
+	}
+	sources = NormalizeTags(sources)
+
+	normalized := fmt.Sprintf(normalizedTagFormat, "t")
+	queryStr := fmt.Sprintf("WITH existing AS ("+
+		"SELECT id, tags FROM datasets WHERE EXISTS (SELECT 1 FROM UNNEST(tags) AS t WHERE %[1]s = ANY($1::text[])) FOR UPDATE), "+
+		"merged AS (SELECT id, tags, ARRAY("+
+		"SELECT tag FROM (SELECT DISTINCT ON (%[2]s) tag, ord FROM ("+
+		"SELECT CASE WHEN %[1]s = ANY($1::text[]) THEN $2 ELSE t END AS tag, ord "+
+		"FROM UNNEST(existing.tags) WITH ORDINALITY AS u(t, ord)) AS r ORDER BY %[2]s, ord) AS s ORDER BY ord"+
+		") AS new_tags FROM existing) "+
+		"UPDATE datasets d SET tags = merged.new_tags, updated_at = $3, etag = $3 "+
+		"FROM merged WHERE d.id = merged.id AND merged.tags <> merged.new_tags "+
+		"RETURNING d.id, merged.tags, d.tags", normalized, fmt.Sprintf(normalizedTagFormat, "tag"))
+
+	currentTime := time.Now()
+	rows, err := q.db.QueryContext(ctx, queryStr, pq.Array(sources), target, currentTime)
+	if err != nil {
+		log.Error("Error merging tags: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	events := make(map[int64][]changelog.Event)
+	for rows.Next() {
+		var datasetId int64
+		var oldTags, newTags pgdb.Tags
+		if err := rows.Scan(&datasetId, &oldTags, &newTags); err != nil {
+			return nil, mapError(err)
+		}
+		events[datasetId] = tagEvents(oldTags, newTags, currentTime)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return events, nil
+}
+
+// updateDatasetTags sets the tags of a dataset to the newTags SQL expression, which can use existing.tags
+// for the existing tags and $2 for tags.
+func (q *Queries) updateDatasetTags(ctx context.Context, datasetId int64, newTags string, tags []string) (*pgdb.Dataset, []changelog.Event, error) {
+	queryStr := fmt.Sprintf("WITH existing AS (SELECT id, COALESCE(tags, '{}') AS tags FROM datasets WHERE id = $1 FOR UPDATE), "+
+		"updated AS (SELECT id, tags, %s AS new_tags FROM existing) "+
+		"UPDATE datasets d SET tags = updated.new_tags, updated_at = $3, etag = $3 "+
+		"FROM updated WHERE d.id = updated.id AND updated.tags <> updated.new_tags "+
+		"RETURNING %s, updated.tags", newTags, datasetColumnsWithAlias("d"))
+
+	currentTime := time.Now()
+	var oldTags pgdb.Tags
+	row := q.db.QueryRowContext(ctx, queryStr, datasetId, pq.Array(tags), currentTime)
+	ds, err := scanDataset(withExtraColumns{row, []interface{}{&oldTags}})
+	if err != nil {
+		if _, ok := err.(DatasetNotFoundError); ok {
+			// Nothing changed, or the dataset does not exist.
+			ds, err = q.GetDatasetById(ctx, datasetId)
+			if err != nil {
+				return nil, nil, mapError(err)
+			}
+			return ds, nil, nil
+		}
+		log.Error("Error updating dataset tags: ", err)
+		return nil, nil, mapError(err)
+	}
+
+	return ds, tagEvents(oldTags, ds.Tags, currentTime), nil
+}
+
+// tagEvents returns an AddTag event for each tag of updated that is not in original, and a RemoveTag event
+// for each tag of original that is not in updated.
+func tagEvents(original []string, updated []string, timestamp time.Time) []changelog.Event {
+	var events []changelog.Event
+	added, removed := diffStrings(original, updated)
+	for _, tag := range added {
+		events = append(events, changelog.Event{EventType: changelog.AddTag, EventDetail: changelog.DatasetTagEvent{Name: tag}, Timestamp: timestamp})
+	}
+	for _, tag := range removed {
+		events = append(events, changelog.Event{EventType: changelog.RemoveTag, EventDetail: changelog.DatasetTagEvent{Name: tag}, Timestamp: timestamp})
+	}
+	return events
+}
+
+// withExtraColumns scans the columns that follow the columns of a scan function into extra.
+type withExtraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (w withExtraColumns) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.extra...)...)
+}
@@ -0,0 +1,128 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestDatasetTags is the main Test Suite function for the tags of Datasets.
+func TestDatasetTags(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Add and remove dataset tags": testAddRemoveDatasetTags,
+		"Get tag catalog":             testGetTagCatalog,
+		"Rename and merge tags":       testMergeTags,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 3
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, "mouse brain", NormalizeTag("  Mouse \t Brain\n"))
+	assert.Equal(t, []string{"eeg", "mouse brain"}, NormalizeTags([]string{"EEG", " ", "mouse  brain", "eeg"}))
+}
+
+func testAddRemoveDatasetTags(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - AddRemoveDatasetTags"))
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET tags = '{Legacy Tag}' WHERE id = $1", ds.Id)
+	require.NoError(t, err)
+
+	updated, events, err := store.AddDatasetTags(ctx, ds.Id, []string{" EEG ", "legacy  tag", "eeg", "Sleep"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Legacy Tag", "eeg", "sleep"}, []string(updated.Tags))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, changelog.AddTag, events[0].EventType)
+		assert.Equal(t, changelog.DatasetTagEvent{Name: "eeg"}, events[0].EventDetail)
+	}
+	assert.True(t, updated.ETag.After(ds.ETag))
+
+	unchanged, events, err := store.AddDatasetTags(ctx, ds.Id, []string{"Sleep"})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.True(t, unchanged.ETag.Equal(updated.ETag))
+
+	updated, events, err = store.RemoveDatasetTags(ctx, ds.Id, []string{"LEGACY TAG", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eeg", "sleep"}, []string(updated.Tags))
+	assert.Equal(t, []changelog.Event{{
+		EventType:   changelog.RemoveTag,
+		EventDetail: changelog.DatasetTagEvent{Name: "Legacy Tag"},
+		Timestamp:   events[0].Timestamp,
+	}}, events)
+
+	_, _, err = store.AddDatasetTags(ctx, 999999, []string{"eeg"})
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
+
+func testGetTagCatalog(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	first := addTestDataset(store.db, "Test Dataset - GetTagCatalog 1")
+	second := addTestDataset(store.db, "Test Dataset - GetTagCatalog 2")
+	deleting := addTestDataset(store.db, "Test Dataset - GetTagCatalog 3")
+	_, err := store.db.Exec("UPDATE datasets SET tags = '{eeg,Mouse}' WHERE id = $1", first)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET tags = '{mouse,\" MOUSE\"}' WHERE id = $1", second)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET tags = '{eeg}', state = 'DELETING' WHERE id = $1", deleting)
+	require.NoError(t, err)
+
+	catalog, err := store.GetTagCatalog(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []TagCount{
+		{Name: "mouse", DatasetCount: 2},
+		{Name: "eeg", DatasetCount: 1},
+	}, catalog)
+}
+
+func testMergeTags(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	first := addTestDataset(store.db, "Test Dataset - MergeTags 1")
+	second := addTestDataset(store.db, "Test Dataset - MergeTags 2")
+	untouched := addTestDataset(store.db, "Test Dataset - MergeTags 3")
+	_, err := store.db.Exec("UPDATE datasets SET tags = '{EEG,sleep,electroencephalography}' WHERE id = $1", first)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET tags = '{mouse,Electroencephalography}' WHERE id = $1", second)
+	require.NoError(t, err)
+	_, err = store.db.Exec("UPDATE datasets SET tags = '{mouse}' WHERE id = $1", untouched)
+	require.NoError(t, err)
+
+	events, err := store.MergeTags(ctx, []string{"eeg", "electroencephalography"}, "EEG")
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	ds, err := store.GetDatasetById(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eeg", "sleep"}, []string(ds.Tags))
+	ds, err = store.GetDatasetById(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mouse", "eeg"}, []string(ds.Tags))
+	assert.Len(t, events[second], 2)
+
+	events, err = store.RenameTag(ctx, "mouse", "rodent")
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+	ds, err = store.GetDatasetById(ctx, untouched)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rodent"}, []string(ds.Tags))
+
+	_, err = store.RenameTag(ctx, "rodent", "  ")
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+}
@@ -0,0 +1,240 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// DatasetETagConflictError is returned by UpdateDataset if the dataset changed after the caller read its ETag.
+type DatasetETagConflictError struct {
+	ErrorMessage string
+}
+
+func (e DatasetETagConflictError) Error() string {
+	return fmt.Sprintf("dataset was changed by another request (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrConflict.
+func (e DatasetETagConflictError) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
+
+// UpdateDatasetParams are the fields changed by UpdateDataset. Fields that are nil are not changed.
+type UpdateDatasetParams struct {
+	Name        *string
+	Description *string
+	// License is set to NULL if it is empty.
+	License *string
+	// Tags are normalized with NormalizeTags.
+	Tags         *[]string
+	Contributors *[]string
+	StatusId     *int32
+	// DataUseAgreementId is set to NULL if it is not Valid.
+	DataUseAgreementId           *sql.NullInt32
+	AutomaticallyProcessPackages *bool
+}
+
+// UpdateDataset changes the fields of a dataset that are set in p.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - Datasets that are being deleted are not changed, a DatasetNotFoundError is returned as if the dataset
+//     did not exist.
+//   - The dataset is only changed if its ETag is still etag, otherwise a DatasetETagConflictError is returned.
+//   - The updated_at and etag of the dataset are set to the current time. If no field changes, the dataset
+//     is returned as is.
+//
+// It returns the updated dataset and a changelog event for each change.
+func (q *Queries) UpdateDataset(ctx context.Context, datasetId int64, etag time.Time, p UpdateDatasetParams) (*pgdb.Dataset, []changelog.Event, error) {
+	current, err := q.getDataset(ctx, "id=$1 FOR UPDATE", datasetId)
+	if err != nil {
+		return nil, nil, mapError(err)
+	}
+	// The row lock keeps MarkDatasetForDeletion from changing the state until the update commits.
+	if current.State == state.DELETING {
+		return nil, nil, DatasetNotFoundError{fmt.Sprintf("dataset %d is being deleted", datasetId)}
+	}
+	if !current.ETag.Equal(etag) {
+		return nil, nil, DatasetETagConflictError{fmt.Sprintf("dataset %d has etag %v, not %v", datasetId, current.ETag, etag)}
+	}
+
+	currentTime := time.Now()
+	var sets []string
+	var args []any
+	var events []changelog.Event
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+	addEvent := func(eventType changelog.Type, detail any) {
+		events = append(events, changelog.Event{EventType: eventType, EventDetail: detail, Timestamp: currentTime})
+	}
+
+	if p.Name != nil && *p.Name != current.Name {
+		if err := q.validateDatasetName(ctx, datasetId, *p.Name); err != nil {
+			return nil, nil, err
+		}
+		set("name", *p.Name)
+		addEvent(changelog.UpdateName, changelog.DatasetUpdateNameEvent{OldName: current.Name, NewName: *p.Name})
+	}
+
+	if p.Description != nil && *p.Description != current.Description.String {
+		set("description", *p.Description)
+		addEvent(changelog.UpdateDescription, changelog.DatasetUpdateDescriptionEvent{
+			OldDescription: current.Description.String,
+			NewDescription: *p.Description,
+		})
+	}
+
+	if p.License != nil && *p.License != current.License.String {
+		set("license", sql.NullString{String: *p.License, Valid: *p.License != ""})
+		addEvent(changelog.UpdateLicense, changelog.DatasetUpdateLicenseEvent{
+			OldLicense: current.License.String,
+			NewLicense: *p.License,
+		})
+	}
+
+	if p.Tags != nil {
+		if tags := NormalizeTags(*p.Tags); !equalStrings(current.Tags, tags) {
+			set("tags", pgdb.Tags(tags))
+			events = append(events, tagEvents(current.Tags, tags, currentTime)...)
+		}
+	}
+
+	if p.Contributors != nil && !equalStrings(current.Contributors, *p.Contributors) {
+		set("contributors", pgdb.Contributors(*p.Contributors))
+		added, removed := diffStrings(current.Contributors, *p.Contributors)
+		for _, contributor := range added {
+			addEvent(changelog.AddContributor, changelog.DatasetContributorEvent{Name: contributor})
+		}
+		for _, contributor := range removed {
+			addEvent(changelog.RemoveContributor, changelog.DatasetContributorEvent{Name: contributor})
+		}
+	}
+
+	if p.StatusId != nil && *p.StatusId != current.StatusId {
+		set("status_id", *p.StatusId)
+		addEvent(changelog.UpdateStatus, changelog.DatasetUpdateStatusEvent{
+			OldStatusId: current.StatusId,
+			NewStatusId: *p.StatusId,
+		})
+	}
+
+	if p.DataUseAgreementId != nil && !equalNullInt32(current.DataUseAgreementId, *p.DataUseAgreementId) {
+		set("data_use_agreement_id", *p.DataUseAgreementId)
+		addEvent(changelog.UpdateDataUseAgreement, changelog.DatasetUpdateDataUseAgreementEvent{
+			OldDataUseAgreementId: nullInt32Ptr(current.DataUseAgreementId),
+			NewDataUseAgreementId: nullInt32Ptr(*p.DataUseAgreementId),
+		})
+	}
+
+	if p.AutomaticallyProcessPackages != nil && *p.AutomaticallyProcessPackages != current.AutomaticallyProcessPackages {
+		set("automatically_process_packages", *p.AutomaticallyProcessPackages)
+		addEvent(changelog.UpdateAutomaticallyProcessPackages, changelog.DatasetUpdateAutomaticallyProcessPackagesEvent{
+			OldValue: current.AutomaticallyProcessPackages,
+			NewValue: *p.AutomaticallyProcessPackages,
+		})
+	}
+
+	if len(sets) == 0 {
+		return current, nil, nil
+	}
+
+	set("updated_at", currentTime)
+	set("etag", currentTime)
+	args = append(args, datasetId, etag)
+	queryStr := fmt.Sprintf("UPDATE datasets SET %s WHERE id=$%d AND etag=$%d RETURNING %s",
+		strings.Join(sets, ", "), len(args)-1, len(args), datasetColumns)
+
+	updated, err := scanDataset(q.db.QueryRowContext(ctx, queryStr, args...))
+	if err != nil {
+		// Without a transaction, the dataset can change between the select and the update.
+		if _, ok := err.(DatasetNotFoundError); ok {
+			return nil, nil, DatasetETagConflictError{fmt.Sprintf("dataset %d no longer has etag %v", datasetId, etag)}
+		}
+		log.Error("Error updating dataset: ", err)
+		return nil, nil, mapError(err)
+	}
+
+	return updated, events, nil
+}
+
+// validateDatasetName checks that name can be used as the name of the dataset with the given id.
+func (q *Queries) validateDatasetName(ctx context.Context, datasetId int64, name string) error {
+	if name == "" {
+		return dbErrors.New(dbErrors.ErrInvalid, "dataset name cannot be empty or null")
+	}
+	if len(name) > 255 {
+		return dbErrors.New(dbErrors.ErrInvalid, "dataset name cannot exceed 255 characters")
+	}
+
+	existing, err := q.GetDatasetByName(ctx, name)
+	if err != nil {
+		switch err.(type) {
+		case DatasetNotFoundError:
+			return nil
+		default:
+			return mapError(err)
+		}
+	}
+	if existing.Id != datasetId {
+		return dbErrors.New(dbErrors.ErrConflict, fmt.Sprintf("a dataset with the name %q already exists", name))
+	}
+	return nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffStrings returns the values of updated that are not in original, and the values of original that are
+// not in updated, in the order in which they appear.
+func diffStrings(original []string, updated []string) (added []string, removed []string) {
+	inOriginal := make(map[string]bool, len(original))
+	for _, v := range original {
+		inOriginal[v] = true
+	}
+	inUpdated := make(map[string]bool, len(updated))
+	for _, v := range updated {
+		inUpdated[v] = true
+	}
+
+	for _, v := range updated {
+		if !inOriginal[v] {
+			added = append(added, v)
+			inOriginal[v] = true
+		}
+	}
+	for _, v := range original {
+		if !inUpdated[v] {
+			removed = append(removed, v)
+			inUpdated[v] = true
+		}
+	}
+	return added, removed
+}
+
+func equalNullInt32(a sql.NullInt32, b sql.NullInt32) bool {
+	return a.Valid == b.Valid && (!a.Valid || a.Int32 == b.Int32)
+}
+
+func nullInt32Ptr(v sql.NullInt32) *int32 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int32
+}
@@ -0,0 +1,189 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestDatasetUpdate is the main Test Suite function for updating Datasets.
+func TestDatasetUpdate(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Update dataset":                 testUpdateDataset,
+		"Update dataset tags":            testUpdateDatasetTags,
+		"Update dataset with stale etag": testUpdateDatasetStaleETag,
+		"Update dataset without changes": testUpdateDatasetNoChanges,
+		"Update dataset with taken name": testUpdateDatasetNameConflict,
+		"Update missing dataset":         testUpdateDatasetNotFound,
+		"Update deleting dataset":        testUpdateDatasetDeleting,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 3
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testUpdateDataset(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - UpdateDataset"))
+	require.NoError(t, err)
+	dataUseAgreement, err := store.GetDefaultDataUseAgreement(ctx, orgId)
+	require.NoError(t, err)
+
+	name := "Test Dataset - UpdateDataset renamed"
+	description := "new description"
+	license := "MIT"
+	process := !ds.AutomaticallyProcessPackages
+	dataUseAgreementId := sql.NullInt32{Int32: int32(dataUseAgreement.Id), Valid: true}
+	updated, events, err := store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{
+		Name:                         &name,
+		Description:                  &description,
+		License:                      &license,
+		DataUseAgreementId:           &dataUseAgreementId,
+		AutomaticallyProcessPackages: &process,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, name, updated.Name)
+	assert.Equal(t, description, updated.Description.String)
+	assert.Equal(t, license, updated.License.String)
+	assert.Equal(t, dataUseAgreementId, updated.DataUseAgreementId)
+	assert.Equal(t, process, updated.AutomaticallyProcessPackages)
+	assert.True(t, updated.ETag.After(ds.ETag))
+	assert.True(t, updated.UpdatedAt.After(ds.UpdatedAt))
+
+	var eventTypes []changelog.Type
+	for _, event := range events {
+		eventTypes = append(eventTypes, event.EventType)
+	}
+	assert.Equal(t, []changelog.Type{
+		changelog.UpdateName,
+		changelog.UpdateDescription,
+		changelog.UpdateLicense,
+		changelog.UpdateDataUseAgreement,
+		changelog.UpdateAutomaticallyProcessPackages,
+	}, eventTypes)
+	assert.Equal(t, changelog.DatasetUpdateNameEvent{OldName: ds.Name, NewName: name}, events[0].EventDetail)
+
+	// Clearing the license sets it to NULL.
+	empty := ""
+	cleared, events, err := store.UpdateDataset(ctx, ds.Id, updated.ETag, UpdateDatasetParams{License: &empty})
+	require.NoError(t, err)
+	assert.False(t, cleared.License.Valid)
+	assert.Len(t, events, 1)
+}
+
+func testUpdateDatasetTags(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - UpdateDatasetTags"))
+	require.NoError(t, err)
+
+	tags := []string{"a", "b"}
+	contributors := []string{"Jane Doe"}
+	ds, events, err := store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Tags: &tags, Contributors: &contributors})
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	tags = []string{"b", "c"}
+	ds, events, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Tags: &tags})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, []string(ds.Tags))
+	assert.Equal(t, []string{"Jane Doe"}, []string(ds.Contributors))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, changelog.AddTag, events[0].EventType)
+		assert.Equal(t, changelog.DatasetTagEvent{Name: "c"}, events[0].EventDetail)
+		assert.Equal(t, changelog.RemoveTag, events[1].EventType)
+		assert.Equal(t, changelog.DatasetTagEvent{Name: "a"}, events[1].EventDetail)
+	}
+}
+
+func testUpdateDatasetStaleETag(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - UpdateDatasetStaleETag"))
+	require.NoError(t, err)
+
+	description := "first"
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Description: &description})
+	require.NoError(t, err)
+
+	description = "second"
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Description: &description})
+	assert.IsType(t, DatasetETagConflictError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+
+	current, err := store.GetDatasetById(ctx, ds.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "first", current.Description.String)
+}
+
+func testUpdateDatasetNoChanges(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - UpdateDatasetNoChanges"))
+	require.NoError(t, err)
+
+	name := ds.Name
+	updated, events, err := store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Name: &name})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.True(t, updated.ETag.Equal(ds.ETag))
+
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag.Add(-time.Second), UpdateDatasetParams{})
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+}
+
+func testUpdateDatasetNameConflict(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	addTestDataset(store.db, "Test Dataset - UpdateDatasetNameConflict taken")
+	ds, err := store.GetDatasetById(ctx, addTestDataset(store.db, "Test Dataset - UpdateDatasetNameConflict"))
+	require.NoError(t, err)
+
+	name := "Test Dataset - UpdateDatasetNameConflict taken"
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Name: &name})
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+
+	name = ""
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Name: &name})
+	assert.ErrorIs(t, err, dbErrors.ErrInvalid)
+}
+
+func testUpdateDatasetNotFound(t *testing.T, store *SQLStore, orgId int) {
+	description := "description"
+	_, _, err := store.UpdateDataset(context.Background(), 999999, time.Now(), UpdateDatasetParams{Description: &description})
+	assert.IsType(t, DatasetNotFoundError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+}
+
+func testUpdateDatasetDeleting(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "datasets")
+
+	ctx := context.Background()
+	ds, err := store.MarkDatasetForDeletion(ctx, addTestDataset(store.db, "Test Dataset - UpdateDatasetDeleting"))
+	require.NoError(t, err)
+
+	description := "description"
+	_, _, err = store.UpdateDataset(ctx, ds.Id, ds.ETag, UpdateDatasetParams{Description: &description})
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+
+	current, err := store.GetDatasetById(ctx, ds.Id)
+	require.NoError(t, err)
+	assert.Empty(t, current.Description.String)
+}
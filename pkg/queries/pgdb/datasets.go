@@ -7,6 +7,7 @@ import (
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/datasetType"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset/state"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/nodeId"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/role"
@@ -24,6 +25,11 @@ func (e DatasetNotFoundError) Error() string {
 	return fmt.Sprintf("dataset was not found (error: %v)", e.ErrorMessage)
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (e DatasetNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 type DatasetUserNotFoundError struct {
 	ErrorMessage string
 }
@@ -32,6 +38,11 @@ func (e DatasetUserNotFoundError) Error() string {
 	return fmt.Sprintf("dataset user was not found (error: %v)", e.ErrorMessage)
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (e DatasetUserNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 type CreateDatasetParams struct {
 	Name                         string
 	Description                  string
@@ -56,11 +67,11 @@ func (q *Queries) CreateDataset(ctx context.Context, p CreateDatasetParams) (*pg
 	var err error
 
 	if p.Name == "" {
-		return nil, fmt.Errorf("dataset name cannot be empty or null")
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "dataset name cannot be empty or null")
 	}
 
 	if len(p.Name) > 255 {
-		return nil, fmt.Errorf("dataset name cannot exceed 255 characters")
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "dataset name cannot exceed 255 characters")
 	}
 
 	_, err = q.GetDatasetByName(ctx, p.Name)
@@ -86,42 +97,45 @@ func (q *Queries) CreateDataset(ctx context.Context, p CreateDatasetParams) (*pg
 		p.AutomaticallyProcessPackages,
 		p.Status.Id,
 		p.License,
-		fmt.Sprintf("{%s}", strings.Join(p.Tags, ",")),
+		pgdb.Tags(NormalizeTags(p.Tags)),
 		p.DataUseAgreement.Id,
 		p.Type.String())
 
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on insert: %v", err))
+		return nil, mapError(fmt.Errorf("database error on insert: %w", err))
 	}
 
 	dataset, err := q.GetDatasetByName(ctx, p.Name)
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on query: %v", err))
+		return nil, mapError(fmt.Errorf("database error on query: %w", err))
 	}
 
 	return dataset, nil
 }
 
-// GetDatasetById will query workspace datasets by name and return one if found.
+// GetDatasetById will query workspace datasets by id and return one if found.
+// Unlike the other getters, it also returns datasets that are being deleted.
 func (q *Queries) GetDatasetById(ctx context.Context, id int64) (*pgdb.Dataset, error) {
-	return q.getDataset(ctx, fmt.Sprintf("id=%d", id))
+	return q.getDataset(ctx, "id=$1", id)
 }
 
-// GetDatasetByNodeId will query workspace datasets by name and return one if found.
+// GetDatasetByNodeId will query workspace datasets by node id and return one if found.
+// Datasets that are being deleted are not found.
 func (q *Queries) GetDatasetByNodeId(ctx context.Context, nodeId string) (*pgdb.Dataset, error) {
-	return q.getDataset(ctx, fmt.Sprintf("node_id='%s'", nodeId))
+	return q.getDataset(ctx, "node_id=$1 AND state<>$2", nodeId, state.DELETING)
 }
 
 // GetDatasetByName will query workspace datasets by name and return one if found.
 func (q *Queries) GetDatasetByName(ctx context.Context, name string) (*pgdb.Dataset, error) {
-	return q.getDataset(ctx, fmt.Sprintf("name='%s'", name))
+	return q.getDataset(ctx, "name=$1", name)
 }
 
-// GetDatasets returns all rows in the Upload Record Table
+// GetDatasets returns all rows in the Upload Record Table, except the datasets that are being deleted.
+// Use SearchDatasets to filter, sort and page through the datasets.
 func (q *Queries) GetDatasets(ctx context.Context, organizationId int) ([]pgdb.Dataset, error) {
-	queryStr := "SELECT (name, state) FROM datasets"
+	queryStr := "SELECT (name, state) FROM datasets WHERE state<>$1"
 
-	rows, err := q.db.QueryContext(ctx, queryStr)
+	rows, err := q.db.QueryContext(ctx, queryStr, state.DELETING)
 	var allDatasets []pgdb.Dataset
 	if err == nil {
 		for rows.Next() {
@@ -136,14 +150,15 @@ func (q *Queries) GetDatasets(ctx context.Context, organizationId int) ([]pgdb.D
 
 			allDatasets = append(allDatasets, currentRecord)
 		}
-		return allDatasets, err
+		return allDatasets, mapError(err)
 	}
-	return allDatasets, err
+	return allDatasets, mapError(err)
 }
 
 // GetDatasetClaim returns the highest role that the user has for a given dataset.
 // This method checks the roles of the dataset, the teams, and the specific user roles.
-// returns (nil, sql.ErrNoRows) if no dataset with the given nodeId is found
+// returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no dataset with the given nodeId is found,
+// or if the dataset is being deleted.
 func (q *Queries) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string, organizationId int64) (*dataset.Claim, error) {
 
 	// if user is super-admin
@@ -156,18 +171,22 @@ func (q *Queries) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetN
 	}
 
 	// 1. Get Dataset Role and integer ID
-	datasetQuery := fmt.Sprintf("SELECT id, role FROM \"%d\".datasets WHERE node_id='%s';", organizationId, datasetNodeId)
+	datasets, err := orgTable(organizationId, "datasets")
+	if err != nil {
+		return nil, mapError(err)
+	}
+	datasetQuery := fmt.Sprintf("SELECT id, role FROM %s WHERE node_id=$1 AND state<>$2;", datasets)
 
 	var datasetId int64
 	var maybeDatasetRole sql.NullString
 
-	row := q.db.QueryRowContext(ctx, datasetQuery)
-	err := row.Scan(
+	row := q.db.QueryRowContext(ctx, datasetQuery, datasetNodeId, state.DELETING)
+	err = row.Scan(
 		&datasetId,
 		&maybeDatasetRole)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	// If maybeDatasetRole is set, include the role, otherwise use none-role
@@ -181,24 +200,27 @@ func (q *Queries) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetN
 	}
 
 	// 2. Get Team Role
-	teamPermission := fmt.Sprintf("\"%d\".dataset_team.role", organizationId)
-	datasetTeam := fmt.Sprintf("\"%d\".dataset_team", organizationId)
-	teamQueryStr := fmt.Sprintf("SELECT %s FROM pennsieve.team_user JOIN %s "+
-		"ON pennsieve.team_user.team_id = %s.team_id "+
-		"WHERE user_id=%d AND dataset_id=%d", teamPermission, datasetTeam, datasetTeam, user.Id, datasetId)
+	datasetTeam, err := orgTable(organizationId, "dataset_team")
+	if err != nil {
+		return nil, mapError(err)
+	}
+	teamQueryStr := fmt.Sprintf("SELECT %[1]s.role FROM pennsieve.team_user JOIN %[1]s "+
+		"ON pennsieve.team_user.team_id = %[1]s.team_id "+
+		"WHERE user_id=$1 AND dataset_id=$2", datasetTeam)
 
 	// Get User Role
-	userPermission := fmt.Sprintf("\"%d\".dataset_user.role", organizationId)
-	datasetUser := fmt.Sprintf("\"%d\".dataset_user", organizationId)
-	userQueryStr := fmt.Sprintf("SELECT %s FROM %s WHERE user_id=%d AND dataset_id=%d",
-		userPermission, datasetUser, user.Id, datasetId)
+	datasetUser, err := orgTable(organizationId, "dataset_user")
+	if err != nil {
+		return nil, mapError(err)
+	}
+	userQueryStr := fmt.Sprintf("SELECT %[1]s.role FROM %[1]s WHERE user_id=$1 AND dataset_id=$2", datasetUser)
 
 	// Combine all queries in a single Union.
 	fullQuery := teamQueryStr + " UNION " + userQueryStr + ";"
 
-	rows, err := q.db.QueryContext(ctx, fullQuery)
+	rows, err := q.db.QueryContext(ctx, fullQuery, user.Id, datasetId)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	roles := []role.Role{
@@ -237,29 +259,7 @@ func (q *Queries) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetN
 }
 
 func (q *Queries) GetDatasetUser(ctx context.Context, dataset *pgdb.Dataset, user *pgdb.User) (*pgdb.DatasetUser, error) {
-	query := "SELECT dataset_id, user_id, role, permission_bit, created_at, updated_at FROM dataset_user WHERE dataset_id=$1 AND user_id=$2"
-
-	var datasetUser pgdb.DatasetUser
-
-	err := q.db.QueryRowContext(ctx, query, dataset.Id, user.Id).Scan(
-		&datasetUser.DatasetId,
-		&datasetUser.UserId,
-		&datasetUser.Role,
-		&datasetUser.PermissionBit,
-		&datasetUser.CreatedAt,
-		&datasetUser.UpdatedAt,
-	)
-
-	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			return nil, DatasetUserNotFoundError{fmt.Sprintf("%+v", err)}
-		default:
-			return nil, err
-		}
-	}
-
-	return &datasetUser, nil
+	return q.getDatasetUser(ctx, dataset.Id, user.Id)
 }
 
 func (q *Queries) AddDatasetUser(ctx context.Context, dataset *pgdb.Dataset, user *pgdb.User, role role.Role) (*pgdb.DatasetUser, error) {
@@ -269,7 +269,7 @@ func (q *Queries) AddDatasetUser(ctx context.Context, dataset *pgdb.Dataset, use
 		case DatasetUserNotFoundError:
 			// do nothing
 		default:
-			return nil, err
+			return nil, mapError(err)
 		}
 	}
 
@@ -277,10 +277,25 @@ func (q *Queries) AddDatasetUser(ctx context.Context, dataset *pgdb.Dataset, use
 		return existing, nil
 	}
 
+	// A dataset has exactly one owner, use TransferOwnership to change it.
+	if datasetRoleToPermission(role) == pgdb.Owner {
+		ownerId, err := q.getDatasetOwnerId(ctx, dataset.Id)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		if ownerId != 0 {
+			return nil, DatasetOwnershipError{fmt.Sprintf("dataset %d is already owned by user %d", dataset.Id, ownerId)}
+		}
+	}
+
 	statement := "INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit) VALUES ($1, $2, $3, $4)"
 	_, err = q.db.ExecContext(ctx, statement, dataset.Id, user.Id, strings.ToLower(role.String()), datasetRoleToPermission(role))
 	if err != nil {
-		return nil, err
+		// A concurrent call can add an owner after the check above.
+		if isSingleOwnerViolation(err) {
+			return nil, DatasetOwnershipError{fmt.Sprintf("dataset %d is already owned by another user", dataset.Id)}
+		}
+		return nil, mapError(err)
 	}
 
 	return q.GetDatasetUser(ctx, dataset, user)
@@ -294,12 +309,12 @@ func (q *Queries) SetUpdatedAt(ctx context.Context, datasetId int64, t time.Time
 	if err != nil {
 		msg = fmt.Sprintf("Error updating the updated_at column: %v", err)
 		log.Println(msg)
-		return err
+		return mapError(err)
 	}
 
 	affectedRows, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 	if affectedRows != 1 {
 		if affectedRows == 0 {
@@ -334,16 +349,33 @@ func datasetRoleToPermission(r role.Role) pgdb.DbPermission {
 	}
 }
 
-func (q *Queries) getDataset(ctx context.Context, predicate string) (*pgdb.Dataset, error) {
-	query := fmt.Sprintf("SELECT id, name, state, description, updated_at, created_at, node_id,"+
-		" permission_bit, type, role, status, automatically_process_packages, license, tags, contributors,"+
-		" banner_id, readme_id, status_id, size, etag, data_use_agreement_id, changelog_id"+
-		" FROM datasets WHERE %s;", predicate)
-	row := q.db.QueryRowContext(ctx, query)
+// datasetColumnsFormat lists the columns of the datasets table read by scanDataset, in scan order.
+// The format argument is the (possibly empty) table alias prefix.
+const datasetColumnsFormat = "%[1]sid, %[1]sname, %[1]sstate, %[1]sdescription, %[1]supdated_at, %[1]screated_at, " +
+	"%[1]snode_id, %[1]spermission_bit, %[1]stype, %[1]srole, %[1]sstatus, %[1]sautomatically_process_packages, " +
+	"%[1]slicense, %[1]stags, %[1]scontributors, %[1]sbanner_id, %[1]sreadme_id, %[1]sstatus_id, %[1]ssize, " +
+	"%[1]setag, %[1]sdata_use_agreement_id, %[1]schangelog_id"
+
+// datasetColumns are the unqualified columns read by scanDataset.
+var datasetColumns = datasetColumnsWithAlias("")
+
+// datasetColumnsWithAlias returns the columns read by scanDataset, qualified by the given table alias.
+func datasetColumnsWithAlias(alias string) string {
+	if alias == "" {
+		return fmt.Sprintf(datasetColumnsFormat, "")
+	}
+	return fmt.Sprintf(datasetColumnsFormat, alias+".")
+}
+
+// getDataset returns the dataset matching predicate. The values of the predicate must be passed as args.
+func (q *Queries) getDataset(ctx context.Context, predicate string, args ...any) (*pgdb.Dataset, error) {
+	query := fmt.Sprintf("SELECT %s FROM datasets WHERE %s;", datasetColumns, predicate)
+	row := q.db.QueryRowContext(ctx, query, args...)
 	return scanDataset(row)
 }
 
-func scanDataset(row *sql.Row) (*pgdb.Dataset, error) {
+// scanDataset scans a row selected with datasetColumns into a Dataset.
+func scanDataset(row rowScanner) (*pgdb.Dataset, error) {
 	var dataset pgdb.Dataset
 
 	err := row.Scan(
@@ -376,7 +408,7 @@ func scanDataset(row *sql.Row) (*pgdb.Dataset, error) {
 		case sql.ErrNoRows:
 			return nil, DatasetNotFoundError{"No rows were returned!"}
 		default:
-			return nil, err
+			return nil, mapError(err)
 		}
 	}
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -49,10 +50,14 @@ func (q *Queries) ShowSearchPath(loc string) {
 	fmt.Printf("%s: Search Path: %v\n", loc, currentSchema)
 }
 
+// WithOrg sets the search_path of the underlying connection to the organization schema.
+//
+// Deprecated: On a *sql.DB, the search_path is only set on one pooled connection, and it stays set
+// for every later user of that connection. Use SQLStore.OrgConn or SQLStore.ExecOrgTx instead.
 func (q *Queries) WithOrg(orgId int) (*Queries, error) {
 	err := setOrgSearchPath(q.db, orgId)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &Queries{
@@ -70,9 +75,18 @@ func (q *Queries) WithOrg(orgId int) (*Queries, error) {
 //
 // If ENV is set to DOCKER, the call is redirected to ConnectENV()
 func ConnectRDS() (*sql.DB, error) {
+	return ConnectRDSWithConfig(ConnectionConfig{})
+}
+
+// ConnectRDSWithConfig returns a DB instance like ConnectRDS, with the pool configured by connCfg.
+// Every new connection is opened with an RDS IAM auth token that is refreshed before it expires,
+// so the DB instance can be used for longer than the 15 minute token lifetime.
+//
+// If ENV is set to DOCKER, the call is redirected to ConnectENVWithConfig()
+func ConnectRDSWithConfig(connCfg ConnectionConfig) (*sql.DB, error) {
 	DOCKER_ENV := "DOCKER"
 	if getEnv("ENV", DOCKER_ENV) == DOCKER_ENV {
-		return ConnectENV()
+		return ConnectENVWithConfig(connCfg)
 	}
 
 	var dbName string = "pennsieve_postgres"
@@ -87,25 +101,36 @@ func ConnectRDS() (*sql.DB, error) {
 		return nil, fmt.Errorf("error loading AWS config: %w", err)
 	}
 
-	authenticationToken, err := auth.BuildAuthToken(
-		context.TODO(), dbEndpoint, region, dbUser, cfg.Credentials)
-	if err != nil {
-		return nil, fmt.Errorf("error building RDS auth token: %w", err)
+	tokens := newAuthTokenSource(func(ctx context.Context) (string, error) {
+		return auth.BuildAuthToken(ctx, dbEndpoint, region, dbUser, cfg.Credentials)
+	})
+	// Build the first token now, so credential errors are returned here instead of on first use.
+	if _, err := tokens.Token(context.TODO()); err != nil {
+		return nil, mapError(err)
 	}
 
-	return connect(dbHost, strconv.Itoa(dbPort), dbUser, authenticationToken, dbName, "")
+	connector := &rdsConnector{
+		dsn: func(token string) string {
+			return buildDSN(dbHost, strconv.Itoa(dbPort), dbUser, token, dbName, "", connCfg)
+		},
+		tokens: tokens,
+	}
+	return configurePool(sql.OpenDB(connector), connCfg)
 }
 
 // ConnectRDSWithOrg returns a DB instance.
 // The Lambda function leverages IAM roles to gain access to the DB Proxy.
 // The function DOES set the search_path to the organization schema.
+//
+// Deprecated: The search_path is only set on one pooled connection. Use ConnectRDS with
+// SQLStore.OrgConn or SQLStore.ExecOrgTx instead.
 func ConnectRDSWithOrg(orgId int) (*sql.DB, error) {
 	db, err := ConnectRDS()
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	err = setOrgSearchPath(db, orgId)
-	return db, err
+	return db, mapError(err)
 }
 
 // ConnectENV returns a DB instance. Used for testing, it requires the
@@ -117,6 +142,11 @@ func ConnectRDSWithOrg(orgId int) (*sql.DB, error) {
 // - PENNSIEVE_DB
 // - POSTGRES_SSL_MODE (should be set to "disable" if the server is not https, left blank if it is)
 func ConnectENV() (*sql.DB, error) {
+	return ConnectENVWithConfig(ConnectionConfig{})
+}
+
+// ConnectENVWithConfig returns a DB instance like ConnectENV, with the pool configured by connCfg.
+func ConnectENVWithConfig(connCfg ConnectionConfig) (*sql.DB, error) {
 	host := getEnv("POSTGRES_HOST", "localhost")
 	port := getEnv("POSTGRES_PORT", "5432")
 	user := getEnv("POSTGRES_USER", "postgres")
@@ -124,7 +154,7 @@ func ConnectENV() (*sql.DB, error) {
 	dbName := getEnv("PENNSIEVE_DB", "postgres")
 	sslMode := getEnv("POSTGRES_SSL_MODE", "disable")
 
-	return connect(host, port, user, password, dbName, sslMode)
+	return connect(host, port, user, password, dbName, sslMode, connCfg)
 }
 
 func getEnv(key, fallback string) string {
@@ -135,45 +165,48 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
+// ConnectENVWithOrg returns a DB instance like ConnectENV, and sets the search_path to the organization schema.
+//
+// Deprecated: The search_path is only set on one pooled connection. Use ConnectENV with
+// SQLStore.OrgConn or SQLStore.ExecOrgTx instead.
 func ConnectENVWithOrg(orgId int) (*sql.DB, error) {
 	db, err := ConnectENV()
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	err = setOrgSearchPath(db, orgId)
-	return db, err
+	return db, mapError(err)
 }
 
-func connect(dbHost string, dbPort string, dbUser string, authenticationToken string, dbName string, sslMode string) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s",
-		dbHost, dbPort, dbUser, authenticationToken, dbName,
-	)
-	if sslMode != "" {
-		dsn = fmt.Sprintf("%s sslmode=%s", dsn, sslMode)
-	}
+func connect(dbHost string, dbPort string, dbUser string, authenticationToken string, dbName string, sslMode string, connCfg ConnectionConfig) (*sql.DB, error) {
+	dsn := buildDSN(dbHost, dbPort, dbUser, authenticationToken, dbName, sslMode, connCfg)
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("error opening DB connection: %w", err)
 	}
 
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("error pinging DB connection: %w", err)
-	}
-
-	return db, err
+	return configurePool(db, connCfg)
 }
 
 func setOrgSearchPath(db DBTX, orgId int) error {
 
 	// Set Search Path to organization
 	ctx := context.Background()
-	_, err := db.ExecContext(ctx, fmt.Sprintf("SET search_path = \"%d\";", orgId))
+	schema, err := orgSchema(int64(orgId))
+	if err != nil {
+		return mapError(err)
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("SET search_path = %s;", schema))
 	if err != nil {
 		log.Error(fmt.Sprintf("Unable to set search_path to %d.", orgId))
-		return err
+		return mapError(err)
 	}
 
-	return err
+	return nil
+}
+
+// mapError returns err as an error of the matching dbErrors kind. See dbErrors.FromPostgres.
+func mapError(err error) error {
+	return dbErrors.FromPostgres(err)
 }
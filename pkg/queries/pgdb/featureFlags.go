@@ -13,7 +13,7 @@ const featureFlagBaseQuery = "SELECT organization_id, feature, enabled,created_a
 func queryFeatureFlags(ctx context.Context, db DBTX, query string, params ...any) ([]pgdb.FeatureFlags, error) {
 	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
-		return nil, fmt.Errorf("error getting feature flags with query %s: %w", query, err)
+		return nil, mapError(fmt.Errorf("error getting feature flags with query %s: %w", query, err))
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -30,15 +30,15 @@ func queryFeatureFlags(ctx context.Context, db DBTX, query string, params ...any
 			&currentRecord.Enabled,
 			&currentRecord.CreatedAt,
 			&currentRecord.UpdatedAt); err != nil {
-			return nil, fmt.Errorf("error scanning feature flag rows with query %s: %w", query, err)
+			return nil, mapError(fmt.Errorf("error scanning feature flag rows with query %s: %w", query, err))
 		}
 
 		featureFlags = append(featureFlags, currentRecord)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error during feature flag row iteration with query %s: %w", query, err)
+		return nil, mapError(fmt.Errorf("error during feature flag row iteration with query %s: %w", query, err))
 	}
-	return featureFlags, err
+	return featureFlags, mapError(err)
 
 }
 
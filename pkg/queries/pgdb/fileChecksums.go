@@ -0,0 +1,64 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// checksumDocument reads the checksum column as jsonb. Legacy values that are not a JSON object are read as null.
+const checksumDocument = "(CASE WHEN f.checksum::text LIKE '{%' THEN f.checksum::jsonb END)"
+
+// VerifyFileChecksum compares the actual checksum of a file with its recorded checksum and
+// stores the result in the checksum column of the file.
+//   - This call should typically be wrapped in a Transaction as it reads and updates the file.
+//   - A file without a recorded checksum for the algorithm fails verification.
+//
+// Returns ErrFileNotFound if the file does not exist.
+func (q *Queries) VerifyFileChecksum(ctx context.Context, fileUUID uuid.UUID, algorithm pgdb.ChecksumAlgorithm, actual string) (*pgdb.ChecksumVerification, error) {
+	var value sql.NullString
+	err := q.db.QueryRowContext(ctx, "SELECT checksum FROM files WHERE uuid=$1 FOR UPDATE", fileUUID.String()).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &pgdb.ErrFileNotFound{}
+		}
+		log.Error("Error reading file checksum: ", err)
+		return nil, mapError(err)
+	}
+
+	checksum, err := pgdb.ParseFileChecksum(value.String)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	verification, err := checksum.Verify(algorithm, actual, time.Now())
+	if err != nil {
+		return nil, mapError(err)
+	}
+	checksum.Verification = verification
+
+	_, err = q.db.ExecContext(ctx, "UPDATE files SET checksum=$1 WHERE uuid=$2", checksum.String(), fileUUID.String())
+	if err != nil {
+		log.Error("Error recording checksum verification: ", err)
+		return nil, mapError(err)
+	}
+	return verification, nil
+}
+
+// GetUnverifiedFiles returns the files in a dataset whose checksum verification failed or never ran.
+// Files of deleted packages are not included. Use pgdb.ParseFileChecksum on the CheckSum of the
+// returned files to read the last verification result.
+func (q *Queries) GetUnverifiedFiles(ctx context.Context, datasetId int64) ([]pgdb.File, error) {
+	queryStr := fmt.Sprintf("SELECT %s FROM files f JOIN packages p ON p.id = f.package_id "+
+		"WHERE p.dataset_id = $1 AND p.state NOT IN ($2, $3) "+
+		"AND COALESCE(%s -> 'verification' ->> 'verified', 'false') <> 'true' "+
+		"ORDER BY f.id", fileColumnsWithAlias("f"), checksumDocument)
+
+	return q.queryFiles(ctx, queryStr, datasetId, packageState.Deleting.String(), packageState.Deleted.String())
+}
@@ -0,0 +1,114 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/objectType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestFileChecksums is the main Test Suite function for file checksum verification.
+func TestFileChecksums(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Verify file checksum": testVerifyFileChecksum,
+		"Get unverified files": testGetUnverifiedFiles,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func addTestChecksumFiles(t *testing.T, store *SQLStore, md5s ...string) []pgdb.File {
+	packages, err := store.AddPackages(context.Background(), test.GenerateTestPackages([]test.PackageParams{
+		{Name: "test-package", ParentId: -1},
+	}, 1))
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting package", err)
+	}
+
+	var params []pgdb.FileParams
+	for _, md5 := range md5s {
+		params = append(params, pgdb.FileParams{
+			PackageId:  int(packages[0].Id),
+			Name:       md5,
+			FileType:   fileType.EDF,
+			S3Bucket:   "test-bucket",
+			S3Key:      md5,
+			ObjectType: objectType.Source,
+			Size:       1024,
+			CheckSum:   md5,
+			Sha256:     md5 + "-sha",
+			UUID:       uuid.New(),
+		})
+	}
+	files, err := store.AddFiles(context.Background(), params)
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting files", err)
+	}
+	return files
+}
+
+func testVerifyFileChecksum(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	ctx := context.Background()
+	files := addTestChecksumFiles(t, store, "abc")
+
+	checksum, err := pgdb.ParseFileChecksum(files[0].CheckSum)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "abc", checksum.MD5)
+		assert.Equal(t, "abc-sha", checksum.SHA256)
+		assert.Nil(t, checksum.Verification)
+	}
+
+	verification, err := store.VerifyFileChecksum(ctx, files[0].UUID, pgdb.SHA256, "abc-sha")
+	if assert.NoError(t, err) {
+		assert.True(t, verification.Verified)
+	}
+
+	f, err := store.GetFileByUUID(ctx, files[0].UUID)
+	if assert.NoError(t, err) {
+		checksum, err = pgdb.ParseFileChecksum(f.CheckSum)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", checksum.MD5, "recorded checksums should be preserved")
+		if assert.NotNil(t, checksum.Verification) {
+			assert.Equal(t, pgdb.SHA256, checksum.Verification.Algorithm)
+			assert.True(t, checksum.Verification.Verified)
+		}
+	}
+
+	_, err = store.VerifyFileChecksum(ctx, uuid.New(), pgdb.MD5, "abc")
+	assert.ErrorAs(t, err, new(*pgdb.ErrFileNotFound))
+}
+
+func testGetUnverifiedFiles(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	ctx := context.Background()
+	files := addTestChecksumFiles(t, store, "verified", "failed", "never")
+
+	_, err := store.VerifyFileChecksum(ctx, files[0].UUID, pgdb.MD5, "verified")
+	assert.NoError(t, err)
+	_, err = store.VerifyFileChecksum(ctx, files[1].UUID, pgdb.MD5, "corrupted")
+	assert.NoError(t, err)
+
+	unverified, err := store.GetUnverifiedFiles(ctx, 1)
+	if assert.NoError(t, err) {
+		var names []string
+		for _, f := range unverified {
+			names = append(names, f.Name)
+		}
+		assert.ElementsMatch(t, []string{"failed", "never"}, names)
+	}
+}
@@ -0,0 +1,226 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/processingState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/uploadState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// completablePackageStates are the package states from which a package automatically moves to
+// Ready once all of its files are uploaded and processed.
+var completablePackageStates = []packageState.State{packageState.Uploaded, packageState.Processing}
+
+type IllegalFileStateTransitionError struct {
+	ErrorMessage string
+}
+
+func (e IllegalFileStateTransitionError) Error() string {
+	return fmt.Sprintf("illegal file state transition (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e IllegalFileStateTransitionError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// FileStateUpdateResult is the result of a file processing or upload state update.
+type FileStateUpdateResult struct {
+	Files             []pgdb.File    // Files that moved to the new state.
+	Skipped           []uuid.UUID    // Requested files that do not exist or cannot move to the new state.
+	CompletedPackages []pgdb.Package // Packages that moved to Ready as a result of the update.
+}
+
+// SetFileProcessingState moves a file to a new processing state.
+// Returns ErrFileNotFound if the file does not exist and an IllegalFileStateTransitionError
+// if the file cannot move to the new state. See SetFileProcessingStates.
+func (q *Queries) SetFileProcessingState(ctx context.Context, fileUUID uuid.UUID, state processingState.ProcessingState) (*FileStateUpdateResult, error) {
+	result, err := q.SetFileProcessingStates(ctx, []uuid.UUID{fileUUID}, state)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(result.Files) == 0 {
+		return nil, q.fileStateTransitionError(ctx, fileUUID, fmt.Sprintf("processing state %s", state))
+	}
+	return result, nil
+}
+
+// SetFileProcessingStates moves a set of files, selected by uuid, to a new processing state.
+//   - Files that do not exist or cannot move to the new state are left untouched and reported as skipped.
+//   - Packages whose files are now all uploaded and processed move to Ready. Files that are not processable,
+//     such as files of unsupported types, count as processed.
+func (q *Queries) SetFileProcessingStates(ctx context.Context, fileUUIDs []uuid.UUID, state processingState.ProcessingState) (*FileStateUpdateResult, error) {
+	return q.setFileStates(ctx, "processing_state", state.String(), processingStateStrings(state),
+		"uuid = ANY($3::uuid[])", pq.Array(uuidStrings(fileUUIDs)), fileUUIDs)
+}
+
+// SetPackageFileProcessingState moves all files of a package to a new processing state.
+// Files that cannot move to the new state are left untouched. See SetFileProcessingStates.
+func (q *Queries) SetPackageFileProcessingState(ctx context.Context, packageId int64, state processingState.ProcessingState) (*FileStateUpdateResult, error) {
+	return q.setFileStates(ctx, "processing_state", state.String(), processingStateStrings(state),
+		"package_id = $3", packageId, nil)
+}
+
+// SetFileUploadState moves a file to a new upload state.
+// Returns ErrFileNotFound if the file does not exist and an IllegalFileStateTransitionError
+// if the file cannot move to the new state. See SetFileUploadStates.
+func (q *Queries) SetFileUploadState(ctx context.Context, fileUUID uuid.UUID, state uploadState.UploadedState) (*FileStateUpdateResult, error) {
+	result, err := q.SetFileUploadStates(ctx, []uuid.UUID{fileUUID}, state)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(result.Files) == 0 {
+		return nil, q.fileStateTransitionError(ctx, fileUUID, fmt.Sprintf("upload state %s", state))
+	}
+	return result, nil
+}
+
+// SetFileUploadStates moves a set of files, selected by uuid, to a new upload state.
+//   - Files that do not exist or cannot move to the new state are left untouched and reported as skipped.
+//   - Packages whose files are now all uploaded and processed move to Ready. Files that are not processable,
+//     such as files of unsupported types, count as processed.
+func (q *Queries) SetFileUploadStates(ctx context.Context, fileUUIDs []uuid.UUID, state uploadState.UploadedState) (*FileStateUpdateResult, error) {
+	return q.setFileStates(ctx, "uploaded_state", state.String(), uploadStateStrings(state),
+		"uuid = ANY($3::uuid[])", pq.Array(uuidStrings(fileUUIDs)), fileUUIDs)
+}
+
+// SetPackageFileUploadState moves all files of a package to a new upload state.
+// Files that cannot move to the new state are left untouched. See SetFileUploadStates.
+func (q *Queries) SetPackageFileUploadState(ctx context.Context, packageId int64, state uploadState.UploadedState) (*FileStateUpdateResult, error) {
+	return q.setFileStates(ctx, "uploaded_state", state.String(), uploadStateStrings(state),
+		"package_id = $3", packageId, nil)
+}
+
+// setFileStates sets a state column of the selected files to the target value, if the current
+// value is one of the provided previous states, and then completes the affected packages.
+// requested is used to report skipped files and can be nil.
+func (q *Queries) setFileStates(ctx context.Context, column string, target string, previous []string,
+	selector string, selectorArg interface{}, requested []uuid.UUID) (*FileStateUpdateResult, error) {
+
+	result := FileStateUpdateResult{}
+	if len(previous) == 0 {
+		result.Skipped = requested
+		return &result, nil
+	}
+
+	queryStr := fmt.Sprintf("UPDATE files SET %[1]s=$1, updated_at=$2 WHERE %[2]s AND %[1]s = ANY($4) RETURNING %[3]s",
+		column, selector, fileColumns)
+	files, err := q.queryFiles(ctx, queryStr, target, time.Now(), selectorArg, pq.Array(previous))
+	if err != nil {
+		log.Error("Error updating file states: ", err)
+		return nil, mapError(err)
+	}
+	result.Files = files
+
+	updated := map[uuid.UUID]bool{}
+	var packageIds []int64
+	seenPackages := map[int64]bool{}
+	for _, f := range files {
+		updated[f.UUID] = true
+		if !seenPackages[int64(f.PackageId)] {
+			seenPackages[int64(f.PackageId)] = true
+			packageIds = append(packageIds, int64(f.PackageId))
+		}
+	}
+	for _, id := range requested {
+		if !updated[id] {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+
+	result.CompletedPackages, err = q.completePackages(ctx, packageIds)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &result, nil
+}
+
+// completePackages moves packages whose files are all uploaded and either processed or not processable to Ready.
+// Only packages in one of the completablePackageStates are updated.
+func (q *Queries) completePackages(ctx context.Context, packageIds []int64) ([]pgdb.Package, error) {
+	if len(packageIds) == 0 {
+		return nil, nil
+	}
+
+	var fromStates []string
+	for _, s := range completablePackageStates {
+		fromStates = append(fromStates, s.String())
+	}
+
+	queryStr := "UPDATE packages p SET state = $1, updated_at = $2 " +
+		"FROM (SELECT package_id, " +
+		"bool_and(processing_state = ANY($3) AND uploaded_state = $4) AS complete " +
+		"FROM files WHERE package_id = ANY($5) GROUP BY package_id) f " +
+		"WHERE p.id = f.package_id AND f.complete AND p.state = ANY($6) " +
+		fmt.Sprintf("RETURNING %s", packageColumnsWithAlias("p"))
+
+	rows, err := q.db.QueryContext(ctx, queryStr,
+		packageState.Ready.String(),
+		time.Now(),
+		pq.Array([]string{processingState.Processed.String(), processingState.NotProcessable.String()}),
+		uploadState.Uploaded.String(),
+		pq.Array(packageIds),
+		pq.Array(fromStates))
+	if err != nil {
+		log.Error("Error completing packages: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var completed []pgdb.Package
+	for rows.Next() {
+		p, err := scanPackage(rows)
+		if err != nil {
+			log.Error("Error scanning package: ", err)
+			return nil, mapError(err)
+		}
+		completed = append(completed, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return completed, nil
+}
+
+// fileStateTransitionError returns ErrFileNotFound if the file does not exist and an
+// IllegalFileStateTransitionError otherwise.
+func (q *Queries) fileStateTransitionError(ctx context.Context, fileUUID uuid.UUID, target string) error {
+	f, err := q.GetFileByUUID(ctx, fileUUID)
+	if err != nil {
+		return mapError(err)
+	}
+	return IllegalFileStateTransitionError{fmt.Sprintf(
+		"file %s in processing state %s and upload state %s cannot move to %s",
+		fileUUID, f.ProcessingState, f.UploadedState, target)}
+}
+
+func processingStateStrings(target processingState.ProcessingState) []string {
+	var states []string
+	for _, s := range processingState.PreviousStates(target) {
+		states = append(states, s.String())
+	}
+	return states
+}
+
+func uploadStateStrings(target uploadState.UploadedState) []string {
+	var states []string
+	for _, s := range uploadState.PreviousStates(target) {
+		states = append(states, s.String())
+	}
+	return states
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
@@ -0,0 +1,115 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/processingState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/uploadState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestFileStates is the main Test Suite function for file processing and upload states.
+func TestFileStates(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int, packageId int,
+	){
+		"Set file processing state":           testSetFileProcessingState,
+		"Illegal file state transition":       testIllegalFileStateTransition,
+		"Package completes when files finish": testPackageCompletesWhenFilesFinish,
+		"Not processable file completes":      testNotProcessableFileCompletesPackage,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			defer test.Truncate(t, store.db, orgId, "packages")
+
+			ctx := context.Background()
+			packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+				{Name: "test-package", ParentId: -1},
+			}, 1))
+			if err != nil {
+				assert.FailNow(t, "unable to set up test; error inserting package", err)
+			}
+			_, err = store.TransitionPackageState(ctx, packages[0].Id, packageState.Unavailable, packageState.Uploaded)
+			if err != nil {
+				assert.FailNow(t, "unable to set up test; error updating package state", err)
+			}
+			_, err = store.TransitionPackageState(ctx, packages[0].Id, packageState.Uploaded, packageState.Processing)
+			if err != nil {
+				assert.FailNow(t, "unable to set up test; error updating package state", err)
+			}
+
+			fn(t, store, orgId, int(packages[0].Id))
+		})
+	}
+}
+
+func testSetFileProcessingState(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	files := addTestFiles(t, store, packageId, "a.edf", "b.edf")
+
+	result, err := store.SetFileProcessingState(context.Background(), files[0].UUID, processingState.Processed)
+	if assert.NoError(t, err) {
+		assert.Len(t, result.Files, 1)
+		assert.Equal(t, processingState.Processed, result.Files[0].ProcessingState)
+		assert.Empty(t, result.CompletedPackages, "package still has an unprocessed file")
+	}
+
+	missing := uuid.New()
+	result, err = store.SetFileProcessingStates(context.Background(), []uuid.UUID{files[0].UUID, files[1].UUID, missing}, processingState.Processed)
+	if assert.NoError(t, err) {
+		assert.Len(t, result.Files, 1)
+		assert.ElementsMatch(t, []uuid.UUID{files[0].UUID, missing}, result.Skipped)
+	}
+
+	_, err = store.SetFileProcessingState(context.Background(), missing, processingState.Processed)
+	assert.ErrorAs(t, err, new(*pgdb.ErrFileNotFound))
+}
+
+func testIllegalFileStateTransition(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	files := addTestFiles(t, store, packageId, "a.edf")
+
+	_, err := store.SetFileUploadState(context.Background(), files[0].UUID, uploadState.Pending)
+	assert.ErrorAs(t, err, &IllegalFileStateTransitionError{})
+
+	_, err = store.SetFileProcessingState(context.Background(), files[0].UUID, processingState.Unprocessed)
+	assert.ErrorAs(t, err, &IllegalFileStateTransitionError{})
+}
+
+func testPackageCompletesWhenFilesFinish(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	addTestFiles(t, store, packageId, "a.edf", "b.edf")
+
+	result, err := store.SetPackageFileProcessingState(context.Background(), int64(packageId), processingState.Processed)
+	if assert.NoError(t, err) {
+		assert.Len(t, result.Files, 2)
+		if assert.Len(t, result.CompletedPackages, 1) {
+			assert.Equal(t, packageState.Ready, result.CompletedPackages[0].PackageState)
+		}
+	}
+}
+
+func testNotProcessableFileCompletesPackage(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	// Files of unsupported types are not processed, which is not a failure.
+	files := addTestFiles(t, store, packageId, "a.pdf")
+
+	result, err := store.SetFileProcessingState(context.Background(), files[0].UUID, processingState.NotProcessable)
+	if assert.NoError(t, err) && assert.Len(t, result.CompletedPackages, 1) {
+		assert.Equal(t, packageState.Ready, result.CompletedPackages[0].PackageState)
+	}
+
+	p, err := store.GetPackageById(context.Background(), int64(packageId))
+	if assert.NoError(t, err) {
+		assert.Equal(t, packageState.Ready, p.PackageState)
+	}
+}
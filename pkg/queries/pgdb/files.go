@@ -2,8 +2,13 @@ package pgdb
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/objectType"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/processingState"
@@ -38,7 +43,7 @@ func (q *Queries) AddFiles(ctx context.Context, files []pgdb.FileParams) ([]pgdb
 			index*13+13,
 		))
 
-		etag := fmt.Sprintf("{\"checksum\": \"%s\", \"chunkSize\": \"%s\", \"sha256\": \"%s\"}", row.CheckSum, "32", row.Sha256)
+		etag := pgdb.FileChecksum{MD5: row.CheckSum, ChunkSize: "32", SHA256: row.Sha256}.String()
 
 		values = append(values, row.PackageId, row.Name, row.FileType.String(), row.S3Bucket, row.S3Key,
 			row.ObjectType.String(), row.Size, etag, row.UUID.String(), processingState.Unprocessed.String(),
@@ -49,18 +54,15 @@ func (q *Queries) AddFiles(ctx context.Context, files []pgdb.FileParams) ([]pgdb
 	sqlInsert := "INSERT INTO files(package_id, name, file_type, s3_bucket, s3_key, " +
 		"object_type, size, checksum, uuid, processing_state, uploaded_state, created_at, updated_at) VALUES "
 
-	returnRows := "id, package_id, name, file_type, s3_bucket, s3_key, " +
-		"object_type, size, checksum, uuid, processing_state, uploaded_state, created_at, updated_at"
-
 	sqlInsert = sqlInsert +
 		strings.Join(inserts, ",") +
-		fmt.Sprintf("ON CONFLICT (uuid) DO UPDATE SET updated_at = EXCLUDED.updated_at WHERE files.s3_bucket = EXCLUDED.s3_bucket AND files.s3_key = EXCLUDED.s3_key RETURNING %s;", returnRows)
+		fmt.Sprintf("ON CONFLICT (uuid) DO UPDATE SET updated_at = EXCLUDED.updated_at WHERE files.s3_bucket = EXCLUDED.s3_bucket AND files.s3_key = EXCLUDED.s3_key RETURNING %s;", fileColumns)
 
 	//prepare the statement
 	stmt, err := q.db.PrepareContext(ctx, sqlInsert)
 	if err != nil {
 		log.Error("ERROR: ", err)
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	//goland:noinspection ALL
@@ -73,45 +75,17 @@ func (q *Queries) AddFiles(ctx context.Context, files []pgdb.FileParams) ([]pgdb
 		if pqErr, ok := err.(*pq.Error); ok {
 			log.Println(pqErr)
 		}
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	for rows.Next() {
-		var currentRecord pgdb.File
-
-		var fType string
-		var oType string
-		var pState string
-		var uState string
-
-		err = rows.Scan(
-			&currentRecord.Id,
-			&currentRecord.PackageId,
-			&currentRecord.Name,
-			&fType,
-			&currentRecord.S3Bucket,
-			&currentRecord.S3Key,
-			&oType,
-			&currentRecord.Size,
-			&currentRecord.CheckSum,
-			&currentRecord.UUID,
-			&pState,
-			&uState,
-			&currentRecord.CreatedAt,
-			&currentRecord.UpdatedAt,
-		)
-
+		currentRecord, err := scanFile(rows)
 		if err != nil {
 			log.Println("ERROR: ", err)
-			return nil, err
+			return nil, mapError(err)
 		}
 
-		currentRecord.FileType = fileType.Dict[fType]
-		currentRecord.ObjectType = objectType.Dict[oType]
-		currentRecord.ProcessingState = processingState.Dict[pState]
-		currentRecord.UploadedState = uploadState.Dict[uState]
-
-		allInsertedFiles = append(allInsertedFiles, currentRecord)
+		allInsertedFiles = append(allInsertedFiles, *currentRecord)
 	}
 
 	return allInsertedFiles, nil
@@ -120,11 +94,15 @@ func (q *Queries) AddFiles(ctx context.Context, files []pgdb.FileParams) ([]pgdb
 // IsFilePublished checks whether a file identified by its UUID has been published
 // by looking for a non-null published_s3_version_id.
 func (q *Queries) IsFilePublished(ctx context.Context, uploadId string, organizationId int64) (bool, error) {
-	queryStr := fmt.Sprintf("SELECT published_s3_version_id IS NOT NULL FROM \"%d\".files WHERE UUID=$1;", organizationId)
+	files, err := orgTable(organizationId, "files")
+	if err != nil {
+		return false, mapError(err)
+	}
+	queryStr := fmt.Sprintf("SELECT published_s3_version_id IS NOT NULL FROM %s WHERE UUID=$1;", files)
 	var published bool
-	err := q.db.QueryRowContext(ctx, queryStr, uploadId).Scan(&published)
+	err = q.db.QueryRowContext(ctx, queryStr, uploadId).Scan(&published)
 	if err != nil {
-		return false, fmt.Errorf("error checking published status for file %s: %w", uploadId, err)
+		return false, mapError(fmt.Errorf("error checking published status for file %s: %w", uploadId, err))
 	}
 	return published, nil
 }
@@ -135,23 +113,27 @@ func (q *Queries) IsFilePublished(ctx context.Context, uploadId string, organiza
 // Returns ErrFileNotFound if the file does not exist.
 // Returns ErrFileAlreadyPublished if the file was published (0 rows affected due to the guard).
 func (q *Queries) UpdateBucketForUnpublishedFile(ctx context.Context, uploadId string, bucket string, s3Key string, organizationId int64) error {
-	queryStr := fmt.Sprintf("UPDATE \"%d\".files SET s3_bucket=$1, s3_key=$2 WHERE UUID=$3 AND published_s3_version_id IS NULL;", organizationId)
+	files, err := orgTable(organizationId, "files")
+	if err != nil {
+		return mapError(err)
+	}
+	queryStr := fmt.Sprintf("UPDATE %s SET s3_bucket=$1, s3_key=$2 WHERE UUID=$3 AND published_s3_version_id IS NULL;", files)
 	result, err := q.db.ExecContext(ctx, queryStr, bucket, s3Key, uploadId)
 	if err != nil {
 		log.Printf("Error updating the bucket location: %v", err)
-		return err
+		return mapError(err)
 	}
 
 	affectedRows, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 	if affectedRows == 0 {
 		// Distinguish between "file doesn't exist" and "file is published" by checking if the file exists.
 		var exists bool
-		existsQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM \"%d\".files WHERE UUID=$1);", organizationId)
+		existsQuery := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE UUID=$1);", files)
 		if err := q.db.QueryRowContext(ctx, existsQuery, uploadId).Scan(&exists); err != nil {
-			return fmt.Errorf("error checking file existence: %w", err)
+			return mapError(fmt.Errorf("error checking file existence: %w", err))
 		}
 		if exists {
 			return &pgdb.ErrFileAlreadyPublished{}
@@ -167,19 +149,23 @@ func (q *Queries) UpdateBucketForUnpublishedFile(ctx context.Context, uploadId s
 // UpdateBucketForFile updates the storage bucket as part of upload process.
 func (q *Queries) UpdateBucketForFile(ctx context.Context, uploadId string, bucket string, s3Key string, organizationId int64) error {
 
-	queryStr := fmt.Sprintf("UPDATE \"%d\".files SET s3_bucket=$1, s3_key=$2 WHERE UUID=$3;", organizationId)
+	files, err := orgTable(organizationId, "files")
+	if err != nil {
+		return mapError(err)
+	}
+	queryStr := fmt.Sprintf("UPDATE %s SET s3_bucket=$1, s3_key=$2 WHERE UUID=$3;", files)
 	result, err := q.db.ExecContext(ctx, queryStr, bucket, s3Key, uploadId)
 
 	msg := ""
 	if err != nil {
 		msg = fmt.Sprintf("Error updating the bucket location: %v", err)
 		log.Println(msg)
-		return err
+		return mapError(err)
 	}
 
 	affectedRows, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 	if affectedRows != 1 {
 		if affectedRows == 0 {
@@ -194,3 +180,193 @@ func (q *Queries) UpdateBucketForFile(ctx context.Context, uploadId string, buck
 	return nil
 
 }
+
+// GetFilesForPackage returns the files of a package.
+func (q *Queries) GetFilesForPackage(ctx context.Context, packageId int64) ([]pgdb.File, error) {
+	files, err := q.GetFilesForPackages(ctx, []int64{packageId})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return files[packageId], nil
+}
+
+// GetFilesForPackages returns the files of a set of packages, keyed by package id.
+// Packages without files are not included in the map.
+func (q *Queries) GetFilesForPackages(ctx context.Context, packageIds []int64) (map[int64][]pgdb.File, error) {
+	result := map[int64][]pgdb.File{}
+	if len(packageIds) == 0 {
+		return result, nil
+	}
+
+	queryStr := fmt.Sprintf("SELECT %s FROM files WHERE package_id = ANY($1) ORDER BY package_id, id", fileColumns)
+	files, err := q.queryFiles(ctx, queryStr, pq.Array(packageIds))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	for _, f := range files {
+		result[int64(f.PackageId)] = append(result[int64(f.PackageId)], f)
+	}
+	return result, nil
+}
+
+// GetFileByUUID returns the file with the provided uuid.
+// Returns ErrFileNotFound if the file does not exist.
+func (q *Queries) GetFileByUUID(ctx context.Context, fileUUID uuid.UUID) (*pgdb.File, error) {
+	queryStr := fmt.Sprintf("SELECT %s FROM files WHERE uuid=$1", fileColumns)
+	f, err := scanFile(q.db.QueryRowContext(ctx, queryStr, fileUUID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &pgdb.ErrFileNotFound{}
+		}
+		log.Error("Error getting file by uuid: ", err)
+		return nil, mapError(err)
+	}
+	return f, nil
+}
+
+// GetFilesByS3Key returns the files that point at an S3 object.
+// Copied packages share S3 objects, so multiple files can be returned.
+func (q *Queries) GetFilesByS3Key(ctx context.Context, s3Bucket string, s3Key string) ([]pgdb.File, error) {
+	queryStr := fmt.Sprintf("SELECT %s FROM files WHERE s3_bucket=$1 AND s3_key=$2 ORDER BY id", fileColumns)
+	return q.queryFiles(ctx, queryStr, s3Bucket, s3Key)
+}
+
+// queryFiles runs a query selecting fileColumns and scans all resulting files.
+func (q *Queries) queryFiles(ctx context.Context, queryStr string, args ...interface{}) ([]pgdb.File, error) {
+	rows, err := q.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		log.Error("Error querying files: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var files []pgdb.File
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			log.Error("Error scanning file: ", err)
+			return nil, mapError(err)
+		}
+		files = append(files, *f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return files, nil
+}
+
+// fileColumnsFormat lists the columns of the files table read by scanFile, in scan order.
+// The format argument is the (possibly empty) table alias prefix.
+const fileColumnsFormat = "%[1]sid, %[1]spackage_id, %[1]sname, %[1]sfile_type, %[1]ss3_bucket, %[1]ss3_key, " +
+	"%[1]sobject_type, %[1]ssize, %[1]schecksum, %[1]suuid, %[1]sprocessing_state, %[1]suploaded_state, " +
+	"%[1]sproperties, %[1]sasset_type, %[1]sprovenance_id, %[1]screated_at, %[1]supdated_at, " +
+	"%[1]spublished_s3_version_id"
+
+// fileColumns are the unqualified columns read by scanFile.
+var fileColumns = fileColumnsWithAlias("")
+
+// fileColumnsWithAlias returns the columns read by scanFile, qualified by the given table alias.
+func fileColumnsWithAlias(alias string) string {
+	if alias == "" {
+		return fmt.Sprintf(fileColumnsFormat, "")
+	}
+	return fmt.Sprintf(fileColumnsFormat, alias+".")
+}
+
+// scanFile scans a row selected with fileColumns into a File.
+func scanFile(row rowScanner) (*pgdb.File, error) {
+	var n nullableFile
+	if err := row.Scan(n.scanDest()...); err != nil {
+		return nil, mapError(err)
+	}
+	return n.toFile()
+}
+
+// nullableFile is a temp struct used to hold the columns read by scanFile.
+// All columns are nullable so it can also hold the result of a LEFT JOIN on files,
+// which returns null values for rows without a file.
+type nullableFile struct {
+	id                   sql.NullString
+	packageId            sql.NullInt64
+	name                 sql.NullString
+	fileType             sql.NullString
+	s3Bucket             sql.NullString
+	s3Key                sql.NullString
+	objectType           sql.NullString
+	size                 sql.NullInt64
+	checksum             sql.NullString
+	uuid                 uuid.NullUUID
+	processingState      sql.NullString
+	uploadedState        sql.NullString
+	properties           []byte
+	assetType            sql.NullString
+	provenanceId         uuid.NullUUID
+	createdAt            sql.NullTime
+	updatedAt            sql.NullTime
+	publishedS3VersionId sql.NullString
+}
+
+func (n *nullableFile) scanDest() []interface{} {
+	return []interface{}{
+		&n.id,
+		&n.packageId,
+		&n.name,
+		&n.fileType,
+		&n.s3Bucket,
+		&n.s3Key,
+		&n.objectType,
+		&n.size,
+		&n.checksum,
+		&n.uuid,
+		&n.processingState,
+		&n.uploadedState,
+		&n.properties,
+		&n.assetType,
+		&n.provenanceId,
+		&n.createdAt,
+		&n.updatedAt,
+		&n.publishedS3VersionId,
+	}
+}
+
+// valid returns false if the row did not contain a file.
+func (n *nullableFile) valid() bool {
+	return n.id.Valid
+}
+
+func (n *nullableFile) toFile() (*pgdb.File, error) {
+	f := pgdb.File{
+		Id:              n.id.String,
+		PackageId:       int(n.packageId.Int64),
+		Name:            n.name.String,
+		FileType:        fileType.Dict[n.fileType.String],
+		S3Bucket:        n.s3Bucket.String,
+		S3Key:           n.s3Key.String,
+		ObjectType:      objectType.Dict[n.objectType.String],
+		Size:            n.size.Int64,
+		CheckSum:        n.checksum.String,
+		UUID:            n.uuid.UUID,
+		ProcessingState: processingState.Dict[n.processingState.String],
+		UploadedState:   uploadState.Dict[n.uploadedState.String],
+		AssetType:       n.assetType.String,
+		ProvenanceId:    n.provenanceId.UUID,
+		CreatedAt:       n.createdAt.Time,
+		UpdatedAt:       n.updatedAt.Time,
+	}
+	if n.publishedS3VersionId.Valid {
+		versionId := n.publishedS3VersionId.String
+		f.PublishedS3VersionId = &versionId
+	}
+
+	// Properties are stored as a JSON object, older rows can hold an empty list.
+	if len(n.properties) > 0 {
+		var properties interface{}
+		if err := json.Unmarshal(n.properties, &properties); err != nil {
+			return nil, dbErrors.Wrap(dbErrors.ErrInvalid, fmt.Errorf("parsing properties of file %s: %w", n.id.String, err))
+		}
+		if m, ok := properties.(map[string]interface{}); ok {
+			f.Properties = m
+		}
+	}
+	return &f, nil
+}
@@ -22,6 +22,9 @@ func TestFiles(t *testing.T) {
 	){
 		"AddFiles duplicate uuid":                    testAddFilesDuplicateUUID,
 		"AddFiles duplicate uuid, differing S3 keys": testAddFilesDuplicateUUIDDifferentS3Key,
+		"Get files for package":                      testGetFilesForPackage,
+		"Get file by uuid":                           testGetFileByUUID,
+		"Get files by S3 key":                        testGetFilesByS3Key,
 	} {
 
 		t.Run(scenario, func(t *testing.T) {
@@ -162,3 +165,80 @@ func testAddFilesDuplicateUUIDDifferentS3Key(t *testing.T, store *SQLStore, orgI
 		assert.Equal(t, actualInitialUpdatedAt, actualUpdatedAt)
 	}
 }
+
+func addTestFiles(t *testing.T, store *SQLStore, packageId int, s3Keys ...string) []pgdb.File {
+	var params []pgdb.FileParams
+	for _, key := range s3Keys {
+		params = append(params, pgdb.FileParams{
+			PackageId:  packageId,
+			Name:       key,
+			FileType:   fileType.EDF,
+			S3Bucket:   "test-bucket",
+			S3Key:      key,
+			ObjectType: objectType.Source,
+			Size:       1024,
+			UUID:       uuid.New(),
+		})
+	}
+	files, err := store.AddFiles(context.Background(), params)
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting files", err)
+	}
+	return files
+}
+
+func testGetFilesForPackage(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	added := addTestFiles(t, store, packageId, "a.edf", "b.edf")
+
+	files, err := store.GetFilesForPackage(context.Background(), int64(packageId))
+	if assert.NoError(t, err) {
+		assert.Len(t, files, 2)
+		assert.Equal(t, added[0].Id, files[0].Id)
+		assert.Equal(t, fileType.EDF, files[0].FileType)
+		assert.Equal(t, objectType.Source, files[0].ObjectType)
+		assert.Nil(t, files[0].PublishedS3VersionId)
+	}
+
+	byPackage, err := store.GetFilesForPackages(context.Background(), []int64{int64(packageId), -1})
+	if assert.NoError(t, err) {
+		assert.Len(t, byPackage, 1)
+		assert.Len(t, byPackage[int64(packageId)], 2)
+	}
+}
+
+func testGetFileByUUID(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	added := addTestFiles(t, store, packageId, "a.edf")
+	_, err := store.db.Exec("UPDATE files SET published_s3_version_id='v1' WHERE id=$1", added[0].Id)
+	assert.NoError(t, err)
+
+	f, err := store.GetFileByUUID(context.Background(), added[0].UUID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, added[0].Id, f.Id)
+		if assert.NotNil(t, f.PublishedS3VersionId) {
+			assert.Equal(t, "v1", *f.PublishedS3VersionId)
+		}
+	}
+
+	_, err = store.GetFileByUUID(context.Background(), uuid.New())
+	assert.ErrorAs(t, err, new(*pgdb.ErrFileNotFound))
+}
+
+func testGetFilesByS3Key(t *testing.T, store *SQLStore, orgId int, packageId int) {
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	addTestFiles(t, store, packageId, "shared.edf", "shared.edf", "other.edf")
+
+	files, err := store.GetFilesByS3Key(context.Background(), "test-bucket", "shared.edf")
+	if assert.NoError(t, err) {
+		assert.Len(t, files, 2)
+	}
+
+	files, err = store.GetFilesByS3Key(context.Background(), "other-bucket", "shared.edf")
+	if assert.NoError(t, err) {
+		assert.Empty(t, files)
+	}
+}
@@ -0,0 +1,122 @@
+package pgdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// keysetValueKind is the type of the sort value of a keyset page, used to encode it in a continuation token.
+type keysetValueKind int
+
+const (
+	keysetString keysetValueKind = iota
+	keysetInt
+	keysetTime
+)
+
+// keysetCursor is the position of the last returned row, encoded in the continuation token.
+type keysetCursor struct {
+	SortBy     string `json:"sortBy"`
+	Descending bool   `json:"descending"`
+	Value      string `json:"value"`
+	Id         int64  `json:"id"`
+}
+
+// keysetPage pages through rows ordered by a sort expression and an id column. Pages are keyed on the sort
+// value and id of the last returned row, so results stay consistent while rows are added or removed.
+// The query selects limit+1 rows; the extra row only signals that there is a next page.
+type keysetPage struct {
+	sortBy     string
+	sortExpr   string
+	idExpr     string
+	kind       keysetValueKind
+	descending bool
+	limit      int
+}
+
+// keysetLimit returns limit, or defaultLimit if limit is not set, capped at maxLimit.
+func keysetLimit(limit int, defaultLimit int, maxLimit int) int {
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit
+}
+
+// after returns the predicate that selects the rows after the continuation token, or "" for the first page.
+// arg adds a query argument and returns its placeholder.
+func (p keysetPage) after(token string, arg func(value interface{}) string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	b, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return "", InvalidContinuationTokenError{err.Error()}
+	}
+	var cursor keysetCursor
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return "", InvalidContinuationTokenError{err.Error()}
+	}
+	if cursor.SortBy != p.sortBy || cursor.Descending != p.descending {
+		return "", InvalidContinuationTokenError{"token was issued for a different sort order"}
+	}
+
+	var value interface{}
+	switch p.kind {
+	case keysetInt:
+		value, err = strconv.ParseInt(cursor.Value, 10, 64)
+	case keysetTime:
+		value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	default:
+		value = cursor.Value
+	}
+	if err != nil {
+		return "", InvalidContinuationTokenError{err.Error()}
+	}
+
+	comparison := ">"
+	if p.descending {
+		comparison = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s (%s, %s)", p.sortExpr, p.idExpr, comparison, arg(value), arg(cursor.Id)), nil
+}
+
+// orderBy returns the ORDER BY and LIMIT clauses of the page.
+func (p keysetPage) orderBy(arg func(value interface{}) string) string {
+	direction := "ASC"
+	if p.descending {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT %s", p.sortExpr, direction, p.idExpr, direction, arg(p.limit+1))
+}
+
+// hasMore returns true if count, the number of selected rows, includes a row of the next page.
+func (p keysetPage) hasMore(count int) bool {
+	return count > p.limit
+}
+
+// continuationToken returns the token for the page after the row with the given id and sort value.
+// value is a string, an int64 or a time.Time, matching the kind of the page.
+func (p keysetPage) continuationToken(id int64, value interface{}) (string, error) {
+	cursor := keysetCursor{SortBy: p.sortBy, Descending: p.descending, Id: id}
+	switch v := value.(type) {
+	case int64:
+		cursor.Value = strconv.FormatInt(v, 10)
+	case time.Time:
+		cursor.Value = v.Format(time.RFC3339Nano)
+	default:
+		cursor.Value = fmt.Sprint(v)
+	}
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,38 @@
+package pgdb
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestKeysetPage(t *testing.T) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	page := keysetPage{sortBy: "createdAt", sortExpr: "p.created_at", idExpr: "p.id", kind: keysetTime, descending: true, limit: 2}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	token, err := page.continuationToken(42, createdAt)
+	require.NoError(t, err)
+
+	after, err := page.after(token, arg)
+	require.NoError(t, err)
+	assert.Equal(t, "(p.created_at, p.id) < ($1, $2)", after)
+	assert.Equal(t, "ORDER BY p.created_at DESC, p.id DESC LIMIT $3", page.orderBy(arg))
+	assert.Equal(t, []interface{}{createdAt, int64(42), 3}, args)
+	assert.False(t, page.hasMore(2))
+	assert.True(t, page.hasMore(3))
+
+	ascending := page
+	ascending.descending = false
+	_, err = ascending.after(token, arg)
+	assert.IsType(t, InvalidContinuationTokenError{}, err)
+
+	assert.Equal(t, 50, keysetLimit(0, 50, 100))
+	assert.Equal(t, 100, keysetLimit(500, 50, 100))
+}
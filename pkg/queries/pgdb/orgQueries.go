@@ -0,0 +1,132 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dataset"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// OrgQueries runs Queries against the schema of a single organization without changing the
+// search_path of a shared connection.
+//   - Queries that take an organization id, such as IsFilePublished, qualify their tables with the
+//     organization schema. OrgQueries provides them without the organization id argument.
+//   - All other Queries use unqualified tables. OrgQueries runs them on a connection or transaction that
+//     is pinned to it, with the search_path set for that connection or transaction only.
+//
+// Use SQLStore.OrgConn or SQLStore.ExecOrgTx to get an OrgQueries.
+type OrgQueries struct {
+	*Queries
+	organizationId int64
+}
+
+// OrganizationId returns the id of the organization the queries run against.
+func (q *OrgQueries) OrganizationId() int64 {
+	return q.organizationId
+}
+
+// Table returns the quoted, schema-qualified name of a table in the organization schema.
+func (q *OrgQueries) Table(name string) (string, error) {
+	return orgTable(q.organizationId, name)
+}
+
+// OrgConn pins a connection from the pool and sets its search_path to the organization schema.
+// The returned release function restores the previous search_path and returns the connection to the
+// pool. It must be called when the OrgQueries is no longer used, and the OrgQueries must not be
+// shared between goroutines.
+func (store *SQLStore) OrgConn(ctx context.Context, organizationId int64) (*OrgQueries, func() error, error) {
+	schema, err := orgSchema(organizationId)
+	if err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	conn, err := store.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	var previous string
+	err = conn.QueryRowContext(ctx, "SELECT current_setting('search_path')").Scan(&previous)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "SELECT set_config('search_path', $1, false)", schema)
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to set search_path to %d on pinned connection: %v", organizationId, err))
+		if closeErr := conn.Close(); closeErr != nil {
+			return nil, nil, fmt.Errorf("search_path err: %v, close err: %v", err, closeErr)
+		}
+		return nil, nil, mapError(err)
+	}
+
+	release := func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT set_config('search_path', $1, false)", previous)
+		if err != nil {
+			// Do not return a connection with the organization search_path to the pool.
+			log.Error("Unable to restore search_path, discarding connection: ", err)
+			_ = conn.Raw(func(any) error {
+				return driver.ErrBadConn
+			})
+		}
+		return conn.Close()
+	}
+
+	return &OrgQueries{Queries: New(conn), organizationId: organizationId}, release, nil
+}
+
+// ExecOrgTx runs fn in a transaction whose search_path is set to the organization schema.
+// The search_path is set with set_config(..., true), like SET LOCAL, so it ends with the transaction.
+// Use ExecTx with TxOptions.OrganizationId to configure the transaction.
+func (store *SQLStore) ExecOrgTx(ctx context.Context, organizationId int64, fn func(*OrgQueries) error) error {
+	if _, err := orgSchema(organizationId); err != nil {
+		return mapError(err)
+	}
+	return store.ExecTx(ctx, TxOptions{OrganizationId: organizationId}, func(qtx *TxQueries) error {
+		return fn(qtx.OrgQueries)
+	})
+}
+
+// IsFilePublished checks whether a file of the organization has been published. See Queries.IsFilePublished.
+func (q *OrgQueries) IsFilePublished(ctx context.Context, uploadId string) (bool, error) {
+	return q.Queries.IsFilePublished(ctx, uploadId, q.organizationId)
+}
+
+// UpdateBucketForUnpublishedFile updates the storage bucket for an unpublished file of the organization.
+// See Queries.UpdateBucketForUnpublishedFile.
+func (q *OrgQueries) UpdateBucketForUnpublishedFile(ctx context.Context, uploadId string, bucket string, s3Key string) error {
+	return q.Queries.UpdateBucketForUnpublishedFile(ctx, uploadId, bucket, s3Key, q.organizationId)
+}
+
+// UpdateBucketForFile updates the storage bucket for a file of the organization. See Queries.UpdateBucketForFile.
+func (q *OrgQueries) UpdateBucketForFile(ctx context.Context, uploadId string, bucket string, s3Key string) error {
+	return q.Queries.UpdateBucketForFile(ctx, uploadId, bucket, s3Key, q.organizationId)
+}
+
+// GetDatasetClaim returns the highest role that the user has for a dataset of the organization.
+// See Queries.GetDatasetClaim.
+func (q *OrgQueries) GetDatasetClaim(ctx context.Context, user *pgdb.User, datasetNodeId string) (*dataset.Claim, error) {
+	return q.Queries.GetDatasetClaim(ctx, user, datasetNodeId, q.organizationId)
+}
+
+// GetDefaultDataUseAgreement returns the default data use agreement of the organization.
+// See Queries.GetDefaultDataUseAgreement.
+func (q *OrgQueries) GetDefaultDataUseAgreement(ctx context.Context) (*pgdb.DataUseAgreement, error) {
+	return q.Queries.GetDefaultDataUseAgreement(ctx, int(q.organizationId))
+}
+
+// GetDefaultDatasetStatus returns the default dataset status of the organization.
+// See Queries.GetDefaultDatasetStatus.
+func (q *OrgQueries) GetDefaultDatasetStatus(ctx context.Context) (*pgdb.DatasetStatus, error) {
+	return q.Queries.GetDefaultDatasetStatus(ctx, int(q.organizationId))
+}
+
+// GetFeatureFlags returns the feature flags of the organization. See Queries.GetFeatureFlags.
+func (q *OrgQueries) GetFeatureFlags(ctx context.Context) ([]pgdb.FeatureFlags, error) {
+	return q.Queries.GetFeatureFlags(ctx, q.organizationId)
+}
+
+// GetEnabledFeatureFlags returns the enabled feature flags of the organization. See Queries.GetEnabledFeatureFlags.
+func (q *OrgQueries) GetEnabledFeatureFlags(ctx context.Context) ([]pgdb.FeatureFlags, error) {
+	return q.Queries.GetEnabledFeatureFlags(ctx, q.organizationId)
+}
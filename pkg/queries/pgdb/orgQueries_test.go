@@ -0,0 +1,130 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+// TestOrgQueries is the main Test Suite function for org-scoped queries.
+// It uses the connection without an organization search_path, so the queries only work if
+// OrgQueries selects the organization schema.
+func TestOrgQueries(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore,
+	){
+		"Org connection selects organization schema":  testOrgConn,
+		"Org connections are isolated":                testOrgConnIsolation,
+		"Org transaction selects organization schema": testExecOrgTx,
+		"Org queries qualify organization tables":     testOrgQueriesQualifiedTables,
+		"Invalid organization id":                     testOrgQueriesInvalidOrganizationId,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			store := NewSQLStore(testDB[0])
+			fn(t, store)
+		})
+	}
+}
+
+func currentSchema(t *testing.T, q *OrgQueries) string {
+	var schema string
+	if err := q.db.QueryRowContext(context.Background(), "SELECT current_schema()").Scan(&schema); err != nil {
+		assert.FailNow(t, "unable to read current schema", err)
+	}
+	return schema
+}
+
+func testOrgConn(t *testing.T, store *SQLStore) {
+	ctx := context.Background()
+
+	q, release, err := store.OrgConn(ctx, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(1), q.OrganizationId())
+	assert.Equal(t, "1", currentSchema(t, q))
+
+	// Unqualified query against the stub dataset of organization 1.
+	ds, err := q.GetDatasetByNodeId(ctx, "N:Dataset:00000000-6803-4a67-bf20-83076774a5c7")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), ds.Id)
+	}
+
+	assert.NoError(t, release())
+}
+
+func testOrgConnIsolation(t *testing.T, store *SQLStore) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, orgId := range []int64{1, 2, 3} {
+		wg.Add(1)
+		go func(orgId int64) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				q, release, err := store.OrgConn(ctx, orgId)
+				if !assert.NoError(t, err) {
+					return
+				}
+				schema, _ := orgSchema(orgId)
+				assert.Equal(t, schema, `"`+currentSchema(t, q)+`"`)
+				assert.NoError(t, release())
+			}
+		}(orgId)
+	}
+	wg.Wait()
+}
+
+func testExecOrgTx(t *testing.T, store *SQLStore) {
+	ctx := context.Background()
+
+	err := store.ExecOrgTx(ctx, 2, func(q *OrgQueries) error {
+		assert.Equal(t, "2", currentSchema(t, q))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	rollback := errors.New("rollback")
+	err = store.ExecOrgTx(ctx, 3, func(q *OrgQueries) error {
+		assert.Equal(t, "3", currentSchema(t, q))
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+}
+
+func testOrgQueriesQualifiedTables(t *testing.T, store *SQLStore) {
+	ctx := context.Background()
+
+	err := store.ExecOrgTx(ctx, 2, func(q *OrgQueries) error {
+		table, err := q.Table("dataset_status")
+		assert.NoError(t, err)
+		assert.Equal(t, `"2"."dataset_status"`, table)
+
+		datasetStatus, err := q.GetDefaultDatasetStatus(ctx)
+		if assert.NoError(t, err) {
+			assert.NotEmpty(t, datasetStatus.Name)
+		}
+
+		dataUseAgreement, err := q.GetDefaultDataUseAgreement(ctx)
+		if assert.NoError(t, err) {
+			assert.True(t, dataUseAgreement.IsDefault)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func testOrgQueriesInvalidOrganizationId(t *testing.T, store *SQLStore) {
+	ctx := context.Background()
+
+	_, _, err := store.OrgConn(ctx, 0)
+	assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+
+	err = store.ExecOrgTx(ctx, -1, func(q *OrgQueries) error {
+		assert.Fail(t, "transaction should not run for an invalid organization id")
+		return nil
+	})
+	assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+}
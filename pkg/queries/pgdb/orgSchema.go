@@ -0,0 +1,43 @@
+package pgdb
+
+import (
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"strconv"
+)
+
+// Values are always passed to queries as bind parameters. Identifiers cannot be bound, so the only
+// identifiers built at runtime are organization schemas and their tables, and they must go through
+// orgSchema or orgTable.
+
+// InvalidOrganizationIdError is returned when an organization id cannot be used to select an organization schema.
+type InvalidOrganizationIdError struct {
+	ErrorMessage string
+}
+
+func (e InvalidOrganizationIdError) Error() string {
+	return fmt.Sprintf("invalid organization id (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidOrganizationIdError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// orgSchema returns the quoted name of the schema of an organization, e.g. "1".
+func orgSchema(organizationId int64) (string, error) {
+	if organizationId <= 0 {
+		return "", InvalidOrganizationIdError{fmt.Sprintf("%d is not a valid organization id", organizationId)}
+	}
+	return pq.QuoteIdentifier(strconv.FormatInt(organizationId, 10)), nil
+}
+
+// orgTable returns the quoted, schema-qualified name of a table in the schema of an organization, e.g. "1"."files".
+func orgTable(organizationId int64, table string) (string, error) {
+	schema, err := orgSchema(organizationId)
+	if err != nil {
+		return "", err
+	}
+	return schema + "." + pq.QuoteIdentifier(table), nil
+}
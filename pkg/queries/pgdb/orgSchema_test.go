@@ -0,0 +1,144 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// hostileNodeIds are seeds for the fuzz tests below. Each would change the meaning of a query
+// if it were formatted into the SQL instead of passed as a bind parameter.
+var hostileNodeIds = []string{
+	"N:package:00000000-0000-0000-0000-000000000000",
+	"' OR '1'='1",
+	"1 OR 1=1",
+	"'; DELETE FROM packages; --",
+	"N:dataset:x'; DROP TABLE datasets; --",
+	"\" OR \"\"=\"",
+	"node_id",
+	"'' UNION SELECT 1, 'owner' --",
+	"\\'; SELECT pg_sleep(10); --",
+	"",
+}
+
+func TestOrgSchema(t *testing.T) {
+	schema, err := orgSchema(1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"1"`, schema)
+	}
+
+	table, err := orgTable(42, "files")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `"42"."files"`, table)
+	}
+
+	for _, id := range []int64{0, -1} {
+		_, err = orgSchema(id)
+		assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+		_, err = orgTable(id, "files")
+		assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+	}
+}
+
+func FuzzOrgTable(f *testing.F) {
+	f.Add(int64(1), "files")
+	f.Add(int64(2), `files"; DROP TABLE packages; --`)
+	f.Add(int64(-7), "datasets")
+	f.Fuzz(func(t *testing.T, organizationId int64, table string) {
+		quoted, err := orgTable(organizationId, table)
+		if organizationId <= 0 {
+			assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+			return
+		}
+		assert.NoError(t, err)
+
+		schema, _ := orgSchema(organizationId)
+		if !assert.True(t, strings.HasPrefix(quoted, schema+`."`)) || !assert.True(t, strings.HasSuffix(quoted, `"`)) {
+			return
+		}
+		// Inside the quotes, every quote of the table name must be escaped by doubling it.
+		inner := strings.TrimSuffix(strings.TrimPrefix(quoted, schema+`."`), `"`)
+		assert.NotContains(t, strings.ReplaceAll(inner, `""`, ""), `"`)
+	})
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+		assert.FailNow(t, "unable to count rows", err)
+	}
+	return count
+}
+
+func FuzzGetPackageByNodeId(f *testing.F) {
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+	packages, err := store.AddPackages(context.Background(), test.GenerateTestPackages([]test.PackageParams{
+		{Name: "fuzz-package", ParentId: -1},
+	}, 1))
+	if err != nil {
+		f.Fatal("unable to set up test; error inserting package", err)
+	}
+	defer test.Truncate(f, store.db, orgId, "packages")
+
+	for _, nodeId := range hostileNodeIds {
+		f.Add(nodeId)
+	}
+	f.Add(packages[0].NodeId)
+	f.Fuzz(func(t *testing.T, nodeId string) {
+		before := countRows(t, store.db, "packages")
+
+		p, err := store.GetPackageByNodeId(context.Background(), nodeId)
+		if err == nil {
+			assert.Equal(t, nodeId, p.NodeId, "only the package with the exact node id should match")
+		}
+
+		assert.Equal(t, before, countRows(t, store.db, "packages"))
+	})
+}
+
+func FuzzGetDatasetByNodeId(f *testing.F) {
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+
+	for _, nodeId := range hostileNodeIds {
+		f.Add(nodeId)
+	}
+	f.Fuzz(func(t *testing.T, nodeId string) {
+		before := countRows(t, store.db, "datasets")
+
+		ds, err := store.GetDatasetByNodeId(context.Background(), nodeId)
+		if err == nil {
+			assert.Equal(t, nodeId, ds.NodeId.String, "only the dataset with the exact node id should match")
+		}
+
+		assert.Equal(t, before, countRows(t, store.db, "datasets"))
+	})
+}
+
+func FuzzGetDatasetClaim(f *testing.F) {
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+	user := &pgdb.User{Id: 1}
+
+	for _, nodeId := range hostileNodeIds {
+		f.Add(nodeId)
+	}
+	f.Fuzz(func(t *testing.T, nodeId string) {
+		before := countRows(t, store.db, "datasets")
+
+		claim, err := store.GetDatasetClaim(context.Background(), user, nodeId, int64(orgId))
+		if err == nil {
+			ds, err := store.GetDatasetById(context.Background(), claim.IntId)
+			if assert.NoError(t, err) {
+				assert.Equal(t, nodeId, ds.NodeId.String, "only the dataset with the exact node id should match")
+			}
+		}
+
+		assert.Equal(t, before, countRows(t, store.db, "datasets"))
+	})
+}
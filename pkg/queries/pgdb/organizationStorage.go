@@ -2,6 +2,8 @@ package pgdb
 
 import (
 	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,7 +20,25 @@ func (q *Queries) IncrementOrganizationStorage(ctx context.Context, organization
 		log.Println("Error incrementing package size: ", err)
 	}
 
-	return err
+	return mapError(err)
+}
+
+// DecrementOrganizationStorage decreases the storage associated with the provided organization.
+// The storage never goes below zero.
+func (q *Queries) DecrementOrganizationStorage(ctx context.Context, organizationId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot decrement organization storage by a negative size: %d", size))
+	}
+
+	queryStr := "UPDATE pennsieve.organization_storage " +
+		"SET size = GREATEST(COALESCE(size, 0) - $2, 0) WHERE organization_id = $1"
+
+	_, err := q.db.ExecContext(ctx, queryStr, organizationId, size)
+	if err != nil {
+		log.Println("Error decrementing organization size: ", err)
+	}
+
+	return mapError(err)
 }
 
 func (q *Queries) GetOrganizationStorageById(ctx context.Context, organizationId int64) (int64, error) {
@@ -30,7 +50,7 @@ func (q *Queries) GetOrganizationStorageById(ctx context.Context, organizationId
 
 	if err != nil {
 		log.Error("unable to get organization size", err)
-		return int64(0), err
+		return int64(0), mapError(err)
 	}
 
 	return orgSize, nil
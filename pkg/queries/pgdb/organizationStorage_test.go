@@ -39,3 +39,22 @@ func TestOrganizationStorage(t *testing.T) {
 	assert.Equal(t, int64(10), actualSize, "Size is expected to be 10")
 
 }
+
+func TestDecrementOrganizationStorage(t *testing.T) {
+
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+	organizationId := int64(1)
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	err := store.IncrementOrganizationStorage(context.Background(), organizationId, 10)
+	assert.NoError(t, err)
+
+	// Removing more than is available
+	err = store.DecrementOrganizationStorage(context.Background(), organizationId, 100)
+	assert.NoError(t, err)
+
+	actualSize, err := store.GetOrganizationStorageById(context.Background(), organizationId)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), actualSize, "Size is expected to stop at 0")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/organization"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 	log "github.com/sirupsen/logrus"
@@ -17,8 +18,13 @@ func (e OrganizationUserNotFoundError) Error() string {
 	return fmt.Sprintf("organization user was not found (error: %v)", e.ErrorMessage)
 }
 
+// Is returns true for dbErrors.ErrNotFound.
+func (e OrganizationUserNotFoundError) Is(target error) bool {
+	return target == dbErrors.ErrNotFound
+}
+
 // GetOrganizationUserById returns *pgdb.OrganizationUser with the given user id.
-// If no such user exists, returns (nil, sql.ErrNoRows)
+// If no such user exists, returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound).
 func (q *Queries) GetOrganizationUserById(ctx context.Context, id int64) (*pgdb.OrganizationUser, error) {
 
 	queryStr := "SELECT organization_id, user_id, permission_bit, created_at, updated_at " +
@@ -34,7 +40,7 @@ func (q *Queries) GetOrganizationUserById(ctx context.Context, id int64) (*pgdb.
 		&orgUser.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &orgUser, nil
 }
@@ -60,7 +66,7 @@ func (q *Queries) GetOrganizationUser(ctx context.Context, orgId int64, userId i
 	case nil:
 		return &orgUser, nil
 	default:
-		return nil, err
+		return nil, mapError(err)
 	}
 }
 
@@ -74,7 +80,7 @@ func (q *Queries) AddOrganizationUser(ctx context.Context, orgId int64, userId i
 		case OrganizationUserNotFoundError:
 			// do nothing
 		default:
-			return nil, err
+			return nil, mapError(err)
 		}
 	}
 
@@ -87,12 +93,12 @@ func (q *Queries) AddOrganizationUser(ctx context.Context, orgId int64, userId i
 
 	_, err = q.db.ExecContext(ctx, statement, orgId, userId, permBit)
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on insert: %v", err))
+		return nil, mapError(fmt.Errorf("database error on insert: %w", err))
 	}
 
 	orgUser, err := q.GetOrganizationUser(ctx, orgId, userId)
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("database error on query: %v", err))
+		return nil, mapError(fmt.Errorf("database error on query: %w", err))
 	}
 
 	return orgUser, nil
@@ -175,7 +181,7 @@ var orgClaimByOrgId = orgClaimQuery{
 func queryOrganizationClaim(ctx context.Context, db DBTX, orgClaimQuery *orgClaimQuery, userId int64, orgIdentifier any) (*organization.Claim, error) {
 	rows, err := db.QueryContext(ctx, orgClaimQuery.query, userId, orgIdentifier)
 	if err != nil {
-		return nil, fmt.Errorf("error getting organization claim with query %s: %w", orgClaimQuery, err)
+		return nil, mapError(fmt.Errorf("error getting organization claim with query %s: %w", orgClaimQuery, err))
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -199,7 +205,7 @@ func queryOrganizationClaim(ctx context.Context, db DBTX, orgClaimQuery *orgClai
 			&nullableFlag.enabled,
 			&nullableFlag.createdAt,
 			&nullableFlag.updatedAt); err != nil {
-			return nil, fmt.Errorf("error reading row for get organization claim with query %s: %w", orgClaimQuery, err)
+			return nil, mapError(fmt.Errorf("error reading row for get organization claim with query %s: %w", orgClaimQuery, err))
 		}
 		// an org may have no feature flags. don't add a bunch of zero-ed structs to claim
 		if nullableFlag.valid() {
@@ -208,7 +214,7 @@ func queryOrganizationClaim(ctx context.Context, db DBTX, orgClaimQuery *orgClai
 		}
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("row error on get organization claim with query %s: %w", orgClaimQuery, err)
+		return nil, mapError(fmt.Errorf("row error on get organization claim with query %s: %w", orgClaimQuery, err))
 	}
 	// zero orgId means no rows returned.
 	if orgId == 0 {
@@ -5,7 +5,7 @@ import (
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
 )
 
-// getOrganization returns (nil, sql.ErrNoRows) if no Organization is found
+// getOrganization returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no Organization is found.
 func (q *Queries) getOrganization(ctx context.Context, query string, value any) (*pgdb.Organization, error) {
 	var organization pgdb.Organization
 	row := q.db.QueryRowContext(ctx, query, value)
@@ -21,7 +21,7 @@ func (q *Queries) getOrganization(ctx context.Context, query string, value any)
 		&organization.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &organization, nil
 }
@@ -0,0 +1,350 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/nodeId"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type InvalidPackageCopyError struct {
+	ErrorMessage string
+}
+
+func (e InvalidPackageCopyError) Error() string {
+	return fmt.Sprintf("invalid package copy (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidPackageCopyError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// CopyPackageTreeParams is the input for CopyPackageTree.
+// TargetParentId is not optional and -1 refers to the root folder of the target dataset.
+type CopyPackageTreeParams struct {
+	OrganizationId  int64
+	SourcePackageId int64
+	TargetDatasetId int64
+	TargetParentId  int64
+	OwnerId         int
+}
+
+// CopyPackageTreeResult describes the packages created by CopyPackageTree.
+type CopyPackageTreeResult struct {
+	Root         pgdb.Package
+	Packages     []pgdb.Package  // All copied packages, parents before children.
+	PackageIdMap map[int64]int64 // Maps source package id to copied package id.
+	FileCount    int
+	Size         int64
+}
+
+// CopyPackageTree copies a package, or a folder with its subtree, into a folder of a dataset in the same organization.
+// The copy is executed in a single transaction.
+func (store *SQLStore) CopyPackageTree(ctx context.Context, params CopyPackageTreeParams) (*CopyPackageTreeResult, error) {
+	var result *CopyPackageTreeResult
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = q.CopyPackageTree(ctx, params)
+		return mapError(err)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return result, nil
+}
+
+// CopyPackageTree copies a package, or a folder with its subtree, into a folder of a dataset in the same organization.
+//   - This call should be wrapped in a Transaction as it will run multiple queries; SQLStore.CopyPackageTree does so.
+//   - Copies get fresh node ids and are owned by params.OwnerId. Only READY packages are copied: packages
+//     that are deleted, still uploading or processing, or that failed are skipped with their descendants.
+//   - The source of each copy is recorded in package_copy_source, see GetPackageCopySourceId.
+//   - files rows are cloned with a new uuid and point at the same S3 objects. The provenance_id
+//     of each cloned file is set to the uuid of its source file.
+//   - A name collision of the copied root at the destination is resolved by appending " (N)".
+//   - package_storage rows are created for the copies from the size of the copied packages, and the size
+//     of the tree is added to the destination ancestors, the target dataset and the organization.
+func (q *Queries) CopyPackageTree(ctx context.Context, params CopyPackageTreeParams) (*CopyPackageTreeResult, error) {
+	source, err := q.GetPackageById(ctx, params.SourcePackageId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, InvalidPackageCopyError{fmt.Sprintf("package %d does not exist", params.SourcePackageId)}
+		}
+		return nil, mapError(err)
+	}
+	if isDeletedState(source.PackageState) {
+		return nil, InvalidPackageCopyError{fmt.Sprintf("package %d is deleted", source.Id)}
+	}
+	if source.PackageState != packageState.Ready {
+		return nil, InvalidPackageCopyError{fmt.Sprintf("package %d is not ready", source.Id)}
+	}
+
+	sqlParentId := sql.NullInt64{Valid: false}
+	if params.TargetParentId >= 0 {
+		target, err := q.GetPackageById(ctx, params.TargetParentId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, InvalidPackageCopyError{fmt.Sprintf("destination %d does not exist", params.TargetParentId)}
+			}
+			return nil, mapError(err)
+		}
+		if int64(target.DatasetId) != params.TargetDatasetId {
+			return nil, InvalidPackageCopyError{fmt.Sprintf("destination %d is not in dataset %d", target.Id, params.TargetDatasetId)}
+		}
+		if target.PackageType != packageType.Collection {
+			return nil, InvalidPackageCopyError{fmt.Sprintf("destination %d is not a folder", target.Id)}
+		}
+		if isDeletedState(target.PackageState) {
+			return nil, InvalidPackageCopyError{fmt.Sprintf("destination %d is deleted", target.Id)}
+		}
+
+		ancestorIds, err := q.GetPackageAncestorIds(ctx, target.Id)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		for _, id := range ancestorIds {
+			if id == source.Id {
+				return nil, InvalidPackageCopyError{fmt.Sprintf("cannot copy package %d into itself or one of its descendants", source.Id)}
+			}
+		}
+		sqlParentId = sql.NullInt64{Int64: target.Id, Valid: true}
+	}
+
+	tree, err := q.getPackageTree(ctx, source.Id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	names, err := q.resolveKeepBothNames(ctx, params.TargetDatasetId, params.TargetParentId, tree[:1])
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	result := CopyPackageTreeResult{PackageIdMap: map[int64]int64{}}
+	currentTime := time.Now()
+
+	sqlInsert := "INSERT INTO packages(name, type, state, node_id, parent_id, " +
+		"dataset_id, owner_id, size, attributes, created_at, updated_at) " +
+		"VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) " +
+		fmt.Sprintf("RETURNING %s", packageColumns)
+
+	stmt, err := q.db.PrepareContext(ctx, sqlInsert)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("error preparing copyPackageTree statement: %w", err))
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer stmt.Close()
+
+	var sourceIds, copyIds []int64
+	for i, p := range tree {
+		parentId := sqlParentId
+		name := p.Name
+		if i == 0 {
+			name = names[p.Id]
+		} else {
+			parentId = sql.NullInt64{Int64: result.PackageIdMap[p.ParentId.Int64], Valid: true}
+		}
+
+		nodeType := nodeId.PackageCode
+		if p.PackageType == packageType.Collection {
+			nodeType = nodeId.CollectionCode
+		}
+
+		copied, err := scanPackage(stmt.QueryRowContext(ctx, name, p.PackageType.String(), p.PackageState.String(),
+			nodeId.NodeId(nodeType), parentId, params.TargetDatasetId, params.OwnerId, p.Size, p.Attributes,
+			currentTime, currentTime))
+		if err != nil {
+			log.Error("Error copying package: ", err)
+			return nil, mapError(fmt.Errorf("copying package %d: %w", p.Id, err))
+		}
+
+		result.PackageIdMap[p.Id] = copied.Id
+		result.Packages = append(result.Packages, *copied)
+		sourceIds = append(sourceIds, p.Id)
+		copyIds = append(copyIds, copied.Id)
+	}
+	result.Root = result.Packages[0]
+
+	result.FileCount, err = q.copyFiles(ctx, sourceIds, copyIds, currentTime)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	_, err = q.db.ExecContext(ctx,
+		"INSERT INTO package_copy_source (package_id, source_package_id) "+
+			"SELECT copy_id, source_id FROM unnest($1::bigint[], $2::bigint[]) AS ids(source_id, copy_id)",
+		pq.Array(sourceIds), pq.Array(copyIds))
+	if err != nil {
+		return nil, schemaError(organizationSchemaDir, fmt.Errorf("recording copy sources: %w", err))
+	}
+
+	result.Size, err = q.copyPackageStorage(ctx, tree, result.PackageIdMap)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	if result.Size > 0 {
+		if params.TargetParentId >= 0 {
+			if err := q.IncrementPackageStorageAncestors(ctx, params.TargetParentId, result.Size); err != nil {
+				return nil, mapError(fmt.Errorf("incrementing ancestor storage: %w", err))
+			}
+		}
+		if err := q.IncrementDatasetStorage(ctx, params.TargetDatasetId, result.Size); err != nil {
+			return nil, mapError(fmt.Errorf("incrementing dataset storage: %w", err))
+		}
+		if err := q.IncrementOrganizationStorage(ctx, params.OrganizationId, result.Size); err != nil {
+			return nil, mapError(fmt.Errorf("incrementing organization storage: %w", err))
+		}
+	}
+
+	return &result, nil
+}
+
+// getPackageTree returns a package and its READY descendants whose ancestors are all READY, parents before children.
+func (q *Queries) getPackageTree(ctx context.Context, packageId int64) ([]pgdb.Package, error) {
+	var tree []pgdb.Package
+	included := map[int64]bool{}
+	err := q.WalkPackageTree(ctx, packageId, WalkPackageTreeOptions{}, func(node PackageTreeNode) error {
+		p := node.Package
+		if p.PackageState != packageState.Ready || (node.Depth > 0 && !included[p.ParentId.Int64]) {
+			return nil
+		}
+		included[p.Id] = true
+		tree = append(tree, p)
+		return nil
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(tree) == 0 {
+		return nil, mapError(sql.ErrNoRows)
+	}
+	return tree, nil
+}
+
+// copyPackageStorage creates the package_storage rows of the copies of the packages in tree. The size of a
+// copied folder is the size of the packages copied into it, which is less than the size of its source
+// when packages were skipped. It returns the size of the copied tree.
+func (q *Queries) copyPackageStorage(ctx context.Context, tree []pgdb.Package, packageIdMap map[int64]int64) (int64, error) {
+	parentIds := map[int64]int64{}
+	var sourceIds []int64
+	for i, p := range tree {
+		if i > 0 {
+			parentIds[p.Id] = p.ParentId.Int64
+		}
+		if p.PackageType != packageType.Collection {
+			sourceIds = append(sourceIds, p.Id)
+		}
+	}
+
+	rows, err := q.db.QueryContext(ctx,
+		"SELECT package_id, size FROM package_storage WHERE package_id = ANY($1)", pq.Array(sourceIds))
+	if err != nil {
+		return 0, mapError(fmt.Errorf("reading package storage to copy: %w", err))
+	}
+	defer rows.Close()
+
+	sizes := map[int64]int64{}
+	for rows.Next() {
+		var packageId, size int64
+		if err := rows.Scan(&packageId, &size); err != nil {
+			return 0, mapError(err)
+		}
+		for id, ok := packageId, true; ok; id, ok = parentIds[id] {
+			sizes[id] += size
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, mapError(err)
+	}
+
+	var copyIds, copySizes []int64
+	for _, p := range tree {
+		if size, ok := sizes[p.Id]; ok {
+			copyIds = append(copyIds, packageIdMap[p.Id])
+			copySizes = append(copySizes, size)
+		}
+	}
+	if len(copyIds) == 0 {
+		return 0, nil
+	}
+
+	_, err = q.db.ExecContext(ctx,
+		"INSERT INTO package_storage (package_id, size) SELECT * FROM unnest($1::bigint[], $2::bigint[])",
+		pq.Array(copyIds), pq.Array(copySizes))
+	if err != nil {
+		return 0, mapError(fmt.Errorf("copying package storage: %w", err))
+	}
+	return sizes[tree[0].Id], nil
+}
+
+// GetPackageCopySourceId returns the id of the package that a package was copied from by CopyPackageTree.
+// It returns an error matching dbErrors.ErrNotFound if the package is not a copy, or if its source was purged.
+func (q *Queries) GetPackageCopySourceId(ctx context.Context, packageId int64) (int64, error) {
+	var sourceId sql.NullInt64
+	err := q.db.QueryRowContext(ctx,
+		"SELECT source_package_id FROM package_copy_source WHERE package_id = $1", packageId).Scan(&sourceId)
+	if err != nil {
+		return 0, schemaError(organizationSchemaDir, err)
+	}
+	if !sourceId.Valid {
+		return 0, mapError(sql.ErrNoRows)
+	}
+	return sourceId.Int64, nil
+}
+
+// copyFiles clones the files rows of the source packages into the matching copied packages.
+// It returns the number of cloned files.
+func (q *Queries) copyFiles(ctx context.Context, sourceIds []int64, copyIds []int64, currentTime time.Time) (int, error) {
+	rows, err := q.db.QueryContext(ctx,
+		"SELECT uuid FROM files WHERE package_id = ANY($1)", pq.Array(sourceIds))
+	if err != nil {
+		return 0, mapError(fmt.Errorf("reading files to copy: %w", err))
+	}
+	defer rows.Close()
+
+	var sourceUUIDs, copyUUIDs []string
+	for rows.Next() {
+		var sourceUUID string
+		if err := rows.Scan(&sourceUUID); err != nil {
+			return 0, mapError(err)
+		}
+		sourceUUIDs = append(sourceUUIDs, sourceUUID)
+		copyUUIDs = append(copyUUIDs, uuid.NewString())
+	}
+	if err := rows.Err(); err != nil {
+		return 0, mapError(err)
+	}
+	if len(sourceUUIDs) == 0 {
+		return 0, nil
+	}
+
+	sqlInsert := "INSERT INTO files(package_id, name, file_type, s3_bucket, s3_key, object_type, size, checksum, " +
+		"uuid, processing_state, uploaded_state, properties, asset_type, provenance_id, created_at, updated_at) " +
+		"SELECT ids.copy_id, f.name, f.file_type, f.s3_bucket, f.s3_key, f.object_type, f.size, f.checksum, " +
+		"uuids.copy_uuid::uuid, f.processing_state, f.uploaded_state, f.properties, f.asset_type, f.uuid, $5, $5 " +
+		"FROM files f " +
+		"JOIN unnest($1::text[], $2::text[]) AS uuids(source_uuid, copy_uuid) ON f.uuid::text = uuids.source_uuid " +
+		"JOIN unnest($3::bigint[], $4::bigint[]) AS ids(source_id, copy_id) ON f.package_id = ids.source_id"
+
+	result, err := q.db.ExecContext(ctx, sqlInsert,
+		pq.Array(sourceUUIDs), pq.Array(copyUUIDs), pq.Array(sourceIds), pq.Array(copyIds), currentTime)
+	if err != nil {
+		return 0, mapError(fmt.Errorf("copying files: %w", err))
+	}
+
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return 0, mapError(err)
+	}
+	return int(affectedRows), nil
+}
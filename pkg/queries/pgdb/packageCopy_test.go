@@ -0,0 +1,139 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/objectType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageCopy is the main Test Suite function for copying Packages.
+func TestPackageCopy(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Copy folder tree to other dataset": testCopyFolderTree,
+		"Copy folder into own descendant":   testCopyIntoDescendant,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testCopyFolderTree(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+	defer test.Truncate(t, store.db, orgId, "package_storage")
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	nested := addTestFolder(t, store, "nested", folder.Id)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.edf", ParentId: nested.Id},
+		{Name: "pending.edf", ParentId: nested.Id},
+	}, 1))
+	assert.NoError(t, err)
+	// Only READY packages are copied, pending.edf stays UNAVAILABLE.
+	_, err = store.db.Exec("UPDATE packages SET state = $1 WHERE id = $2", packageState.Ready.String(), packages[0].Id)
+	assert.NoError(t, err)
+
+	sourceFileUUID := uuid.New()
+	_, err = store.AddFiles(ctx, []pgdb.FileParams{{
+		PackageId:  int(packages[0].Id),
+		Name:       "file.edf",
+		FileType:   fileType.EDF,
+		S3Bucket:   "test-bucket",
+		S3Key:      "test/key/file.edf",
+		ObjectType: objectType.Source,
+		Size:       100,
+		UUID:       sourceFileUUID,
+	}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.IncrementPackageStorage(ctx, packages[0].Id, 100))
+	assert.NoError(t, store.IncrementPackageStorageAncestors(ctx, nested.Id, 100))
+	assert.NoError(t, store.IncrementPackageStorage(ctx, packages[1].Id, 50))
+	assert.NoError(t, store.IncrementPackageStorageAncestors(ctx, nested.Id, 50))
+
+	result, err := store.CopyPackageTree(ctx, CopyPackageTreeParams{
+		OrganizationId:  int64(orgId),
+		SourcePackageId: folder.Id,
+		TargetDatasetId: 2,
+		TargetParentId:  -1,
+		OwnerId:         1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, result.Packages, 3)
+	assert.Equal(t, 1, result.FileCount)
+	assert.Equal(t, int64(100), result.Size)
+	assert.Equal(t, "folder", result.Root.Name)
+	assert.Equal(t, 2, result.Root.DatasetId)
+	assert.NotEqual(t, folder.NodeId, result.Root.NodeId, "copies should get a new node id")
+
+	copiedPackageId := result.PackageIdMap[packages[0].Id]
+	assert.NotZero(t, copiedPackageId)
+	assert.NotContains(t, result.PackageIdMap, packages[1].Id, "packages that are not READY should not be copied")
+
+	sourceId, err := store.GetPackageCopySourceId(ctx, copiedPackageId)
+	assert.NoError(t, err)
+	assert.Equal(t, packages[0].Id, sourceId)
+	_, err = store.GetPackageCopySourceId(ctx, packages[0].Id)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+
+	var s3Key, provenanceId string
+	err = store.db.QueryRow("SELECT s3_key, provenance_id FROM files WHERE package_id=$1", copiedPackageId).
+		Scan(&s3Key, &provenanceId)
+	assert.NoError(t, err)
+	assert.Equal(t, "test/key/file.edf", s3Key, "copied file should point at the same S3 object")
+	assert.Equal(t, sourceFileUUID.String(), provenanceId, "copied file should link back to its source")
+
+	copiedSize, err := store.GetPackageStorageById(ctx, result.PackageIdMap[nested.Id])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), copiedSize)
+
+	datasetSize, err := store.GetDatasetStorageById(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), datasetSize)
+
+	orgSize, err := store.GetOrganizationStorageById(ctx, int64(orgId))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), orgSize)
+
+	// Copying again results in a renamed root
+	result, err = store.CopyPackageTree(ctx, CopyPackageTreeParams{
+		OrganizationId:  int64(orgId),
+		SourcePackageId: folder.Id,
+		TargetDatasetId: 2,
+		TargetParentId:  -1,
+		OwnerId:         1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "folder (1)", result.Root.Name)
+}
+
+func testCopyIntoDescendant(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	nested := addTestFolder(t, store, "nested", folder.Id)
+
+	_, err := store.CopyPackageTree(ctx, CopyPackageTreeParams{
+		OrganizationId:  int64(orgId),
+		SourcePackageId: folder.Id,
+		TargetDatasetId: 1,
+		TargetParentId:  nested.Id,
+		OwnerId:         1,
+	})
+	assert.ErrorAs(t, err, &InvalidPackageCopyError{})
+}
@@ -0,0 +1,107 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/nodeId"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/uploadFolder"
+	"sort"
+	"strings"
+)
+
+// EnsureFolderPath makes sure that every folder in a slash-separated path exists in a dataset and
+// returns the last folder in the path. See EnsureFolders.
+func (q *Queries) EnsureFolderPath(ctx context.Context, datasetId int64, ownerId int, path string) (*uploadFolder.UploadFolder, error) {
+	folders, err := q.EnsureFolders(ctx, datasetId, ownerId, []string{path})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	folder, ok := folders[strings.Join(splitPackagePath(path), pathSeparator)]
+	if !ok {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "folder path cannot be empty")
+	}
+	return folder, nil
+}
+
+// EnsureFolders makes sure that every folder in the provided slash-separated paths exists in a dataset.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - Missing folders, including intermediate folders, are created in depth order.
+//   - Existing folders are reused through the ON CONFLICT logic of AddFolder.
+//   - An error is returned if a path segment resolves to a package that is not a folder.
+//
+// It returns an UploadFolderMap with an entry for every folder on the paths, keyed by the
+// normalized path (no leading, trailing or repeated slashes).
+func (q *Queries) EnsureFolders(ctx context.Context, datasetId int64, ownerId int, paths []string) (uploadFolder.UploadFolderMap, error) {
+	folders := uploadFolder.UploadFolderMap{}
+	for _, path := range paths {
+		segments := splitPackagePath(path)
+		for depth := range segments {
+			folderPath := strings.Join(segments[:depth+1], pathSeparator)
+			if _, ok := folders[folderPath]; ok {
+				continue
+			}
+			folders[folderPath] = &uploadFolder.UploadFolder{
+				Name:     segments[depth],
+				ParentId: -1,
+				Depth:    depth,
+			}
+		}
+	}
+
+	// Create parents before children
+	sortedPaths := make([]string, 0, len(folders))
+	for folderPath := range folders {
+		sortedPaths = append(sortedPaths, folderPath)
+	}
+	sort.Slice(sortedPaths, func(i, j int) bool {
+		if folders[sortedPaths[i]].Depth != folders[sortedPaths[j]].Depth {
+			return folders[sortedPaths[i]].Depth < folders[sortedPaths[j]].Depth
+		}
+		return sortedPaths[i] < sortedPaths[j]
+	})
+
+	for _, folderPath := range sortedPaths {
+		folder := folders[folderPath]
+
+		var parent *uploadFolder.UploadFolder
+		if folder.Depth > 0 {
+			parent = folders[folderPath[:strings.LastIndex(folderPath, pathSeparator)]]
+			folder.ParentId = parent.Id
+			folder.ParentNodeId = parent.NodeId
+		}
+
+		result, err := q.AddFolder(ctx, pgdb.PackageParams{
+			Name:         folder.Name,
+			PackageType:  packageType.Collection,
+			PackageState: packageState.Ready,
+			NodeId:       nodeId.NodeId(nodeId.CollectionCode),
+			ParentId:     folder.ParentId,
+			DatasetId:    int(datasetId),
+			OwnerId:      ownerId,
+			Attributes:   packageInfo.PackageAttributes{},
+		})
+		if err != nil {
+			return nil, mapError(fmt.Errorf("ensuring folder %q: %w", folderPath, err))
+		}
+		if result.PackageType != packageType.Collection {
+			return nil, dbErrors.New(dbErrors.ErrConflict, fmt.Sprintf("cannot create folder %q: a package with the same name exists", folderPath))
+		}
+		if isDeletedState(result.PackageState) {
+			return nil, dbErrors.New(dbErrors.ErrConflict, fmt.Sprintf("cannot create folder %q: folder is deleted", folderPath))
+		}
+
+		folder.Id = result.Id
+		folder.NodeId = result.NodeId
+		if parent != nil {
+			parent.Children = append(parent.Children, folder)
+		}
+	}
+
+	return folders, nil
+}
@@ -0,0 +1,68 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageFolders is the main Test Suite function for ensuring folder paths.
+func TestPackageFolders(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Ensure folders":               testEnsureFolders,
+		"Ensure folder path over file": testEnsureFolderPathOverFile,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testEnsureFolders(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	existing := addTestFolder(t, store, "a", -1)
+
+	folders, err := store.EnsureFolders(ctx, 1, 1, []string{"a/b/c", "/a/d/", "e"})
+	assert.NoError(t, err)
+	assert.Len(t, folders, 5)
+
+	assert.Equal(t, existing.Id, folders["a"].Id, "existing folder should be reused")
+	assert.Equal(t, existing.NodeId, folders["a"].NodeId)
+	assert.Equal(t, int64(-1), folders["a"].ParentId)
+	assert.Equal(t, "", folders["a"].ParentNodeId)
+	assert.Len(t, folders["a"].Children, 2)
+
+	assert.Equal(t, folders["a"].Id, folders["a/b"].ParentId)
+	assert.Equal(t, folders["a/b"].Id, folders["a/b/c"].ParentId)
+	assert.Equal(t, folders["a/b"].NodeId, folders["a/b/c"].ParentNodeId)
+	assert.Equal(t, 2, folders["a/b/c"].Depth)
+
+	p, err := store.GetPackageByPath(ctx, 1, "a/b/c")
+	assert.NoError(t, err)
+	assert.Equal(t, folders["a/b/c"].Id, p.Id)
+
+	// Calling again returns the same folders
+	folder, err := store.EnsureFolderPath(ctx, 1, 1, "a/b/c")
+	assert.NoError(t, err)
+	assert.Equal(t, folders["a/b/c"].Id, folder.Id)
+}
+
+func testEnsureFolderPathOverFile(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.EnsureFolderPath(ctx, 1, 1, "a/b")
+	assert.Error(t, err, "a file cannot be used as a folder")
+}
@@ -0,0 +1,229 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// PackageSortField is the field used to order a listing of packages.
+type PackageSortField string
+
+const (
+	SortByName      PackageSortField = "name"
+	SortBySize      PackageSortField = "size"
+	SortByCreatedAt PackageSortField = "created_at"
+	SortByUpdatedAt PackageSortField = "updated_at"
+)
+
+const (
+	defaultPackageListLimit = 100
+	maxPackageListLimit     = 1000
+)
+
+// packageSortExpressions maps each sort field onto the SQL expression used for ordering.
+// Size uses the package_storage size so folders are ordered by the size of their contents.
+var packageSortExpressions = map[PackageSortField]string{
+	SortByName:      "p.name",
+	SortBySize:      "COALESCE(storage.size, p.size, 0)",
+	SortByCreatedAt: "p.created_at",
+	SortByUpdatedAt: "p.updated_at",
+}
+
+type InvalidContinuationTokenError struct {
+	ErrorMessage string
+}
+
+func (e InvalidContinuationTokenError) Error() string {
+	return fmt.Sprintf("invalid continuation token (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidContinuationTokenError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// ListPackageChildrenParams is the input for ListPackageChildren.
+// ParentId is not optional and -1 refers to the root folder.
+type ListPackageChildrenParams struct {
+	DatasetId         int64
+	ParentId          int64
+	SortBy            PackageSortField // Defaults to SortByName.
+	Descending        bool
+	Limit             int                  // Defaults to 100, capped at 1000.
+	ContinuationToken string               // Token returned by the previous page, empty for the first page.
+	Types             []packageType.Type   // Empty matches all types.
+	States            []packageState.State // Empty matches all states except DELETING and DELETED.
+	NamePrefix        string
+	OwnerId           int // 0 matches all owners.
+}
+
+// ListPackageChildrenResponse is a single page of ListPackageChildren results.
+type ListPackageChildrenResponse struct {
+	Packages          []pgdb.Package `json:"packages"`
+	ContinuationToken string         `json:"continuation_token"` // Empty if this is the last page.
+}
+
+// ListPackageChildren returns a page of the children of a folder, ordered by the requested field
+// and filtered by type, state, name prefix and owner.
+// Pages are keyed on the sort value and package id of the last returned package, so results stay
+// consistent while packages are added or removed.
+func (q *Queries) ListPackageChildren(ctx context.Context, params ListPackageChildrenParams) (*ListPackageChildrenResponse, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = SortByName
+	}
+	sortExpr, ok := packageSortExpressions[sortBy]
+	if !ok {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown sort field: %s", sortBy))
+	}
+
+	page := keysetPage{
+		sortBy:     string(sortBy),
+		sortExpr:   sortExpr,
+		idExpr:     "p.id",
+		kind:       packageSortKind(sortBy),
+		descending: params.Descending,
+		limit:      keysetLimit(params.Limit, defaultPackageListLimit, maxPackageListLimit),
+	}
+
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	predicates := []string{fmt.Sprintf("p.dataset_id = %s", arg(params.DatasetId))}
+	if params.ParentId < 0 {
+		predicates = append(predicates, "p.parent_id IS NULL")
+	} else {
+		predicates = append(predicates, fmt.Sprintf("p.parent_id = %s", arg(params.ParentId)))
+	}
+
+	if len(params.States) > 0 {
+		states := make([]string, len(params.States))
+		for i, s := range params.States {
+			states[i] = s.String()
+		}
+		predicates = append(predicates, fmt.Sprintf("p.state = ANY(%s)", arg(pq.Array(states))))
+	} else {
+		predicates = append(predicates, fmt.Sprintf("p.state NOT IN (%s, %s)",
+			arg(packageState.Deleting.String()), arg(packageState.Deleted.String())))
+	}
+
+	if len(params.Types) > 0 {
+		types := make([]string, len(params.Types))
+		for i, t := range params.Types {
+			types[i] = t.String()
+		}
+		predicates = append(predicates, fmt.Sprintf("p.type = ANY(%s)", arg(pq.Array(types))))
+	}
+
+	if params.NamePrefix != "" {
+		predicates = append(predicates, fmt.Sprintf("p.name LIKE %s", arg(escapeLike(params.NamePrefix)+"%")))
+	}
+
+	if params.OwnerId != 0 {
+		predicates = append(predicates, fmt.Sprintf("p.owner_id = %s", arg(params.OwnerId)))
+	}
+
+	after, err := page.after(params.ContinuationToken, arg)
+	if err != nil {
+		return nil, err
+	}
+	if after != "" {
+		predicates = append(predicates, after)
+	}
+
+	queryStr := fmt.Sprintf("SELECT %s, COALESCE(storage.size, p.size, 0) FROM packages p "+
+		"LEFT JOIN package_storage storage ON storage.package_id = p.id "+
+		"WHERE %s %s",
+		packageColumnsWithAlias("p"), strings.Join(predicates, " AND "), page.orderBy(arg))
+
+	rows, err := q.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		log.Error("Error listing package children: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	response := ListPackageChildrenResponse{}
+	var sizes []int64
+	for rows.Next() {
+		var p pgdb.Package
+		var size int64
+		err = rows.Scan(
+			&p.Id,
+			&p.Name,
+			&p.PackageType,
+			&p.PackageState,
+			&p.NodeId,
+			&p.ParentId,
+			&p.DatasetId,
+			&p.OwnerId,
+			&p.Size,
+			&p.ImportId,
+			&p.Attributes,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+			&p.ReplacesPackageId,
+			&p.ReplacedByPackageId,
+			&size,
+		)
+		if err != nil {
+			log.Error("Error scanning package child: ", err)
+			return nil, mapError(err)
+		}
+
+		response.Packages = append(response.Packages, p)
+		sizes = append(sizes, size)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	if page.hasMore(len(response.Packages)) {
+		response.Packages = response.Packages[:page.limit]
+		last := response.Packages[page.limit-1]
+		var value interface{}
+		switch sortBy {
+		case SortBySize:
+			value = sizes[page.limit-1]
+		case SortByCreatedAt:
+			value = last.CreatedAt
+		case SortByUpdatedAt:
+			value = last.UpdatedAt
+		default:
+			value = last.Name
+		}
+		response.ContinuationToken, err = page.continuationToken(last.Id, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+// packageSortKind returns the type of the sort value of a sort field.
+func packageSortKind(sortBy PackageSortField) keysetValueKind {
+	switch sortBy {
+	case SortBySize:
+		return keysetInt
+	case SortByCreatedAt, SortByUpdatedAt:
+		return keysetTime
+	default:
+		return keysetString
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in a literal so it can be used as a pattern prefix.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,131 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageListing is the main Test Suite function for listing Package children.
+func TestPackageListing(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"List children in pages":    testListChildrenPages,
+		"List children with filter": testListChildrenFilters,
+		"List children bad token":   testListChildrenBadToken,
+		"Escape like":               testEscapeLike,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testListChildrenPages(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	var params []test.PackageParams
+	for i := 0; i < 25; i++ {
+		params = append(params, test.PackageParams{Name: fmt.Sprintf("file_%02d.txt", i), ParentId: folder.Id})
+	}
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages(params, 1))
+	assert.NoError(t, err)
+
+	var names []string
+	token := ""
+	pages := 0
+	for {
+		page, err := store.ListPackageChildren(ctx, ListPackageChildrenParams{
+			DatasetId:         1,
+			ParentId:          folder.Id,
+			SortBy:            SortByName,
+			Descending:        true,
+			Limit:             10,
+			ContinuationToken: token,
+		})
+		assert.NoError(t, err)
+		pages++
+		for _, p := range page.Packages {
+			names = append(names, p.Name)
+		}
+		token = page.ContinuationToken
+		if token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, 3, pages)
+	assert.Len(t, names, 25)
+	assert.Equal(t, "file_24.txt", names[0])
+	assert.Equal(t, "file_00.txt", names[24])
+}
+
+func testListChildrenFilters(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	addTestFolder(t, store, "data_folder", -1)
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "data_1.txt", ParentId: -1},
+		{Name: "data%.txt", ParentId: -1},
+		{Name: "other.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	page, err := store.ListPackageChildren(ctx, ListPackageChildrenParams{
+		DatasetId:  1,
+		ParentId:   -1,
+		NamePrefix: "data_",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, page.Packages, 2, "underscore in prefix should match literally")
+	assert.Empty(t, page.ContinuationToken)
+
+	page, err = store.ListPackageChildren(ctx, ListPackageChildrenParams{
+		DatasetId: 1,
+		ParentId:  -1,
+		Types:     []packageType.Type{packageType.Collection},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, page.Packages, 1)
+	assert.Equal(t, "data_folder", page.Packages[0].Name)
+
+	page, err = store.ListPackageChildren(ctx, ListPackageChildrenParams{
+		DatasetId: 1,
+		ParentId:  -1,
+		OwnerId:   2,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Packages)
+}
+
+func testListChildrenBadToken(t *testing.T, store *SQLStore, _ int) {
+	_, err := store.ListPackageChildren(context.Background(), ListPackageChildrenParams{
+		DatasetId:         1,
+		ParentId:          -1,
+		ContinuationToken: "not a token",
+	})
+	assert.ErrorAs(t, err, &InvalidContinuationTokenError{})
+
+	token, err := keysetPage{sortBy: string(SortBySize), kind: keysetInt}.continuationToken(1, int64(10))
+	assert.NoError(t, err)
+	_, err = store.ListPackageChildren(context.Background(), ListPackageChildrenParams{
+		DatasetId:         1,
+		ParentId:          -1,
+		SortBy:            SortByName,
+		ContinuationToken: token,
+	})
+	assert.ErrorAs(t, err, &InvalidContinuationTokenError{}, "token for a different sort field should be rejected")
+}
+
+func testEscapeLike(t *testing.T, _ *SQLStore, _ int) {
+	assert.Equal(t, `a\_b\%c\\d`, escapeLike(`a_b%c\d`))
+}
@@ -0,0 +1,316 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type InvalidPackageMoveError struct {
+	ErrorMessage string
+}
+
+func (e InvalidPackageMoveError) Error() string {
+	return fmt.Sprintf("invalid package move (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidPackageMoveError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// MovePackages moves packages to a new parent folder within the same dataset.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - Set targetParentId to -1 to move packages to the root of the dataset.
+//   - Folders are moved together with their subtree. Moving a folder into itself or
+//     into one of its descendants returns an InvalidPackageMoveError.
+//   - Name collisions at the destination are resolved using the provided strategy.
+//   - package_storage is decremented for the old ancestors and incremented for the new ancestors.
+//     The size of packages removed by the Replace strategy is also removed from the organization.
+//
+// It returns the moved packages with their updated name and parent.
+func (q *Queries) MovePackages(ctx context.Context, organizationId int64, datasetId int64, packageIds []int64, targetParentId int64, strategy conflictStrategy.Strategy) ([]pgdb.Package, error) {
+	if len(packageIds) == 0 {
+		return nil, nil
+	}
+
+	sqlParentId := sql.NullInt64{Valid: false}
+	if targetParentId >= 0 {
+		target, err := q.GetPackageById(ctx, targetParentId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, InvalidPackageMoveError{fmt.Sprintf("destination %d does not exist", targetParentId)}
+			}
+			return nil, mapError(err)
+		}
+		if int64(target.DatasetId) != datasetId {
+			return nil, InvalidPackageMoveError{fmt.Sprintf("destination %d is not in dataset %d", targetParentId, datasetId)}
+		}
+		if target.PackageType != packageType.Collection {
+			return nil, InvalidPackageMoveError{fmt.Sprintf("destination %d is not a folder", targetParentId)}
+		}
+		if isDeletedState(target.PackageState) {
+			return nil, InvalidPackageMoveError{fmt.Sprintf("destination %d is deleted", targetParentId)}
+		}
+		sqlParentId = sql.NullInt64{Int64: targetParentId, Valid: true}
+	}
+
+	// Moving a package into its own subtree would disconnect it from the dataset root.
+	var targetAncestors []int64
+	if targetParentId >= 0 {
+		var err error
+		targetAncestors, err = q.GetPackageAncestorIds(ctx, targetParentId)
+		if err != nil {
+			return nil, mapError(err)
+		}
+	}
+
+	var toMove []pgdb.Package
+	var unchanged []pgdb.Package
+	for _, id := range packageIds {
+		for _, ancestorId := range targetAncestors {
+			if ancestorId == id {
+				return nil, InvalidPackageMoveError{fmt.Sprintf("cannot move package %d into itself or one of its descendants", id)}
+			}
+		}
+
+		p, err := q.GetPackageById(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, InvalidPackageMoveError{fmt.Sprintf("package %d does not exist", id)}
+			}
+			return nil, mapError(err)
+		}
+		if int64(p.DatasetId) != datasetId {
+			return nil, InvalidPackageMoveError{fmt.Sprintf("package %d is not in dataset %d", id, datasetId)}
+		}
+		if isDeletedState(p.PackageState) {
+			return nil, InvalidPackageMoveError{fmt.Sprintf("package %d is deleted", id)}
+		}
+
+		if p.ParentId == sqlParentId {
+			unchanged = append(unchanged, *p)
+			continue
+		}
+		toMove = append(toMove, *p)
+	}
+
+	if len(toMove) == 0 {
+		return unchanged, nil
+	}
+
+	var names map[int64]string
+	var replaced map[int64]*pgdb.Package
+	var replacedSize int64
+	var err error
+	switch strategy {
+	case conflictStrategy.KeepBoth:
+		names, err = q.resolveKeepBothNames(ctx, datasetId, targetParentId, toMove)
+	case conflictStrategy.Replace:
+		names, replaced, replacedSize, err = q.resolveReplaceConflicts(ctx, datasetId, targetParentId, toMove)
+	case conflictStrategy.Fail:
+		names, err = q.resolveFailConflicts(ctx, datasetId, targetParentId, toMove)
+	default:
+		return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown conflict strategy: %s", strategy))
+	}
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if replacedSize > 0 {
+		if err := q.DecrementOrganizationStorage(ctx, organizationId, replacedSize); err != nil {
+			return nil, mapError(fmt.Errorf("decrementing organization storage for replaced packages: %w", err))
+		}
+	}
+
+	currentTime := time.Now()
+	movedPackages := unchanged
+	for _, p := range toMove {
+		size, err := q.GetPackageStorageById(ctx, p.Id)
+		if err != nil {
+			// No storage row just means nothing to move between ancestor chains.
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, mapError(fmt.Errorf("reading storage for package %d: %w", p.Id, err))
+			}
+			size = 0
+		}
+
+		if size > 0 && p.ParentId.Valid {
+			if err := q.DecrementPackageStorageAncestors(ctx, p.ParentId.Int64, size); err != nil {
+				return nil, mapError(fmt.Errorf("decrementing old ancestor storage for package %d: %w", p.Id, err))
+			}
+		}
+
+		queryStr := fmt.Sprintf("UPDATE packages SET parent_id=$1, name=$2, updated_at=$3 WHERE id=$4 RETURNING %s", packageColumns)
+		moved, err := scanPackage(q.db.QueryRowContext(ctx, queryStr, sqlParentId, names[p.Id], currentTime, p.Id))
+		if err != nil {
+			log.Error("Error moving package: ", err)
+			return nil, mapError(fmt.Errorf("moving package %d: %w", p.Id, err))
+		}
+
+		if size > 0 && targetParentId >= 0 {
+			if err := q.IncrementPackageStorageAncestors(ctx, targetParentId, size); err != nil {
+				return nil, mapError(fmt.Errorf("incrementing new ancestor storage for package %d: %w", p.Id, err))
+			}
+		}
+
+		if old, ok := replaced[p.Id]; ok {
+			if err := q.linkReplacedPackage(ctx, old.Id, moved.Id); err != nil {
+				return nil, mapError(err)
+			}
+			moved.ReplacesPackageId = sql.NullInt64{Int64: old.Id, Valid: true}
+		}
+
+		movedPackages = append(movedPackages, *moved)
+	}
+
+	return movedPackages, nil
+}
+
+// resolveKeepBothNames returns the name each package should have at the
+// destination, appending " (N)" until it no longer collides with an existing
+// package or with another package in the same move.
+func (q *Queries) resolveKeepBothNames(ctx context.Context, datasetId int64, parentId int64, packages []pgdb.Package) (map[int64]string, error) {
+	names := map[int64]string{}
+	taken := map[string]bool{}
+
+	pending := packages
+	index := 0
+	for len(pending) > 0 {
+		candidates := make([]pgdb.PackageParams, len(pending))
+		for i, p := range pending {
+			candidates[i] = pgdb.PackageParams{Name: p.Name, DatasetId: int(datasetId), ParentId: parentId}
+			if index > 0 {
+				expandName(&candidates[i], p.Name, index)
+			}
+		}
+
+		conflicts, err := q.findConflictingPackages(ctx, parentId, candidates)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		var next []pgdb.Package
+		for i, p := range pending {
+			name := candidates[i].Name
+			if _, exists := conflicts[name]; exists || taken[name] {
+				next = append(next, p)
+				continue
+			}
+			taken[name] = true
+			names[p.Id] = name
+		}
+
+		pending = next
+		index++
+	}
+	return names, nil
+}
+
+// resolveFailConflicts returns the unchanged name of each package, or a PackageNameConflictError if a
+// package collides with an existing package at the destination or with another package in the same call.
+func (q *Queries) resolveFailConflicts(ctx context.Context, datasetId int64, parentId int64, packages []pgdb.Package) (map[int64]string, error) {
+	names := map[int64]string{}
+	candidates := make([]pgdb.PackageParams, len(packages))
+	for i, p := range packages {
+		names[p.Id] = p.Name
+		candidates[i] = pgdb.PackageParams{Name: p.Name, DatasetId: int(datasetId), ParentId: parentId}
+	}
+	if err := q.checkNameConflicts(ctx, parentId, candidates); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// checkNameConflicts returns a PackageNameConflictError listing the names of the records that collide with an
+// existing package under parentId, or with another record. All records must be in the same dataset.
+func (q *Queries) checkNameConflicts(ctx context.Context, parentId int64, records []pgdb.PackageParams) error {
+	conflicts, err := q.findConflictingPackages(ctx, parentId, records)
+	if err != nil {
+		return mapError(err)
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range records {
+		if _, exists := conflicts[r.Name]; exists || seen[r.Name] {
+			names = append(names, fmt.Sprintf("%q", r.Name))
+		}
+		seen[r.Name] = true
+	}
+	if len(names) > 0 {
+		return PackageNameConflictError{fmt.Sprintf("packages named %s already exist", strings.Join(names, ", "))}
+	}
+	return nil
+}
+
+// resolveReplaceConflicts soft-deletes each existing package at the destination
+// whose name collides with a package being moved. Folders cannot be replaced.
+// It returns the destination name of each package, the predecessor it replaces, and the
+// storage size removed from the dataset with the predecessors.
+func (q *Queries) resolveReplaceConflicts(ctx context.Context, datasetId int64, parentId int64, packages []pgdb.Package) (map[int64]string, map[int64]*pgdb.Package, int64, error) {
+	names := map[int64]string{}
+	candidates := make([]pgdb.PackageParams, len(packages))
+	seen := map[string]bool{}
+	for i, p := range packages {
+		if seen[p.Name] {
+			return nil, nil, 0, InvalidPackageMoveError{fmt.Sprintf("multiple packages named %q are being moved to the same folder", p.Name)}
+		}
+		seen[p.Name] = true
+		names[p.Id] = p.Name
+		candidates[i] = pgdb.PackageParams{Name: p.Name, DatasetId: int(datasetId), ParentId: parentId}
+	}
+
+	conflicts, err := q.findConflictingPackages(ctx, parentId, candidates)
+	if err != nil {
+		return nil, nil, 0, mapError(err)
+	}
+
+	replaced := map[int64]*pgdb.Package{}
+	var replacedSize int64
+	for _, p := range packages {
+		old, ok := conflicts[p.Name]
+		if !ok {
+			continue
+		}
+		if p.PackageType == packageType.Collection || old.PackageType == packageType.Collection {
+			return nil, nil, 0, InvalidPackageMoveError{fmt.Sprintf("folder %q cannot be replaced", p.Name)}
+		}
+		size, err := q.softDeletePredecessor(ctx, datasetId, old)
+		if err != nil {
+			return nil, nil, 0, mapError(err)
+		}
+		replaced[p.Id] = old
+		replacedSize += size
+	}
+
+	return names, replaced, replacedSize, nil
+}
+
+// linkReplacedPackage records that the package with id replacementId replaces the package with id oldId.
+func (q *Queries) linkReplacedPackage(ctx context.Context, oldId int64, replacementId int64) error {
+	_, err := q.db.ExecContext(ctx,
+		"UPDATE packages SET replaces_package_id=$1 WHERE id=$2", oldId, replacementId)
+	if err != nil {
+		return mapError(fmt.Errorf("setting replaces_package_id on package %d: %w", replacementId, err))
+	}
+	_, err = q.db.ExecContext(ctx,
+		"UPDATE packages SET replaced_by_package_id=$1 WHERE id=$2", replacementId, oldId)
+	if err != nil {
+		return mapError(fmt.Errorf("setting back-ref on predecessor %d: %w", oldId, err))
+	}
+	return nil
+}
+
+// isDeletedState returns true if the package is deleted or being deleted.
+func isDeletedState(s packageState.State) bool {
+	return s == packageState.Deleting || s == packageState.Deleted
+}
@@ -0,0 +1,216 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageMove is the main Test Suite function for moving Packages.
+func TestPackageMove(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Move package into folder":        testMovePackageIntoFolder,
+		"Move folder into own descendant": testMoveFolderIntoDescendant,
+		"Move with keep-both conflict":    testMoveKeepBoth,
+		"Move with replace conflict":      testMoveReplace,
+		"Move with fail conflict":         testMoveFail,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+// addTestFolder adds a folder with the given name and parent to dataset 1.
+func addTestFolder(t *testing.T, store *SQLStore, name string, parentId int64) *pgdb.Package {
+	uploadId, _ := uuid.NewUUID()
+	folder, err := store.Queries.AddFolder(context.Background(), pgdb.PackageParams{
+		Name:         name,
+		PackageType:  packageType.Collection,
+		PackageState: packageState.Ready,
+		NodeId:       fmt.Sprintf("N:collection:%s", uploadId.String()),
+		ParentId:     parentId,
+		DatasetId:    1,
+		OwnerId:      1,
+		ImportId:     sql.NullString{String: uploadId.String(), Valid: true},
+		Attributes:   []packageInfo.PackageAttribute{},
+	})
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting folder", err)
+	}
+	return folder
+}
+
+func testMovePackageIntoFolder(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "package_storage")
+
+	ctx := context.Background()
+	source := addTestFolder(t, store, "source", -1)
+	destination := addTestFolder(t, store, "destination", -1)
+
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: source.Id},
+	}, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, store.IncrementPackageStorage(ctx, packages[0].Id, 100))
+	assert.NoError(t, store.IncrementPackageStorageAncestors(ctx, source.Id, 100))
+
+	moved, err := store.MovePackages(ctx, int64(orgId), 1, []int64{packages[0].Id}, destination.Id, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, destination.Id, moved[0].ParentId.Int64, "package should be re-parented")
+	assert.Equal(t, "file.txt", moved[0].Name)
+
+	sourceSize, err := store.GetPackageStorageById(ctx, source.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sourceSize, "old parent storage should be decremented")
+
+	destinationSize, err := store.GetPackageStorageById(ctx, destination.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), destinationSize, "new parent storage should be incremented")
+
+	packageSize, err := store.GetPackageStorageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), packageSize, "moved package storage should be unchanged")
+
+	// Move back to root
+	moved, err = store.MovePackages(ctx, int64(orgId), 1, []int64{packages[0].Id}, -1, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.False(t, moved[0].ParentId.Valid, "package should be in root")
+
+	destinationSize, err = store.GetPackageStorageById(ctx, destination.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), destinationSize)
+}
+
+func testMoveFolderIntoDescendant(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	parent := addTestFolder(t, store, "parent", -1)
+	child := addTestFolder(t, store, "child", parent.Id)
+	grandChild := addTestFolder(t, store, "grandchild", child.Id)
+
+	_, err := store.MovePackages(ctx, int64(orgId), 1, []int64{parent.Id}, grandChild.Id, conflictStrategy.KeepBoth)
+	assert.ErrorAs(t, err, &InvalidPackageMoveError{}, "moving a folder into its descendant should fail")
+
+	_, err = store.MovePackages(ctx, int64(orgId), 1, []int64{parent.Id}, parent.Id, conflictStrategy.KeepBoth)
+	assert.ErrorAs(t, err, &InvalidPackageMoveError{}, "moving a folder into itself should fail")
+
+	moved, err := store.MovePackages(ctx, int64(orgId), 1, []int64{grandChild.Id}, -1, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.False(t, moved[0].ParentId.Valid, "moving a descendant up should succeed")
+}
+
+func testMoveKeepBoth(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	source := addTestFolder(t, store, "source", -1)
+	destination := addTestFolder(t, store, "destination", -1)
+
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: destination.Id},
+	}, 1))
+	assert.NoError(t, err)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: source.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	moved, err := store.MovePackages(ctx, int64(orgId), 1, []int64{packages[0].Id}, destination.Id, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, "file (1).txt", moved[0].Name, "conflicting name should be expanded")
+}
+
+func testMoveReplace(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "package_storage")
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	ctx := context.Background()
+	source := addTestFolder(t, store, "source", -1)
+	destination := addTestFolder(t, store, "destination", -1)
+
+	existing, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: destination.Id},
+	}, 1))
+	assert.NoError(t, err)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: source.Id},
+	}, 1))
+	assert.NoError(t, err)
+	for _, p := range []pgdb.Package{existing[0], packages[0]} {
+		assert.NoError(t, store.IncrementPackageStorage(ctx, p.Id, 100))
+		assert.NoError(t, store.IncrementPackageStorageAncestors(ctx, p.ParentId.Int64, 100))
+		assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 100))
+		assert.NoError(t, store.IncrementOrganizationStorage(ctx, int64(orgId), 100))
+	}
+
+	moved, err := store.MovePackages(ctx, int64(orgId), 1, []int64{packages[0].Id}, destination.Id, conflictStrategy.Replace)
+	assert.NoError(t, err)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, "file.txt", moved[0].Name, "moved package keeps its name")
+	assert.Equal(t, existing[0].Id, moved[0].ReplacesPackageId.Int64)
+
+	predecessor, err := store.GetPackageById(ctx, existing[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Deleting, predecessor.PackageState)
+	assert.Contains(t, predecessor.Name, "__DELETED__")
+
+	// Only the moved package is still stored
+	datasetSize, err := store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), datasetSize)
+	orgSize, err := store.GetOrganizationStorageById(ctx, int64(orgId))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), orgSize)
+
+	// Folders cannot be replaced
+	addTestFolder(t, store, "folder", destination.Id)
+	folder := addTestFolder(t, store, "folder", source.Id)
+	_, err = store.MovePackages(ctx, int64(orgId), 1, []int64{folder.Id}, destination.Id, conflictStrategy.Replace)
+	assert.ErrorAs(t, err, &InvalidPackageMoveError{})
+}
+
+func testMoveFail(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	destination := addTestFolder(t, store, "destination", -1)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: -1},
+		{Name: "other.txt", ParentId: -1},
+		{Name: "file.txt", ParentId: destination.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.MovePackages(ctx, int64(orgId), 1, []int64{packages[0].Id, packages[1].Id}, destination.Id, conflictStrategy.Fail)
+	assert.IsType(t, PackageNameConflictError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+
+	unmoved, err := store.GetPackageById(ctx, packages[1].Id)
+	assert.NoError(t, err)
+	assert.False(t, unmoved.ParentId.Valid, "nothing is moved if a name conflicts")
+
+	moved, err := store.MovePackages(ctx, int64(orgId), 1, []int64{packages[1].Id}, destination.Id, conflictStrategy.Fail)
+	assert.NoError(t, err)
+	assert.Equal(t, "other.txt", moved[0].Name)
+}
@@ -0,0 +1,80 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"strings"
+)
+
+// pathSeparator separates package names in a package path.
+const pathSeparator = "/"
+
+// GetPackageByPath returns the non-deleted package at the provided slash-separated path in a dataset,
+// for example "folder/nested/file.edf".
+//   - Leading, trailing and repeated slashes are ignored.
+//   - The path is resolved in a single query.
+//
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no package exists at the path.
+func (q *Queries) GetPackageByPath(ctx context.Context, datasetId int64, path string) (*pgdb.Package, error) {
+	segments := splitPackagePath(path)
+	if len(segments) == 0 {
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "package path cannot be empty")
+	}
+
+	queryStr := "" +
+		"WITH RECURSIVE walk(id, depth) AS (" +
+		"SELECT id, 1 FROM packages " +
+		"WHERE dataset_id = $1 AND parent_id IS NULL AND name = ($2::text[])[1] AND state NOT IN ($3, $4) " +
+		"UNION ALL " +
+		"SELECT children.id, walk.depth + 1 FROM packages children " +
+		"JOIN walk ON children.parent_id = walk.id " +
+		"WHERE walk.depth < cardinality($2::text[]) " +
+		"AND children.name = ($2::text[])[walk.depth + 1] AND children.state NOT IN ($3, $4)" +
+		") " +
+		fmt.Sprintf("SELECT %s FROM walk JOIN packages p ON p.id = walk.id ", packageColumnsWithAlias("p")) +
+		"WHERE walk.depth = cardinality($2::text[])"
+
+	return scanPackage(q.db.QueryRowContext(ctx, queryStr, datasetId, pq.Array(segments),
+		packageState.Deleting.String(), packageState.Deleted.String()))
+}
+
+// GetPackagePath returns the slash-separated path of a package from the root of its dataset,
+// for example "folder/nested/file.edf".
+// Returns ("", err) with errors.Is(err, dbErrors.ErrNotFound) if no such package exists.
+func (q *Queries) GetPackagePath(ctx context.Context, packageId int64) (string, error) {
+	queryStr := "" +
+		"WITH RECURSIVE ancestors(id, parent_id, name, depth) AS (" +
+		"SELECT id, parent_id, name, 0 FROM packages WHERE id = $1 " +
+		"UNION ALL " +
+		"SELECT parents.id, parents.parent_id, parents.name, ancestors.depth + 1 " +
+		"FROM packages parents " +
+		"JOIN ancestors ON ancestors.parent_id = parents.id" +
+		") " +
+		"SELECT string_agg(name, $2 ORDER BY depth DESC) FROM ancestors"
+
+	var path sql.NullString
+	err := q.db.QueryRowContext(ctx, queryStr, packageId, pathSeparator).Scan(&path)
+	if err != nil {
+		return "", mapError(err)
+	}
+	if !path.Valid {
+		return "", mapError(sql.ErrNoRows)
+	}
+	return path.String, nil
+}
+
+// splitPackagePath splits a slash-separated path into package names, ignoring empty segments.
+func splitPackagePath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, pathSeparator) {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,84 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackagePaths is the main Test Suite function for Package paths.
+func TestPackagePaths(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Get package by path": testGetPackageByPath,
+		"Get package path":    testGetPackagePath,
+		"Split package path":  testSplitPackagePath,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testGetPackageByPath(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "a", -1)
+	nested := addTestFolder(t, store, "b", folder.Id)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "c.edf", ParentId: nested.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	p, err := store.GetPackageByPath(ctx, 1, "a/b/c.edf")
+	assert.NoError(t, err)
+	assert.Equal(t, packages[0].Id, p.Id)
+
+	p, err = store.GetPackageByPath(ctx, 1, "/a/b/")
+	assert.NoError(t, err)
+	assert.Equal(t, nested.Id, p.Id, "leading and trailing slashes are ignored")
+
+	_, err = store.GetPackageByPath(ctx, 1, "a/c.edf")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.GetPackageByPath(ctx, 2, "a/b/c.edf")
+	assert.ErrorIs(t, err, sql.ErrNoRows, "path should not resolve in other dataset")
+
+	_, err = store.GetPackageByPath(ctx, 1, "/")
+	assert.Error(t, err)
+}
+
+func testGetPackagePath(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "a", -1)
+	nested := addTestFolder(t, store, "b", folder.Id)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "c.edf", ParentId: nested.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	path, err := store.GetPackagePath(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "a/b/c.edf", path)
+
+	path, err = store.GetPackagePath(ctx, folder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", path)
+
+	_, err = store.GetPackagePath(ctx, -1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testSplitPackagePath(t *testing.T, _ *SQLStore, _ int) {
+	assert.Equal(t, []string{"a", "b", "c.edf"}, splitPackagePath("a/b/c.edf"))
+	assert.Equal(t, []string{"a", "b"}, splitPackagePath("/a//b/"))
+	assert.Empty(t, splitPackagePath("/"))
+}
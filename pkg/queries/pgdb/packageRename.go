@@ -0,0 +1,145 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+type InvalidPackageRenameError struct {
+	ErrorMessage string
+}
+
+func (e InvalidPackageRenameError) Error() string {
+	return fmt.Sprintf("invalid package rename (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidPackageRenameError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+type PackageNameConflictError struct {
+	ErrorMessage string
+}
+
+func (e PackageNameConflictError) Error() string {
+	return fmt.Sprintf("package name conflict (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrConflict.
+func (e PackageNameConflictError) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
+
+// RenamePackage changes the name of a package.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - Name collisions with other packages in the same folder are resolved using the provided
+//     strategy: KeepBoth appends " (N)" to the new name, Fail returns a PackageNameConflictError.
+//   - The updated_at of the package and the dataset are set to the current time.
+//
+// It returns the renamed package and a RenamePackage changelog event.
+func (q *Queries) RenamePackage(ctx context.Context, datasetId int64, packageId int64, name string, strategy conflictStrategy.Strategy) (*pgdb.Package, *changelog.Event, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil, InvalidPackageRenameError{"name cannot be empty"}
+	}
+	if strings.Contains(name, pathSeparator) {
+		return nil, nil, InvalidPackageRenameError{fmt.Sprintf("name %q cannot contain %q", name, pathSeparator)}
+	}
+	if strings.HasPrefix(name, deletedNamePrefix) {
+		return nil, nil, InvalidPackageRenameError{fmt.Sprintf("name cannot start with %q", deletedNamePrefix)}
+	}
+
+	p, err := q.GetPackageById(ctx, packageId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, InvalidPackageRenameError{fmt.Sprintf("package %d does not exist", packageId)}
+		}
+		return nil, nil, mapError(err)
+	}
+	if int64(p.DatasetId) != datasetId {
+		return nil, nil, InvalidPackageRenameError{fmt.Sprintf("package %d is not in dataset %d", packageId, datasetId)}
+	}
+	if isDeletedState(p.PackageState) {
+		return nil, nil, InvalidPackageRenameError{fmt.Sprintf("package %d is deleted", packageId)}
+	}
+
+	oldName := p.Name
+	if name == oldName {
+		return p, nil, nil
+	}
+
+	parentId := int64(-1)
+	if p.ParentId.Valid {
+		parentId = p.ParentId.Int64
+	}
+
+	candidate := *p
+	candidate.Name = name
+	switch strategy {
+	case conflictStrategy.KeepBoth:
+		names, err := q.resolveKeepBothNames(ctx, datasetId, parentId, []pgdb.Package{candidate})
+		if err != nil {
+			return nil, nil, mapError(err)
+		}
+		name = names[p.Id]
+	case conflictStrategy.Fail:
+		conflicts, err := q.findConflictingPackages(ctx, parentId, []pgdb.PackageParams{
+			{Name: name, DatasetId: int(datasetId), ParentId: parentId},
+		})
+		if err != nil {
+			return nil, nil, mapError(err)
+		}
+		if _, exists := conflicts[name]; exists {
+			return nil, nil, PackageNameConflictError{fmt.Sprintf("a package named %q already exists", name)}
+		}
+	default:
+		return nil, nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown conflict strategy: %s", strategy))
+	}
+
+	currentTime := time.Now()
+	queryStr := fmt.Sprintf("UPDATE packages SET name=$1, updated_at=$2 WHERE id=$3 RETURNING %s", packageColumns)
+	renamed, err := scanPackage(q.db.QueryRowContext(ctx, queryStr, name, currentTime, p.Id))
+	if err != nil {
+		// A concurrent insert or rename can still claim the name before this update runs.
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, nil, PackageNameConflictError{fmt.Sprintf("a package named %q already exists", name)}
+		}
+		log.Error("Error renaming package: ", err)
+		return nil, nil, mapError(err)
+	}
+
+	if err := q.SetUpdatedAt(ctx, datasetId, currentTime); err != nil {
+		return nil, nil, mapError(fmt.Errorf("updating dataset %d: %w", datasetId, err))
+	}
+
+	parent, err := q.getParentPackage(ctx, renamed.ParentId)
+	if err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	event := changelog.Event{
+		EventType: changelog.RenamePackage,
+		EventDetail: changelog.PackageRenameEvent{
+			Id:      renamed.Id,
+			OldName: oldName,
+			NewName: renamed.Name,
+			NodeId:  renamed.NodeId,
+			Parent:  parent,
+		},
+		Timestamp: currentTime,
+	}
+
+	return renamed, &event, nil
+}
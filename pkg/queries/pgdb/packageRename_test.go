@@ -0,0 +1,102 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageRename is the main Test Suite function for renaming Packages.
+func TestPackageRename(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Rename package":              testRenamePackage,
+		"Rename package keep both":    testRenamePackageKeepBoth,
+		"Rename package fail":         testRenamePackageFail,
+		"Rename package invalid name": testRenamePackageInvalidName,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testRenamePackage(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "old.txt", ParentId: folder.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	renamed, event, err := store.RenamePackage(ctx, 1, packages[0].Id, "new.txt", conflictStrategy.Fail)
+	assert.NoError(t, err)
+	assert.Equal(t, "new.txt", renamed.Name)
+	assert.True(t, renamed.UpdatedAt.After(packages[0].UpdatedAt))
+
+	assert.Equal(t, changelog.RenamePackage, event.EventType)
+	detail := event.EventDetail.(changelog.PackageRenameEvent)
+	assert.Equal(t, "old.txt", detail.OldName)
+	assert.Equal(t, "new.txt", detail.NewName)
+	assert.Equal(t, folder.Id, detail.Parent.Id)
+
+	dataset, err := store.GetDatasetById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, event.Timestamp.UnixMilli(), dataset.UpdatedAt.UnixMilli())
+}
+
+func testRenamePackageKeepBoth(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+		{Name: "b.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	renamed, _, err := store.RenamePackage(ctx, 1, packages[1].Id, "a.txt", conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.Equal(t, "a (1).txt", renamed.Name)
+}
+
+func testRenamePackageFail(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+		{Name: "b.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, _, err = store.RenamePackage(ctx, 1, packages[1].Id, "a.txt", conflictStrategy.Fail)
+	assert.ErrorAs(t, err, &PackageNameConflictError{})
+
+	p, err := store.GetPackageById(ctx, packages[1].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "b.txt", p.Name)
+}
+
+func testRenamePackageInvalidName(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	for _, name := range []string{"", " ", "a/b.txt", deletedNamePrefix + "x"} {
+		_, _, err = store.RenamePackage(ctx, 1, packages[0].Id, name, conflictStrategy.KeepBoth)
+		assert.ErrorAs(t, err, &InvalidPackageRenameError{}, name)
+	}
+}
@@ -0,0 +1,160 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type IllegalPackageStateTransitionError struct {
+	ErrorMessage string
+}
+
+func (e IllegalPackageStateTransitionError) Error() string {
+	return fmt.Sprintf("illegal package state transition (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e IllegalPackageStateTransitionError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+type PackageStateConflictError struct {
+	ErrorMessage string
+}
+
+func (e PackageStateConflictError) Error() string {
+	return fmt.Sprintf("package state conflict (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrConflict.
+func (e PackageStateConflictError) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
+
+// TransitionPackageState moves a package from one state to another.
+//   - The transition must be allowed by the package state machine, otherwise an
+//     IllegalPackageStateTransitionError is returned.
+//   - The update only applies if the package is still in the from state. If another process
+//     changed the state in the meantime, a PackageStateConflictError is returned.
+//
+// It returns the updated package.
+func (q *Queries) TransitionPackageState(ctx context.Context, packageId int64, from packageState.State, to packageState.State) (*pgdb.Package, error) {
+	if !from.CanTransitionTo(to) {
+		return nil, IllegalPackageStateTransitionError{fmt.Sprintf("cannot move package %d from %s to %s", packageId, from, to)}
+	}
+
+	queryStr := fmt.Sprintf("UPDATE packages SET state=$1, updated_at=$2 WHERE id=$3 AND state=$4 RETURNING %s", packageColumns)
+	p, err := scanPackage(q.db.QueryRowContext(ctx, queryStr, to.String(), time.Now(), packageId, from.String()))
+	if err == nil {
+		return p, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Error("Error transitioning package state: ", err)
+		return nil, mapError(err)
+	}
+
+	// Nothing was updated, either the package does not exist or its state changed.
+	current, err := q.GetPackageById(ctx, packageId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return nil, PackageStateConflictError{fmt.Sprintf("package %d is in state %s, expected %s", packageId, current.PackageState, from)}
+}
+
+// TransitionPackageStates moves a set of packages from one state to another in a single statement.
+//   - The transition must be allowed by the package state machine, otherwise an
+//     IllegalPackageStateTransitionError is returned and no package is updated.
+//   - Packages that do not exist or are no longer in the from state are left untouched.
+//
+// It returns the updated packages and the ids of the packages that were skipped.
+func (q *Queries) TransitionPackageStates(ctx context.Context, packageIds []int64, from packageState.State, to packageState.State) ([]pgdb.Package, []int64, error) {
+	if !from.CanTransitionTo(to) {
+		return nil, nil, IllegalPackageStateTransitionError{fmt.Sprintf("cannot move packages from %s to %s", from, to)}
+	}
+	if len(packageIds) == 0 {
+		return nil, nil, nil
+	}
+
+	queryStr := fmt.Sprintf("UPDATE packages SET state=$1, updated_at=$2 WHERE id = ANY($3) AND state=$4 RETURNING %s", packageColumns)
+	rows, err := q.db.QueryContext(ctx, queryStr, to.String(), time.Now(), pq.Array(packageIds), from.String())
+	if err != nil {
+		log.Error("Error transitioning package states: ", err)
+		return nil, nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var updated []pgdb.Package
+	updatedIds := map[int64]bool{}
+	for rows.Next() {
+		p, err := scanPackage(rows)
+		if err != nil {
+			log.Error("Error scanning package: ", err)
+			return nil, nil, mapError(err)
+		}
+		updated = append(updated, *p)
+		updatedIds[p.Id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	var skipped []int64
+	for _, id := range packageIds {
+		if !updatedIds[id] {
+			skipped = append(skipped, id)
+		}
+	}
+	return updated, skipped, nil
+}
+
+// TransitionPackageStatesByNodeId is the same as TransitionPackageStates, but selects the
+// packages by node id.
+//
+// It returns the updated packages and the node ids of the packages that were skipped.
+func (q *Queries) TransitionPackageStatesByNodeId(ctx context.Context, nodeIds []string, from packageState.State, to packageState.State) ([]pgdb.Package, []string, error) {
+	if !from.CanTransitionTo(to) {
+		return nil, nil, IllegalPackageStateTransitionError{fmt.Sprintf("cannot move packages from %s to %s", from, to)}
+	}
+	if len(nodeIds) == 0 {
+		return nil, nil, nil
+	}
+
+	queryStr := fmt.Sprintf("UPDATE packages SET state=$1, updated_at=$2 WHERE node_id = ANY($3) AND state=$4 RETURNING %s", packageColumns)
+	rows, err := q.db.QueryContext(ctx, queryStr, to.String(), time.Now(), pq.Array(nodeIds), from.String())
+	if err != nil {
+		log.Error("Error transitioning package states: ", err)
+		return nil, nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var updated []pgdb.Package
+	updatedIds := map[string]bool{}
+	for rows.Next() {
+		p, err := scanPackage(rows)
+		if err != nil {
+			log.Error("Error scanning package: ", err)
+			return nil, nil, mapError(err)
+		}
+		updated = append(updated, *p)
+		updatedIds[p.NodeId] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	var skipped []string
+	for _, id := range nodeIds {
+		if !updatedIds[id] {
+			skipped = append(skipped, id)
+		}
+	}
+	return updated, skipped, nil
+}
@@ -0,0 +1,94 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageStates is the main Test Suite function for Package state transitions.
+func TestPackageStates(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Transition package state":          testTransitionPackageState,
+		"Transition package state conflict": testTransitionPackageStateConflict,
+		"Illegal package state transition":  testIllegalPackageStateTransition,
+		"Transition package states in bulk": testTransitionPackageStates,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testTransitionPackageState(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	p, err := store.TransitionPackageState(ctx, packages[0].Id, packageState.Unavailable, packageState.Uploaded)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Uploaded, p.PackageState)
+}
+
+func testTransitionPackageStateConflict(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.TransitionPackageState(ctx, packages[0].Id, packageState.Processing, packageState.Ready)
+	assert.ErrorAs(t, err, &PackageStateConflictError{})
+
+	p, err := store.GetPackageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packages[0].PackageState, p.PackageState)
+}
+
+func testIllegalPackageStateTransition(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	_, err := store.TransitionPackageState(ctx, 1, packageState.Deleted, packageState.Processing)
+	assert.ErrorAs(t, err, &IllegalPackageStateTransitionError{})
+
+	_, _, err = store.TransitionPackageStates(ctx, []int64{1}, packageState.Infected, packageState.Ready)
+	assert.ErrorAs(t, err, &IllegalPackageStateTransitionError{})
+}
+
+func testTransitionPackageStates(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: -1},
+		{Name: "b.txt", ParentId: -1},
+		{Name: "c.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.TransitionPackageState(ctx, packages[2].Id, packageState.Unavailable, packageState.UploadFailed)
+	assert.NoError(t, err)
+
+	ids := []int64{packages[0].Id, packages[1].Id, packages[2].Id}
+	updated, skipped, err := store.TransitionPackageStates(ctx, ids, packageState.Unavailable, packageState.Uploaded)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 2)
+	assert.Equal(t, []int64{packages[2].Id}, skipped)
+
+	nodeIds := []string{packages[0].NodeId, packages[1].NodeId}
+	updated, skippedNodeIds, err := store.TransitionPackageStatesByNodeId(ctx, nodeIds, packageState.Uploaded, packageState.Processing)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 2)
+	assert.Empty(t, skippedNodeIds)
+}
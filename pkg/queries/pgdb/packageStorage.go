@@ -2,6 +2,8 @@ package pgdb
 
 import (
 	"context"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -17,7 +19,7 @@ func (q *Queries) IncrementPackageStorage(ctx context.Context, packageId int64,
 		log.Println("Error incrementing package size: ", err)
 	}
 
-	return err
+	return mapError(err)
 }
 
 // IncrementPackageStorageAncestors increases the storage associated with the parents of the provided package.
@@ -46,7 +48,55 @@ func (q *Queries) IncrementPackageStorageAncestors(ctx context.Context, parentId
 		log.Println("Error incrementing package size: ", err)
 	}
 
-	return err
+	return mapError(err)
+}
+
+// DecrementPackageStorage decreases the storage associated with the provided package.
+// The storage never goes below zero.
+func (q *Queries) DecrementPackageStorage(ctx context.Context, packageId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot decrement package storage by a negative size: %d", size))
+	}
+
+	queryStr := "UPDATE package_storage SET size = GREATEST(COALESCE(size, 0) - $2, 0) WHERE package_id = $1;"
+
+	_, err := q.db.ExecContext(ctx, queryStr, packageId, size)
+	if err != nil {
+		log.Println("Error decrementing package size: ", err)
+	}
+
+	return mapError(err)
+}
+
+// DecrementPackageStorageAncestors decreases the storage associated with the provided package and its parents.
+// The storage never goes below zero.
+func (q *Queries) DecrementPackageStorageAncestors(ctx context.Context, parentId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot decrement package storage by a negative size: %d", size))
+	}
+
+	queryStr := "" +
+		"WITH RECURSIVE ancestors(id, parent_id) AS (" +
+		"SELECT " +
+		"packages.id, " +
+		"packages.parent_id " +
+		"FROM packages packages " +
+		"WHERE packages.id = $1 " +
+		"UNION " +
+		"SELECT parents.id, parents.parent_id " +
+		"FROM packages parents " +
+		"JOIN ancestors ON ancestors.parent_id = parents.id" +
+		") " +
+		"UPDATE package_storage " +
+		"SET size = GREATEST(COALESCE(package_storage.size, 0) - $2, 0) " +
+		"FROM ancestors WHERE package_storage.package_id = ancestors.id;"
+
+	_, err := q.db.ExecContext(ctx, queryStr, parentId, size)
+	if err != nil {
+		log.Println("Error decrementing package size: ", err)
+	}
+
+	return mapError(err)
 }
 
 func (q *Queries) GetPackageStorageById(ctx context.Context, packageId int64) (int64, error) {
@@ -58,7 +108,7 @@ func (q *Queries) GetPackageStorageById(ctx context.Context, packageId int64) (i
 
 	if err != nil {
 		log.Error("unable to get package size", err)
-		return int64(0), err
+		return int64(0), mapError(err)
 	}
 
 	return packageSize, nil
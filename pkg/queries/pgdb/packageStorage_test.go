@@ -83,3 +83,41 @@ func TestPackageStorage(t *testing.T) {
 	assert.Equal(t, int64(10), actualSize, "Size is expected to be 10")
 
 }
+
+func TestDecrementPackageStorageAncestors(t *testing.T) {
+
+	orgId := 1
+	store := NewSQLStore(testDB[orgId])
+
+	defer func() {
+		test.Truncate(t, store.db, orgId, "packages")
+		test.Truncate(t, store.db, orgId, "package_storage")
+	}()
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	subFolder := addTestFolder(t, store, "sub", folder.Id)
+
+	err := store.IncrementPackageStorage(ctx, folder.Id, 100)
+	assert.NoError(t, err)
+	err = store.IncrementPackageStorage(ctx, subFolder.Id, 10)
+	assert.NoError(t, err)
+
+	err = store.DecrementPackageStorageAncestors(ctx, subFolder.Id, 20)
+	assert.NoError(t, err)
+
+	actualSize, err := store.GetPackageStorageById(ctx, folder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), actualSize, "Size is expected to be 80")
+
+	actualSize, err = store.GetPackageStorageById(ctx, subFolder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), actualSize, "Size is expected to stop at 0")
+
+	err = store.DecrementPackageStorage(ctx, folder.Id, 80)
+	assert.NoError(t, err)
+
+	actualSize, err = store.GetPackageStorageById(ctx, folder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), actualSize, "Size is expected to be 0")
+}
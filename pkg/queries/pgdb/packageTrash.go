@@ -0,0 +1,415 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// deletedNamePrefix is prepended (together with the node id) to the name of
+// explicitly deleted packages so they no longer occupy their name in the parent folder.
+const deletedNamePrefix = "__DELETED__"
+
+// deletedNamePattern matches names starting with deletedNamePrefix in a LIKE clause.
+const deletedNamePattern = `\_\_DELETED\_\_%`
+
+type InvalidPackageDeleteError struct {
+	ErrorMessage string
+}
+
+func (e InvalidPackageDeleteError) Error() string {
+	return fmt.Sprintf("invalid package delete (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidPackageDeleteError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+type InvalidPackageRestoreError struct {
+	ErrorMessage string
+}
+
+func (e InvalidPackageRestoreError) Error() string {
+	return fmt.Sprintf("invalid package restore (error: %v)", e.ErrorMessage)
+}
+
+// Is returns true for dbErrors.ErrInvalid.
+func (e InvalidPackageRestoreError) Is(target error) bool {
+	return target == dbErrors.ErrInvalid
+}
+
+// TrashItem is an explicitly deleted package that can be restored.
+type TrashItem struct {
+	pgdb.Package
+	OriginalName string `json:"original_name"`
+	StorageSize  int64  `json:"storage_size"`
+}
+
+// SoftDeletePackages marks packages, and for folders their entire subtree, as deleted.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - Each requested package is renamed to __DELETED__<nodeId>_<name> and shows up in GetTrashItems.
+//   - Descendants keep their name so they can be restored together with their folder.
+//   - The state of each deleted package is recorded in package_deleted_state so RestorePackages can return
+//     the package to it.
+//   - The package storage is removed from the ancestors, the dataset and the organization, but kept on the
+//     deleted packages so it can be added back on restore.
+//
+// It returns a DeletePackage changelog event for each requested package.
+func (q *Queries) SoftDeletePackages(ctx context.Context, organizationId int64, datasetId int64, packageIds []int64) ([]changelog.Event, error) {
+	var packages []*pgdb.Package
+	requested := map[int64]bool{}
+	for _, id := range packageIds {
+		p, err := q.GetPackageById(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, InvalidPackageDeleteError{fmt.Sprintf("package %d does not exist", id)}
+			}
+			return nil, mapError(err)
+		}
+		if int64(p.DatasetId) != datasetId {
+			return nil, InvalidPackageDeleteError{fmt.Sprintf("package %d is not in dataset %d", id, datasetId)}
+		}
+		if isDeletedState(p.PackageState) {
+			return nil, InvalidPackageDeleteError{fmt.Sprintf("package %d is already deleted", id)}
+		}
+		packages = append(packages, p)
+		requested[id] = true
+	}
+
+	var events []changelog.Event
+	currentTime := time.Now()
+	for _, p := range packages {
+		// Packages in the subtree of another requested package are deleted with that package.
+		ancestorIds, err := q.GetPackageAncestorIds(ctx, p.Id)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		nested := false
+		for _, ancestorId := range ancestorIds[1:] {
+			if requested[ancestorId] {
+				nested = true
+				break
+			}
+		}
+		if nested {
+			continue
+		}
+
+		size, err := q.GetPackageStorageById(ctx, p.Id)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return nil, mapError(fmt.Errorf("reading storage for package %d: %w", p.Id, err))
+			}
+			size = 0
+		}
+
+		// All parts of a statement see the packages before the update, so the saved state is the previous one.
+		queryStr := "" +
+			"WITH saved AS (" +
+			"INSERT INTO package_deleted_state (package_id, state) SELECT id, state FROM packages WHERE id = $4 " +
+			"ON CONFLICT (package_id) DO UPDATE SET state = EXCLUDED.state" +
+			") " +
+			"UPDATE packages SET state=$1, name=$2, updated_at=$3 WHERE id=$4"
+
+		_, err = q.db.ExecContext(ctx, queryStr,
+			packageState.Deleted.String(), deletedName(p), currentTime, p.Id)
+		if err != nil {
+			return nil, schemaError(organizationSchemaDir, fmt.Errorf("soft-deleting package %d: %w", p.Id, err))
+		}
+
+		queryStr = "" +
+			"WITH RECURSIVE descendants(id) AS (" +
+			"SELECT id FROM packages WHERE parent_id = $1 AND state NOT IN ($2, $3) " +
+			"UNION " +
+			"SELECT children.id FROM packages children " +
+			"JOIN descendants ON children.parent_id = descendants.id " +
+			"WHERE children.state NOT IN ($2, $3)" +
+			"), saved AS (" +
+			"INSERT INTO package_deleted_state (package_id, state) " +
+			"SELECT id, state FROM packages WHERE id IN (SELECT id FROM descendants) " +
+			"ON CONFLICT (package_id) DO UPDATE SET state = EXCLUDED.state" +
+			") " +
+			"UPDATE packages SET state=$2, updated_at=$4 WHERE id IN (SELECT id FROM descendants)"
+
+		_, err = q.db.ExecContext(ctx, queryStr, p.Id,
+			packageState.Deleted.String(), packageState.Deleting.String(), currentTime)
+		if err != nil {
+			return nil, schemaError(organizationSchemaDir, fmt.Errorf("soft-deleting descendants of package %d: %w", p.Id, err))
+		}
+
+		if size > 0 {
+			if p.ParentId.Valid {
+				if err := q.DecrementPackageStorageAncestors(ctx, p.ParentId.Int64, size); err != nil {
+					return nil, mapError(fmt.Errorf("decrementing ancestor storage for package %d: %w", p.Id, err))
+				}
+			}
+			if err := q.DecrementDatasetStorage(ctx, datasetId, size); err != nil {
+				return nil, mapError(fmt.Errorf("decrementing dataset storage for package %d: %w", p.Id, err))
+			}
+			if err := q.DecrementOrganizationStorage(ctx, organizationId, size); err != nil {
+				return nil, mapError(fmt.Errorf("decrementing organization storage for package %d: %w", p.Id, err))
+			}
+		}
+
+		parent, err := q.getParentPackage(ctx, p.ParentId)
+		if err != nil {
+			return nil, mapError(err)
+		}
+
+		events = append(events, changelog.Event{
+			EventType: changelog.DeletePackage,
+			EventDetail: changelog.PackageDeleteEvent{
+				Id:     p.Id,
+				Name:   p.Name,
+				NodeId: p.NodeId,
+				Parent: parent,
+			},
+			Timestamp: currentTime,
+		})
+	}
+
+	return events, nil
+}
+
+// GetTrashItems returns the explicitly deleted packages in a dataset whose parent is not deleted,
+// most recently deleted first.
+func (q *Queries) GetTrashItems(ctx context.Context, datasetId int64) ([]TrashItem, error) {
+	queryStr := fmt.Sprintf("SELECT %s, COALESCE(storage.size, 0) FROM packages p "+
+		"LEFT JOIN package_storage storage ON storage.package_id = p.id "+
+		"LEFT JOIN packages parent ON parent.id = p.parent_id "+
+		"WHERE p.dataset_id = $1 AND p.state = $2 AND p.name LIKE $3 "+
+		"AND (p.parent_id IS NULL OR parent.state NOT IN ($2, $4)) "+
+		"ORDER BY p.updated_at DESC, p.id", packageColumnsWithAlias("p"))
+
+	rows, err := q.db.QueryContext(ctx, queryStr, datasetId,
+		packageState.Deleted.String(), deletedNamePattern, packageState.Deleting.String())
+	if err != nil {
+		log.Error("Error fetching trash items: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		var item TrashItem
+		err = rows.Scan(
+			&item.Id,
+			&item.Name,
+			&item.PackageType,
+			&item.PackageState,
+			&item.NodeId,
+			&item.ParentId,
+			&item.DatasetId,
+			&item.OwnerId,
+			&item.Size,
+			&item.ImportId,
+			&item.Attributes,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&item.ReplacesPackageId,
+			&item.ReplacedByPackageId,
+			&item.StorageSize,
+		)
+		if err != nil {
+			log.Error("Error scanning trash item: ", err)
+			return nil, mapError(err)
+		}
+		item.OriginalName = originalName(&item.Package)
+		items = append(items, item)
+	}
+	return items, mapError(rows.Err())
+}
+
+// RestorePackages restores explicitly deleted packages, and the subtree that was deleted with them,
+// to their original name and parent.
+//   - This call should typically be wrapped in a Transaction as it will run multiple queries.
+//   - The original parent must not be deleted.
+//   - Name collisions with packages created since the delete are resolved using the provided strategy.
+//     With Replace, the restored package is linked to the package it replaces.
+//   - A restore undoes the delete rather than being a state transition: restored packages return to the state
+//     recorded by SoftDeletePackages, or READY for packages deleted before the state was recorded.
+//   - The storage of restored packages is added back to the ancestors, the dataset and the organization.
+//
+// It returns a RestorePackage changelog event for each requested package.
+func (q *Queries) RestorePackages(ctx context.Context, organizationId int64, datasetId int64, packageIds []int64, strategy conflictStrategy.Strategy) ([]changelog.Event, error) {
+	// Group by parent as name conflicts are resolved per destination folder.
+	parentIdMap := map[int64][]pgdb.Package{}
+	var parentIds []int64
+	for _, id := range packageIds {
+		p, err := q.GetPackageById(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, InvalidPackageRestoreError{fmt.Sprintf("package %d does not exist", id)}
+			}
+			return nil, mapError(err)
+		}
+		if int64(p.DatasetId) != datasetId {
+			return nil, InvalidPackageRestoreError{fmt.Sprintf("package %d is not in dataset %d", id, datasetId)}
+		}
+		if p.PackageState != packageState.Deleted || !strings.HasPrefix(p.Name, deletedNamePrefix) {
+			return nil, InvalidPackageRestoreError{fmt.Sprintf("package %d is not in the trash", id)}
+		}
+
+		parentId := int64(-1)
+		if p.ParentId.Valid {
+			parent, err := q.GetPackageById(ctx, p.ParentId.Int64)
+			if err != nil {
+				return nil, mapError(err)
+			}
+			if isDeletedState(parent.PackageState) {
+				return nil, InvalidPackageRestoreError{fmt.Sprintf("parent of package %d is deleted", id)}
+			}
+			parentId = parent.Id
+		}
+
+		restored := *p
+		restored.Name = originalName(p)
+		if _, ok := parentIdMap[parentId]; !ok {
+			parentIds = append(parentIds, parentId)
+		}
+		parentIdMap[parentId] = append(parentIdMap[parentId], restored)
+	}
+
+	var events []changelog.Event
+	currentTime := time.Now()
+	for _, parentId := range parentIds {
+		records := parentIdMap[parentId]
+
+		var names map[int64]string
+		var replaced map[int64]*pgdb.Package
+		var replacedSize int64
+		var err error
+		switch strategy {
+		case conflictStrategy.KeepBoth:
+			names, err = q.resolveKeepBothNames(ctx, datasetId, parentId, records)
+		case conflictStrategy.Replace:
+			names, replaced, replacedSize, err = q.resolveReplaceConflicts(ctx, datasetId, parentId, records)
+		case conflictStrategy.Fail:
+			names, err = q.resolveFailConflicts(ctx, datasetId, parentId, records)
+		default:
+			return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown conflict strategy: %s", strategy))
+		}
+		if err != nil {
+			return nil, mapError(err)
+		}
+		if replacedSize > 0 {
+			if err := q.DecrementOrganizationStorage(ctx, organizationId, replacedSize); err != nil {
+				return nil, mapError(fmt.Errorf("decrementing organization storage for replaced packages: %w", err))
+			}
+		}
+
+		for _, p := range records {
+			_, err = q.db.ExecContext(ctx,
+				"UPDATE packages SET state=COALESCE((SELECT state FROM package_deleted_state WHERE package_id = $4), $1), "+
+					"name=$2, updated_at=$3 WHERE id=$4",
+				packageState.Ready.String(), names[p.Id], currentTime, p.Id)
+			if err != nil {
+				return nil, schemaError(organizationSchemaDir, fmt.Errorf("restoring package %d: %w", p.Id, err))
+			}
+
+			// Descendants that were deleted on their own keep their prefixed name and stay in the trash.
+			// All parts of a statement see the recorded states before they are removed.
+			queryStr := "" +
+				"WITH RECURSIVE descendants(id) AS (" +
+				"SELECT id FROM packages WHERE parent_id = $1 AND state = $2 AND name NOT LIKE $3 " +
+				"UNION " +
+				"SELECT children.id FROM packages children " +
+				"JOIN descendants ON children.parent_id = descendants.id " +
+				"WHERE children.state = $2 AND children.name NOT LIKE $3" +
+				"), cleared AS (" +
+				"DELETE FROM package_deleted_state WHERE package_id = $1 OR package_id IN (SELECT id FROM descendants)" +
+				") " +
+				"UPDATE packages SET state=COALESCE(" +
+				"(SELECT saved.state FROM package_deleted_state saved WHERE saved.package_id = packages.id), $4), " +
+				"updated_at=$5 WHERE id IN (SELECT id FROM descendants)"
+
+			_, err = q.db.ExecContext(ctx, queryStr, p.Id,
+				packageState.Deleted.String(), deletedNamePattern, packageState.Ready.String(), currentTime)
+			if err != nil {
+				return nil, schemaError(organizationSchemaDir, fmt.Errorf("restoring descendants of package %d: %w", p.Id, err))
+			}
+
+			if old, ok := replaced[p.Id]; ok {
+				if err := q.linkReplacedPackage(ctx, old.Id, p.Id); err != nil {
+					return nil, mapError(err)
+				}
+			}
+
+			size, err := q.GetPackageStorageById(ctx, p.Id)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					return nil, mapError(fmt.Errorf("reading storage for package %d: %w", p.Id, err))
+				}
+				size = 0
+			}
+			if size > 0 {
+				if parentId >= 0 {
+					if err := q.IncrementPackageStorageAncestors(ctx, parentId, size); err != nil {
+						return nil, mapError(fmt.Errorf("incrementing ancestor storage for package %d: %w", p.Id, err))
+					}
+				}
+				if err := q.IncrementDatasetStorage(ctx, datasetId, size); err != nil {
+					return nil, mapError(fmt.Errorf("incrementing dataset storage for package %d: %w", p.Id, err))
+				}
+				if err := q.IncrementOrganizationStorage(ctx, organizationId, size); err != nil {
+					return nil, mapError(fmt.Errorf("incrementing organization storage for package %d: %w", p.Id, err))
+				}
+			}
+
+			parent, err := q.getParentPackage(ctx, p.ParentId)
+			if err != nil {
+				return nil, mapError(err)
+			}
+
+			events = append(events, changelog.Event{
+				EventType: changelog.RestorePackage,
+				EventDetail: changelog.PackageRestoreEvent{
+					Id:           p.Id,
+					Name:         names[p.Id],
+					OriginalName: p.Name,
+					NodeId:       p.NodeId,
+					Parent:       parent,
+				},
+				Timestamp: currentTime,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// getParentPackage returns the changelog representation of a parent package, or nil for the dataset root.
+func (q *Queries) getParentPackage(ctx context.Context, parentId sql.NullInt64) (*changelog.ParentPackage, error) {
+	if !parentId.Valid {
+		return nil, nil
+	}
+	parent, err := q.GetPackageById(ctx, parentId.Int64)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("reading parent package %d: %w", parentId.Int64, err))
+	}
+	return &changelog.ParentPackage{
+		Id:     parent.Id,
+		Name:   parent.Name,
+		NodeId: parent.NodeId,
+	}, nil
+}
+
+// deletedName returns the name used for a deleted package.
+func deletedName(p *pgdb.Package) string {
+	return fmt.Sprintf("%s%s_%s", deletedNamePrefix, p.NodeId, p.Name)
+}
+
+// originalName returns the name of a deleted package before it was deleted.
+func originalName(p *pgdb.Package) string {
+	return strings.TrimPrefix(p.Name, fmt.Sprintf("%s%s_", deletedNamePrefix, p.NodeId))
+}
@@ -0,0 +1,229 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/changelog"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageTrash is the main Test Suite function for deleting and restoring Packages.
+func TestPackageTrash(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Delete and restore folder":       testDeleteAndRestoreFolder,
+		"Restore with keep-both conflict": testRestoreKeepBoth,
+		"Nested delete stays in trash":    testNestedDeleteStaysInTrash,
+		"Restore with replace conflict":   testRestoreReplace,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testDeleteAndRestoreFolder(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "package_storage")
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	ctx := context.Background()
+	parent := addTestFolder(t, store, "parent", -1)
+	folder := addTestFolder(t, store, "folder", parent.Id)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: folder.Id},
+	}, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, store.IncrementPackageStorage(ctx, packages[0].Id, 100))
+	assert.NoError(t, store.IncrementPackageStorageAncestors(ctx, folder.Id, 100))
+	assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 100))
+	assert.NoError(t, store.IncrementOrganizationStorage(ctx, 1, 100))
+	_, err = store.db.Exec("UPDATE packages SET state = $1 WHERE id = $2", packageState.Processing.String(), packages[0].Id)
+	assert.NoError(t, err)
+
+	events, err := store.SoftDeletePackages(ctx, 1, 1, []int64{folder.Id, packages[0].Id})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1, "nested package should be deleted with its folder")
+	assert.Equal(t, changelog.DeletePackage, events[0].EventType)
+	assert.Equal(t, parent.Id, events[0].EventDetail.(changelog.PackageDeleteEvent).Parent.Id)
+
+	deletedFolder, err := store.GetPackageById(ctx, folder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Deleted, deletedFolder.PackageState)
+	assert.Contains(t, deletedFolder.Name, "__DELETED__")
+
+	deletedPackage, err := store.GetPackageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Deleted, deletedPackage.PackageState)
+	assert.Equal(t, "file.txt", deletedPackage.Name, "descendants keep their name")
+
+	parentSize, err := store.GetPackageStorageById(ctx, parent.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), parentSize)
+	datasetSize, err := store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), datasetSize)
+	orgSize, err := store.GetOrganizationStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), orgSize)
+
+	items, err := store.GetTrashItems(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, folder.Id, items[0].Id)
+	assert.Equal(t, "folder", items[0].OriginalName)
+	assert.Equal(t, int64(100), items[0].StorageSize)
+
+	events, err = store.RestorePackages(ctx, 1, 1, []int64{folder.Id}, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, changelog.RestorePackage, events[0].EventType)
+
+	restoredFolder, err := store.GetPackageById(ctx, folder.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Ready, restoredFolder.PackageState)
+	assert.Equal(t, "folder", restoredFolder.Name)
+
+	restoredPackage, err := store.GetPackageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Processing, restoredPackage.PackageState, "restored packages return to their previous state")
+
+	parentSize, err = store.GetPackageStorageById(ctx, parent.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), parentSize)
+	datasetSize, err = store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), datasetSize)
+	orgSize, err = store.GetOrganizationStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), orgSize)
+
+	items, err = store.GetTrashItems(ctx, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func testRestoreKeepBoth(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.SoftDeletePackages(ctx, 1, 1, []int64{packages[0].Id})
+	assert.NoError(t, err)
+
+	// A new package takes the name of the deleted one
+	_, err = store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.RestorePackages(ctx, 1, 1, []int64{packages[0].Id}, conflictStrategy.Fail)
+	assert.IsType(t, PackageNameConflictError{}, err)
+
+	events, err := store.RestorePackages(ctx, 1, 1, []int64{packages[0].Id}, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	detail := events[0].EventDetail.(changelog.PackageRestoreEvent)
+	assert.Equal(t, "file (1).txt", detail.Name)
+	assert.Equal(t, "file.txt", detail.OriginalName)
+
+	_, err = store.RestorePackages(ctx, 1, 1, []int64{packages[0].Id}, conflictStrategy.KeepBoth)
+	assert.ErrorAs(t, err, &InvalidPackageRestoreError{}, "restoring a live package should fail")
+}
+
+func testNestedDeleteStaysInTrash(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: folder.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.SoftDeletePackages(ctx, 1, 1, []int64{packages[0].Id})
+	assert.NoError(t, err)
+	_, err = store.SoftDeletePackages(ctx, 1, 1, []int64{folder.Id})
+	assert.NoError(t, err)
+
+	items, err := store.GetTrashItems(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1, "package in deleted folder is not listed")
+
+	_, err = store.RestorePackages(ctx, 1, 1, []int64{packages[0].Id}, conflictStrategy.KeepBoth)
+	assert.ErrorAs(t, err, &InvalidPackageRestoreError{}, "cannot restore into a deleted folder")
+
+	_, err = store.RestorePackages(ctx, 1, 1, []int64{folder.Id}, conflictStrategy.KeepBoth)
+	assert.NoError(t, err)
+
+	stillDeleted, err := store.GetPackageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Deleted, stillDeleted.PackageState, "separately deleted package stays in trash")
+
+	items, err = store.GetTrashItems(ctx, 1)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, packages[0].Id, items[0].Id)
+}
+
+func testRestoreReplace(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "package_storage")
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+	defer test.Truncate(t, store.db, orgId, "organization_storage")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, store.IncrementPackageStorage(ctx, packages[0].Id, 100))
+	assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 100))
+	assert.NoError(t, store.IncrementOrganizationStorage(ctx, 1, 100))
+
+	_, err = store.SoftDeletePackages(ctx, 1, 1, []int64{packages[0].Id})
+	assert.NoError(t, err)
+
+	// A new package takes the name of the deleted one
+	newer, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "file.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, store.IncrementPackageStorage(ctx, newer[0].Id, 30))
+	assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 30))
+	assert.NoError(t, store.IncrementOrganizationStorage(ctx, 1, 30))
+
+	events, err := store.RestorePackages(ctx, 1, 1, []int64{packages[0].Id}, conflictStrategy.Replace)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "file.txt", events[0].EventDetail.(changelog.PackageRestoreEvent).Name)
+
+	restored, err := store.GetPackageById(ctx, packages[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "file.txt", restored.Name)
+	assert.Equal(t, packageState.Unavailable, restored.PackageState)
+	assert.Equal(t, newer[0].Id, restored.ReplacesPackageId.Int64)
+
+	replaced, err := store.GetPackageById(ctx, newer[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, packageState.Deleting, replaced.PackageState)
+	assert.Equal(t, packages[0].Id, replaced.ReplacedByPackageId.Int64)
+
+	datasetSize, err := store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), datasetSize)
+	orgSize, err := store.GetOrganizationStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), orgSize)
+}
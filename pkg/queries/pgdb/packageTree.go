@@ -0,0 +1,150 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	log "github.com/sirupsen/logrus"
+)
+
+// StopWalk can be returned by a WalkPackageTree callback to end the walk without an error.
+var StopWalk = errors.New("stop package tree walk")
+
+// WalkPackageTreeOptions controls which rows WalkPackageTree visits.
+type WalkPackageTreeOptions struct {
+	IncludeFiles   bool // Populate PackageTreeNode.Files with the files rows of each package.
+	IncludeDeleted bool // Visit packages in DELETING or DELETED state and their descendants.
+}
+
+// PackageTreeNode is a package visited by WalkPackageTree.
+type PackageTreeNode struct {
+	Package pgdb.Package
+	Depth   int         // 0 for the package the walk started at.
+	Path    string      // Slash-separated path relative to the parent of the package the walk started at.
+	Files   []pgdb.File // Only populated if WalkPackageTreeOptions.IncludeFiles is set.
+}
+
+// WalkPackageTree calls fn for a package and each of its descendants, parents before children.
+//   - Rows are streamed from the database, so a tree is never loaded into memory at once.
+//   - The walk holds the connection of the Queries object until it returns. When walking
+//     inside a transaction, fn must not run queries on the same transaction.
+//   - If fn returns StopWalk the walk ends and WalkPackageTree returns nil; any other error
+//     ends the walk and is returned.
+func (q *Queries) WalkPackageTree(ctx context.Context, packageId int64, opts WalkPackageTreeOptions, fn func(node PackageTreeNode) error) error {
+	args := []interface{}{packageId}
+	stateFilter := ""
+	if !opts.IncludeDeleted {
+		stateFilter = "WHERE children.state NOT IN ($2, $3)"
+		args = append(args, packageState.Deleting.String(), packageState.Deleted.String())
+	}
+
+	fileSelect := ""
+	fileJoin := ""
+	fileOrder := ""
+	if opts.IncludeFiles {
+		fileSelect = ", " + fileColumnsWithAlias("f")
+		fileJoin = "LEFT JOIN files f ON f.package_id = p.id "
+		fileOrder = ", f.id"
+	}
+
+	queryStr := "" +
+		"WITH RECURSIVE tree(id, depth, path) AS (" +
+		"SELECT id, 0, CAST(name AS text) FROM packages WHERE id = $1 " +
+		"UNION ALL " +
+		"SELECT children.id, tree.depth + 1, tree.path || '/' || children.name FROM packages children " +
+		"JOIN tree ON children.parent_id = tree.id " +
+		stateFilter +
+		") " +
+		fmt.Sprintf("SELECT tree.depth, tree.path, %s%s FROM tree ", packageColumnsWithAlias("p"), fileSelect) +
+		"JOIN packages p ON p.id = tree.id " +
+		fileJoin +
+		fmt.Sprintf("ORDER BY tree.depth, p.id%s", fileOrder)
+
+	rows, err := q.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		log.Error("Error walking package tree: ", err)
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	// With files joined, a package spans multiple consecutive rows.
+	var current *PackageTreeNode
+	for rows.Next() {
+		var node PackageTreeNode
+		var f nullableFile
+		dest := []interface{}{
+			&node.Depth,
+			&node.Path,
+			&node.Package.Id,
+			&node.Package.Name,
+			&node.Package.PackageType,
+			&node.Package.PackageState,
+			&node.Package.NodeId,
+			&node.Package.ParentId,
+			&node.Package.DatasetId,
+			&node.Package.OwnerId,
+			&node.Package.Size,
+			&node.Package.ImportId,
+			&node.Package.Attributes,
+			&node.Package.CreatedAt,
+			&node.Package.UpdatedAt,
+			&node.Package.ReplacesPackageId,
+			&node.Package.ReplacedByPackageId,
+		}
+		if opts.IncludeFiles {
+			dest = append(dest, f.scanDest()...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			log.Error("Error scanning package tree: ", err)
+			return mapError(err)
+		}
+
+		if current != nil && current.Package.Id != node.Package.Id {
+			if err := fn(*current); err != nil {
+				if errors.Is(err, StopWalk) {
+					return nil
+				}
+				return mapError(err)
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &node
+		}
+		if f.valid() {
+			file, err := f.toFile()
+			if err != nil {
+				return mapError(err)
+			}
+			current.Files = append(current.Files, *file)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return mapError(err)
+	}
+
+	if current != nil {
+		if err := fn(*current); err != nil && !errors.Is(err, StopWalk) {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// GetPackageDescendants returns the non-deleted descendants of a package, parents before children.
+// The package itself is not included. Use WalkPackageTree for large trees.
+func (q *Queries) GetPackageDescendants(ctx context.Context, packageId int64, includeFiles bool) ([]PackageTreeNode, error) {
+	var descendants []PackageTreeNode
+	err := q.WalkPackageTree(ctx, packageId, WalkPackageTreeOptions{IncludeFiles: includeFiles}, func(node PackageTreeNode) error {
+		if node.Depth > 0 {
+			descendants = append(descendants, node)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return descendants, nil
+}
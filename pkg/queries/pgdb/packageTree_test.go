@@ -0,0 +1,135 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/objectType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestPackageTree is the main Test Suite function for walking Package trees.
+func TestPackageTree(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Walk package tree":            testWalkPackageTree,
+		"Walk package tree with files": testWalkPackageTreeWithFiles,
+		"Stop package tree walk":       testStopWalkPackageTree,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testWalkPackageTree(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	root := addTestFolder(t, store, "root", -1)
+	sub := addTestFolder(t, store, "sub", root.Id)
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: root.Id},
+		{Name: "b.txt", ParentId: sub.Id},
+		{Name: "deleted.txt", ParentId: sub.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	deleted, err := store.GetPackageByPath(ctx, 1, "root/sub/deleted.txt")
+	assert.NoError(t, err)
+	_, err = store.SoftDeletePackages(ctx, 1, 1, []int64{deleted.Id})
+	assert.NoError(t, err)
+
+	descendants, err := store.GetPackageDescendants(ctx, root.Id, false)
+	assert.NoError(t, err)
+
+	paths := map[string]int{}
+	for _, d := range descendants {
+		paths[d.Path] = d.Depth
+	}
+	assert.Equal(t, map[string]int{
+		"root/sub":       1,
+		"root/a.txt":     1,
+		"root/sub/b.txt": 2,
+	}, paths)
+
+	var visited int
+	err = store.WalkPackageTree(ctx, root.Id, WalkPackageTreeOptions{IncludeDeleted: true}, func(node PackageTreeNode) error {
+		visited++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, visited, "deleted packages should be visited when requested")
+}
+
+func testWalkPackageTreeWithFiles(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	ctx := context.Background()
+	root := addTestFolder(t, store, "root", -1)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.edf", ParentId: root.Id},
+		{Name: "b.edf", ParentId: root.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	var files []pgdb.FileParams
+	for _, key := range []string{"a/1.edf", "a/2.edf"} {
+		files = append(files, pgdb.FileParams{
+			PackageId:  int(packages[0].Id),
+			Name:       key,
+			FileType:   fileType.EDF,
+			S3Bucket:   "test-bucket",
+			S3Key:      key,
+			ObjectType: objectType.Source,
+			Size:       10,
+			UUID:       uuid.New(),
+		})
+	}
+	_, err = store.AddFiles(ctx, files)
+	assert.NoError(t, err)
+
+	descendants, err := store.GetPackageDescendants(ctx, root.Id, true)
+	assert.NoError(t, err)
+	assert.Len(t, descendants, 2)
+
+	for _, d := range descendants {
+		if d.Package.Id == packages[0].Id {
+			assert.Len(t, d.Files, 2)
+			assert.Equal(t, fileType.EDF, d.Files[0].FileType)
+			assert.Equal(t, "test-bucket", d.Files[0].S3Bucket)
+		} else {
+			assert.Empty(t, d.Files)
+		}
+	}
+}
+
+func testStopWalkPackageTree(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	root := addTestFolder(t, store, "root", -1)
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.txt", ParentId: root.Id},
+		{Name: "b.txt", ParentId: root.Id},
+	}, 1))
+	assert.NoError(t, err)
+
+	var visited int
+	err = store.WalkPackageTree(ctx, root.Id, WalkPackageTreeOptions{}, func(node PackageTreeNode) error {
+		visited++
+		if visited == 2 {
+			return StopWalk
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, visited)
+}
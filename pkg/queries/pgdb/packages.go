@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
@@ -20,7 +21,7 @@ import (
 func (q *Queries) AddFolder(ctx context.Context, r pgdb.PackageParams) (*pgdb.Package, error) {
 
 	if r.PackageType != packageType.Collection {
-		return nil, errors.New("record is not of type COLLECTION")
+		return nil, dbErrors.New(dbErrors.ErrInvalid, "record is not of type COLLECTION")
 	}
 
 	currentTime := time.Now()
@@ -52,7 +53,7 @@ func (q *Queries) AddFolder(ctx context.Context, r pgdb.PackageParams) (*pgdb.Pa
 	//prepare the statement
 	stmt, err := q.db.PrepareContext(ctx, sqlInsert)
 	if err != nil {
-		return nil, fmt.Errorf("error preparing addFolder statement: %w", err)
+		return nil, mapError(fmt.Errorf("error preparing addFolder statement: %w", err))
 	}
 	//goland:noinspection ALL
 	defer stmt.Close()
@@ -78,11 +79,11 @@ func (q *Queries) AddFolder(ctx context.Context, r pgdb.PackageParams) (*pgdb.Pa
 	switch err {
 	case sql.ErrNoRows:
 		log.Error("Error creating or getting a folder")
-		return nil, err
+		return nil, mapError(err)
 	case nil:
 		return &currentRecord, nil
 	default:
-		return nil, err
+		return nil, mapError(err)
 	}
 
 }
@@ -105,11 +106,11 @@ func (q *Queries) AddPackages(ctx context.Context, records []pgdb.PackageParams)
 //   - Replace soft-deletes the conflicting predecessor (state=DELETING, name
 //     prefixed with __DELETED__<nodeId>_) and links the new package via
 //     replaces_package_id / replaced_by_package_id.
-//   - Fail returns an error listing conflicting names without inserting anything.
+//   - Fail returns a PackageNameConflictError listing conflicting names without inserting anything.
 func (q *Queries) AddPackagesWithConflict(ctx context.Context, records []pgdb.PackageParams, strategy conflictStrategy.Strategy) ([]pgdb.Package, error) {
 	for _, r := range records {
 		if r.PackageType == packageType.Collection {
-			return nil, errors.New("cannot create COLLECTION package with AddPackages method, use AddFolder instead")
+			return nil, dbErrors.New(dbErrors.ErrInvalid, "cannot create COLLECTION package with AddPackages method, use AddFolder instead")
 		}
 	}
 
@@ -119,6 +120,15 @@ func (q *Queries) AddPackagesWithConflict(ctx context.Context, records []pgdb.Pa
 		parentIdMap[r.ParentId] = append(parentIdMap[r.ParentId], r)
 	}
 
+	// Check every folder before inserting, so nothing is inserted if any name conflicts.
+	if strategy == conflictStrategy.Fail {
+		for parentId, pRecords := range parentIdMap {
+			if err := q.checkNameConflicts(ctx, parentId, pRecords); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	var allInsertedPackages []pgdb.Package
 	for parentId, pRecords := range parentIdMap {
 		var inserted []pgdb.Package
@@ -128,11 +138,13 @@ func (q *Queries) AddPackagesWithConflict(ctx context.Context, records []pgdb.Pa
 			inserted, err = q.addPackagesKeepBoth(ctx, parentId, pRecords)
 		case conflictStrategy.Replace:
 			inserted, err = q.addPackagesReplace(ctx, parentId, pRecords)
+		case conflictStrategy.Fail:
+			inserted, err = q.addPackagesFail(ctx, parentId, pRecords)
 		default:
-			return nil, fmt.Errorf("unknown conflict strategy: %s", strategy)
+			return nil, dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("unknown conflict strategy: %s", strategy))
 		}
 		if err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
 		allInsertedPackages = append(allInsertedPackages, inserted...)
 	}
@@ -146,7 +158,7 @@ func (q *Queries) addPackagesKeepBoth(ctx context.Context, parentId int64, recor
 
 	insertedPackages, failedPackages, err := q.addPackageByParent(ctx, parentId, records, nil)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	allInsertedPackages = append(allInsertedPackages, insertedPackages...)
 
@@ -165,7 +177,7 @@ func (q *Queries) addPackagesKeepBoth(ctx context.Context, parentId int64, recor
 
 		insertedPackages, failedPackages, err = q.addPackageByParent(ctx, parentId, failedPackages, nil)
 		if err != nil {
-			return nil, err
+			return nil, mapError(err)
 		}
 
 		allInsertedPackages = append(allInsertedPackages, insertedPackages...)
@@ -175,6 +187,19 @@ func (q *Queries) addPackagesKeepBoth(ctx context.Context, parentId int64, recor
 	return allInsertedPackages, nil
 }
 
+// addPackagesFail inserts the records, and returns a PackageNameConflictError if a concurrent insert claimed
+// one of the names after checkNameConflicts.
+func (q *Queries) addPackagesFail(ctx context.Context, parentId int64, records []pgdb.PackageParams) ([]pgdb.Package, error) {
+	inserted, failed, err := q.addPackageByParent(ctx, parentId, records, nil)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if len(failed) > 0 {
+		return nil, PackageNameConflictError{fmt.Sprintf("%d packages could not be inserted because of a name conflict", len(failed))}
+	}
+	return inserted, nil
+}
+
 // addPackagesReplace soft-deletes each conflicting predecessor, decrements
 // its storage counts, inserts the new packages with replaces_package_id set,
 // then writes the back-reference. Async S3 asset cleanup is the caller's
@@ -184,7 +209,7 @@ func (q *Queries) addPackagesKeepBoth(ctx context.Context, parentId int64, recor
 func (q *Queries) addPackagesReplace(ctx context.Context, parentId int64, records []pgdb.PackageParams) ([]pgdb.Package, error) {
 	conflicts, err := q.findConflictingPackages(ctx, parentId, records)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	replacementByNodeId := map[string]int64{}
@@ -196,44 +221,18 @@ func (q *Queries) addPackagesReplace(ctx context.Context, parentId int64, record
 
 	datasetId := int64(records[0].DatasetId)
 
-	// Rename + soft-delete each predecessor so the new insert doesn't trip
-	// the unique (name, dataset_id, parent_id) partial indexes, and decrement
-	// its storage so dataset/ancestor counts reflect the removal. Mirrors
-	// pennsieve-api's PackageManager.delete behavior on the DB side.
 	for _, old := range conflicts {
-		newName := fmt.Sprintf("__DELETED__%s_%s", old.NodeId, old.Name)
-		_, err := q.db.ExecContext(ctx,
-			"UPDATE packages SET state=$1, name=$2 WHERE id=$3",
-			packageState.Deleting.String(), newName, old.Id)
-		if err != nil {
-			return nil, fmt.Errorf("soft-deleting predecessor %d: %w", old.Id, err)
-		}
-
-		size, err := q.GetPackageStorageById(ctx, old.Id)
-		if err != nil {
-			// No storage row just means no decrement needed.
-			if errors.Is(err, sql.ErrNoRows) {
-				continue
-			}
-			return nil, fmt.Errorf("reading storage for predecessor %d: %w", old.Id, err)
-		}
-		if size <= 0 {
-			continue
-		}
-		if err := q.IncrementPackageStorageAncestors(ctx, old.Id, -size); err != nil {
-			return nil, fmt.Errorf("decrementing package/ancestor storage for predecessor %d: %w", old.Id, err)
-		}
-		if err := q.IncrementDatasetStorage(ctx, datasetId, -size); err != nil {
-			return nil, fmt.Errorf("decrementing dataset storage for predecessor %d: %w", old.Id, err)
+		if _, err := q.softDeletePredecessor(ctx, datasetId, old); err != nil {
+			return nil, mapError(err)
 		}
 	}
 
 	inserted, failed, err := q.addPackageByParent(ctx, parentId, records, replacementByNodeId)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	if len(failed) > 0 {
-		return nil, fmt.Errorf("replace: %d unexpected insert failures after predecessor rename", len(failed))
+		return nil, dbErrors.New(dbErrors.ErrConflict, fmt.Sprintf("replace: %d unexpected insert failures after predecessor rename", len(failed)))
 	}
 
 	// Link back: old.replaced_by_package_id = new.id
@@ -245,13 +244,47 @@ func (q *Queries) addPackagesReplace(ctx context.Context, parentId int64, record
 			"UPDATE packages SET replaced_by_package_id=$1 WHERE id=$2",
 			p.Id, p.ReplacesPackageId.Int64)
 		if err != nil {
-			return nil, fmt.Errorf("setting back-ref on predecessor %d: %w", p.ReplacesPackageId.Int64, err)
+			return nil, mapError(fmt.Errorf("setting back-ref on predecessor %d: %w", p.ReplacesPackageId.Int64, err))
 		}
 	}
 
 	return inserted, nil
 }
 
+// softDeletePredecessor renames and soft-deletes a package that is being
+// replaced so a new package can take its name without tripping the unique
+// (name, dataset_id, parent_id) partial indexes, and decrements its storage so
+// dataset/ancestor counts reflect the removal. Mirrors pennsieve-api's
+// PackageManager.delete behavior on the DB side.
+// It returns the storage size that was removed from the dataset.
+func (q *Queries) softDeletePredecessor(ctx context.Context, datasetId int64, old *pgdb.Package) (int64, error) {
+	_, err := q.db.ExecContext(ctx,
+		"UPDATE packages SET state=$1, name=$2 WHERE id=$3",
+		packageState.Deleting.String(), deletedName(old), old.Id)
+	if err != nil {
+		return 0, mapError(fmt.Errorf("soft-deleting predecessor %d: %w", old.Id, err))
+	}
+
+	size, err := q.GetPackageStorageById(ctx, old.Id)
+	if err != nil {
+		// No storage row just means no decrement needed.
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, mapError(fmt.Errorf("reading storage for predecessor %d: %w", old.Id, err))
+	}
+	if size <= 0 {
+		return 0, nil
+	}
+	if err := q.DecrementPackageStorageAncestors(ctx, old.Id, size); err != nil {
+		return 0, mapError(fmt.Errorf("decrementing package/ancestor storage for predecessor %d: %w", old.Id, err))
+	}
+	if err := q.DecrementDatasetStorage(ctx, datasetId, size); err != nil {
+		return 0, mapError(fmt.Errorf("decrementing dataset storage for predecessor %d: %w", old.Id, err))
+	}
+	return size, nil
+}
+
 // findConflictingPackages returns existing, non-deleted packages under the
 // given parent whose name matches any of the incoming records.
 func (q *Queries) findConflictingPackages(ctx context.Context, parentId int64, records []pgdb.PackageParams) (map[string]*pgdb.Package, error) {
@@ -268,12 +301,12 @@ func (q *Queries) findConflictingPackages(ctx context.Context, parentId int64, r
 	var queryStr string
 	var args []interface{}
 	if parentId < 0 {
-		queryStr = "SELECT id, name, node_id FROM packages " +
+		queryStr = "SELECT id, name, node_id, type FROM packages " +
 			"WHERE dataset_id=$1 AND parent_id IS NULL " +
 			"AND state NOT IN ($2, $3) AND name = ANY($4)"
 		args = []interface{}{datasetId, packageState.Deleting.String(), packageState.Deleted.String(), pq.Array(names)}
 	} else {
-		queryStr = "SELECT id, name, node_id FROM packages " +
+		queryStr = "SELECT id, name, node_id, type FROM packages " +
 			"WHERE dataset_id=$1 AND parent_id=$2 " +
 			"AND state NOT IN ($3, $4) AND name = ANY($5)"
 		args = []interface{}{datasetId, parentId, packageState.Deleting.String(), packageState.Deleted.String(), pq.Array(names)}
@@ -281,15 +314,15 @@ func (q *Queries) findConflictingPackages(ctx context.Context, parentId int64, r
 
 	rows, err := q.db.QueryContext(ctx, queryStr, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	defer rows.Close()
 
 	conflicts := map[string]*pgdb.Package{}
 	for rows.Next() {
 		var p pgdb.Package
-		if err := rows.Scan(&p.Id, &p.Name, &p.NodeId); err != nil {
-			return nil, err
+		if err := rows.Scan(&p.Id, &p.Name, &p.NodeId, &p.PackageType); err != nil {
+			return nil, mapError(err)
 		}
 		pkg := p
 		conflicts[pkg.Name] = &pkg
@@ -300,30 +333,33 @@ func (q *Queries) findConflictingPackages(ctx context.Context, parentId int64, r
 // GetPackageChildren Get the children in a package
 func (q *Queries) GetPackageChildren(ctx context.Context, parent *pgdb.Package, datasetId int, onlyFolders bool) ([]pgdb.Package, error) {
 
-	folderFilter := ""
-	if onlyFolders {
-		folderFilter = fmt.Sprintf("AND type = '%s'", packageType.Collection.String())
-	}
-
 	// Return children for specific dataset in specific org with specific parent.
 	// Do NOT return any packages that are in DELETE State
 	queryRows := "id, name, type, state, node_id, parent_id, " +
 		"dataset_id, owner_id, size, created_at, updated_at"
 
+	args := []any{datasetId, packageState.Deleting.String()}
+
 	// If parent is empty => return children of root of dataset.
-	var queryStr string
-	if parent == nil {
-		queryStr = fmt.Sprintf("SELECT %s FROM packages WHERE dataset_id = %d AND parent_id IS NULL AND state != '%s' %s;",
-			queryRows, datasetId, packageState.Deleting.String(), folderFilter)
-	} else {
-		queryStr = fmt.Sprintf("SELECT %s FROM packages WHERE dataset_id = %d AND parent_id = %d AND state != '%s' %s;",
-			queryRows, datasetId, parent.Id, packageState.Deleting.String(), folderFilter)
+	parentFilter := "parent_id IS NULL"
+	if parent != nil {
+		args = append(args, parent.Id)
+		parentFilter = fmt.Sprintf("parent_id = $%d", len(args))
 	}
 
-	rows, err := q.db.QueryContext(ctx, queryStr)
+	folderFilter := ""
+	if onlyFolders {
+		args = append(args, packageType.Collection.String())
+		folderFilter = fmt.Sprintf("AND type = $%d", len(args))
+	}
+
+	queryStr := fmt.Sprintf("SELECT %s FROM packages WHERE dataset_id = $1 AND %s AND state != $2 %s;",
+		queryRows, parentFilter, folderFilter)
+
+	rows, err := q.db.QueryContext(ctx, queryStr, args...)
 	var allPackages []pgdb.Package
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	for rows.Next() {
@@ -344,12 +380,12 @@ func (q *Queries) GetPackageChildren(ctx context.Context, parent *pgdb.Package,
 
 		if err != nil {
 			log.Println("ERROR: ", err)
-			return nil, err
+			return nil, mapError(err)
 		}
 
 		allPackages = append(allPackages, currentRecord)
 	}
-	return allPackages, err
+	return allPackages, mapError(err)
 }
 
 func (q *Queries) GetPackageByNodeId(ctx context.Context, nodeId string) (*pgdb.Package, error) {
@@ -357,8 +393,8 @@ func (q *Queries) GetPackageByNodeId(ctx context.Context, nodeId string) (*pgdb.
 	queryRows := "id, name, type, state, node_id, parent_id, " +
 		"dataset_id, owner_id, size, created_at, updated_at"
 
-	queryStr := fmt.Sprintf("SELECT %s FROM packages WHERE node_id = %s", queryRows, nodeId)
-	result := q.db.QueryRowContext(ctx, queryStr)
+	queryStr := fmt.Sprintf("SELECT %s FROM packages WHERE node_id = $1", queryRows)
+	result := q.db.QueryRowContext(ctx, queryStr, nodeId)
 
 	currentRecord := pgdb.Package{}
 	err := result.Scan(&currentRecord.Id,
@@ -376,31 +412,40 @@ func (q *Queries) GetPackageByNodeId(ctx context.Context, nodeId string) (*pgdb.
 
 	if err != nil {
 		log.Println("ERROR: ", err)
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return &currentRecord, nil
 
 }
 
+// GetPackageById returns the package with the provided id.
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no such package exists.
+func (q *Queries) GetPackageById(ctx context.Context, packageId int64) (*pgdb.Package, error) {
+	queryStr := fmt.Sprintf("SELECT %s FROM packages WHERE id = $1", packageColumns)
+	return scanPackage(q.db.QueryRowContext(ctx, queryStr, packageId))
+}
+
 // GetPackageAncestorIds returns an array of Package Ids corresponding with the ancestor Package Ids for the provided package.
 //   - resulting array includes requested package Id as first entry
+//   - resulting array is ordered from the package to the root, each entry is the parent of the previous entry
 //   - resulting array includes first folder in dataset as last entry if package is in nested folder
 func (q *Queries) GetPackageAncestorIds(ctx context.Context, packageId int64) ([]int64, error) {
 
 	queryStr := "" +
-		"WITH RECURSIVE ancestors(id, parent_id) AS (" +
+		"WITH RECURSIVE ancestors(id, parent_id, depth) AS (" +
 		"SELECT " +
 		"packages.id, " +
-		"packages.parent_id " +
+		"packages.parent_id, " +
+		"0 " +
 		"FROM packages packages " +
 		"WHERE packages.id = $1 " +
 		"UNION " +
-		"SELECT parents.id, parents.parent_id " +
+		"SELECT parents.id, parents.parent_id, ancestors.depth + 1 " +
 		"FROM packages parents " +
 		"JOIN ancestors ON ancestors.parent_id = parents.id" +
 		") " +
-		"SELECT id FROM ancestors "
+		"SELECT id FROM ancestors ORDER BY depth"
 
 	rows, err := q.db.QueryContext(ctx, queryStr, packageId)
 	if err != nil {
@@ -416,14 +461,14 @@ func (q *Queries) GetPackageAncestorIds(ctx context.Context, packageId int64) ([
 
 			if err != nil {
 				log.Error("Error scanning package ids from results: ", err)
-				return nil, err
+				return nil, mapError(err)
 			}
 			ancestorIds = append(ancestorIds, *currentRow)
 
 		}
 		return ancestorIds, nil
 	}
-	return ancestorIds, err
+	return ancestorIds, mapError(err)
 }
 
 // PRIVATE
@@ -449,7 +494,7 @@ func (q *Queries) addPackageByParent(ctx context.Context, parentId int64, record
 	for _, r := range records {
 		// Check all packages share provided parent
 		if r.ParentId != parentId {
-			return nil, nil, errors.New("mismatch provided parentID and parentId in packageParams")
+			return nil, nil, dbErrors.New(dbErrors.ErrInvalid, "mismatch provided parentID and parentId in packageParams")
 		}
 
 		// Check for name duplication and return duplicates as failed inserts
@@ -510,7 +555,7 @@ func (q *Queries) addPackageByParent(ctx context.Context, parentId int64, record
 	// prepare the statement
 	stmt, err := q.db.PrepareContext(ctx, sqlInsert)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error preparing addPackageByParent statement: %w", err)
+		return nil, nil, mapError(fmt.Errorf("error preparing addPackageByParent statement: %w", err))
 	}
 	//goland:noinspection GoUnhandledErrorResult
 	defer stmt.Close()
@@ -524,7 +569,7 @@ func (q *Queries) addPackageByParent(ctx context.Context, parentId int64, record
 		if pqErr, ok := err.(*pq.Error); ok {
 			log.Println(pqErr)
 		}
-		return nil, nil, err
+		return nil, nil, mapError(err)
 	}
 
 	var resultNodeIds []string
@@ -549,7 +594,7 @@ func (q *Queries) addPackageByParent(ctx context.Context, parentId int64, record
 
 		if err != nil {
 			log.Println("ERROR: ", err)
-			return nil, nil, err
+			return nil, nil, mapError(err)
 		}
 
 		// Only return newly inserted objects
@@ -576,6 +621,54 @@ func (q *Queries) addPackageByParent(ctx context.Context, parentId int64, record
 
 // HELPER FUNCTIONS
 
+// packageColumnsFormat lists the columns of the packages table read by scanPackage, in scan order.
+// The format argument is the (possibly empty) table alias prefix.
+const packageColumnsFormat = "%[1]sid, %[1]sname, %[1]stype, %[1]sstate, %[1]snode_id, %[1]sparent_id, " +
+	"%[1]sdataset_id, %[1]sowner_id, %[1]ssize, %[1]simport_id, COALESCE(%[1]sattributes, '[]'), " +
+	"%[1]screated_at, %[1]supdated_at, %[1]sreplaces_package_id, %[1]sreplaced_by_package_id"
+
+// packageColumns are the unqualified columns read by scanPackage.
+var packageColumns = packageColumnsWithAlias("")
+
+// packageColumnsWithAlias returns the columns read by scanPackage, qualified by the given table alias.
+func packageColumnsWithAlias(alias string) string {
+	if alias == "" {
+		return fmt.Sprintf(packageColumnsFormat, "")
+	}
+	return fmt.Sprintf(packageColumnsFormat, alias+".")
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPackage scans a row selected with packageColumns into a Package.
+func scanPackage(row rowScanner) (*pgdb.Package, error) {
+	var p pgdb.Package
+	err := row.Scan(
+		&p.Id,
+		&p.Name,
+		&p.PackageType,
+		&p.PackageState,
+		&p.NodeId,
+		&p.ParentId,
+		&p.DatasetId,
+		&p.OwnerId,
+		&p.Size,
+		&p.ImportId,
+		&p.Attributes,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+		&p.ReplacesPackageId,
+		&p.ReplacedByPackageId,
+	)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &p, nil
+}
+
 // contains checks if a string is present in a slice
 func contains(s []string, str string) bool {
 	for _, v := range s {
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/conflictStrategy"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
@@ -29,6 +30,7 @@ func TestPackageTable(t *testing.T) {
 		"Test getting ancestor Ids":      testGettingAncestors,
 		"Test conflict replace":          testConflictReplace,
 		"Test conflict replace no-op":    testConflictReplaceNoConflict,
+		"Test conflict fail":             testConflictFail,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			orgId := 1
@@ -693,3 +695,28 @@ func testConflictReplaceNoConflict(t *testing.T, store *SQLStore, orgId int) {
 	assert.False(t, result[0].ReplacesPackageId.Valid, "No conflict = no replaces_package_id")
 	assert.False(t, result[0].ReplacedByPackageId.Valid, "Fresh insert should have null replaced_by_package_id")
 }
+
+// testConflictFail verifies the Fail strategy inserts nothing if any name conflicts.
+func testConflictFail(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+
+	ctx := context.Background()
+	_, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "existing.txt", ParentId: -1},
+	}, 1))
+	assert.NoError(t, err)
+
+	_, err = store.AddPackagesWithConflict(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "new.txt", ParentId: -1},
+		{Name: "existing.txt", ParentId: -1},
+	}, 1), conflictStrategy.Fail)
+	assert.IsType(t, PackageNameConflictError{}, err)
+	assert.ErrorIs(t, err, dbErrors.ErrConflict)
+	assert.Equal(t, 1, countRows(t, store.db, "packages"), "nothing is inserted if a name conflicts")
+
+	inserted, err := store.AddPackagesWithConflict(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "new.txt", ParentId: -1},
+	}, 1), conflictStrategy.Fail)
+	assert.NoError(t, err)
+	assert.Equal(t, "new.txt", inserted[0].Name)
+}
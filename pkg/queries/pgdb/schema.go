@@ -0,0 +1,241 @@
+package pgdb
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The tables of the Pennsieve platform are created by the pennsieve-api migrations. The tables that only
+// pennsieve-go-core uses, such as the storage quotas, are defined in the schema directory of this package:
+//   - schema/pennsieve is applied once, to the pennsieve schema.
+//   - schema/organization is applied to the schema of every organization.
+//
+// The files of each directory run in name order. Every applied file is recorded in
+// pennsieve.go_core_schema_migrations and does not run again; CheckSchema reports the files that did not
+// run. Statements must still be idempotent, because a file that fails can be run again.
+
+//go:embed schema
+var schemaFiles embed.FS
+
+const (
+	pennsieveSchemaDir    = "schema/pennsieve"
+	organizationSchemaDir = "schema/organization"
+	pennsieveSchemaName   = "pennsieve"
+)
+
+// undefinedTableCode is the PostgreSQL error code of a query on a table that does not exist.
+const undefinedTableCode = "42P01"
+
+// schemaMigrationsTable records the schema files applied to the pennsieve schema and to each organization
+// schema. It is created by execSchemaDir, because it must exist before the first file runs.
+const schemaMigrationsTable = "CREATE TABLE IF NOT EXISTS pennsieve.go_core_schema_migrations (" +
+	"schema_name VARCHAR(255) NOT NULL, " +
+	"file_name   VARCHAR(255) NOT NULL, " +
+	"applied_at  TIMESTAMP NOT NULL DEFAULT now(), " +
+	"PRIMARY KEY (schema_name, file_name))"
+
+// SchemaNotAppliedError is returned when a table defined in the schema directory does not exist, because
+// ApplySchema or ApplyOrganizationSchema did not run. It has no dbErrors kind: the deployment is incomplete.
+type SchemaNotAppliedError struct {
+	Schema string   // The missing schema, such as schema/organization.
+	Files  []string // The files that were not applied, if known.
+	Err    error
+}
+
+func (e SchemaNotAppliedError) Error() string {
+	missing := e.Schema
+	if len(e.Files) > 0 {
+		missing = strings.Join(e.Files, ", ")
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("schema not applied (error: %s is missing, run SQLStore.ApplySchema: %v)", missing, e.Err)
+	}
+	return fmt.Sprintf("schema not applied (error: %s is missing, run SQLStore.ApplySchema)", missing)
+}
+
+func (e SchemaNotAppliedError) Unwrap() error {
+	return e.Err
+}
+
+// ApplySchema creates the tables defined in the schema directory in the pennsieve schema and in the schema
+// of every organization. It can run on every deploy, and must run before the queries that use these tables.
+// Organizations created later need ApplyOrganizationSchema.
+func (store *SQLStore) ApplySchema(ctx context.Context) error {
+	err := store.ExecTx(ctx, TxOptions{}, func(qtx *TxQueries) error {
+		return qtx.execSchemaDir(ctx, pennsieveSchemaDir, pennsieveSchemaName)
+	})
+	if err != nil {
+		return mapError(err)
+	}
+
+	// Organizations whose schema was not created yet are skipped.
+	rows, err := store.db.QueryContext(ctx, "SELECT id FROM pennsieve.organizations "+
+		"WHERE to_regclass(format('%I.datasets', id::text)) IS NOT NULL ORDER BY id")
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	var organizationIds []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return mapError(err)
+		}
+		organizationIds = append(organizationIds, id)
+	}
+	if err := rows.Err(); err != nil {
+		return mapError(err)
+	}
+
+	for _, id := range organizationIds {
+		if err := store.ApplyOrganizationSchema(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyOrganizationSchema creates the tables defined in schema/organization in the schema of an organization.
+func (store *SQLStore) ApplyOrganizationSchema(ctx context.Context, organizationId int64) error {
+	if _, err := orgSchema(organizationId); err != nil {
+		return err
+	}
+	err := store.ExecTx(ctx, TxOptions{OrganizationId: organizationId}, func(qtx *TxQueries) error {
+		return qtx.execSchemaDir(ctx, organizationSchemaDir, strconv.FormatInt(organizationId, 10))
+	})
+	if err != nil {
+		log.Error(fmt.Sprintf("Unable to apply schema to organization %d: %v", organizationId, err))
+	}
+	return mapError(err)
+}
+
+// CheckSchema returns a SchemaNotAppliedError that lists the files of the schema directory that were not
+// applied to the pennsieve schema or to the schema of the organization, or nil if all were applied.
+func (q *Queries) CheckSchema(ctx context.Context, organizationId int64) error {
+	if _, err := orgSchema(organizationId); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, s := range []struct{ dir, name string }{
+		{pennsieveSchemaDir, pennsieveSchemaName},
+		{organizationSchemaDir, strconv.FormatInt(organizationId, 10)},
+	} {
+		files, err := schemaDirFiles(s.dir)
+		if err != nil {
+			return err
+		}
+		applied, err := q.appliedSchemaFiles(ctx, s.name)
+		if err != nil && !isUndefinedTable(err) {
+			return mapError(err)
+		}
+		for _, file := range files {
+			if !applied[file] {
+				missing = append(missing, path.Join(s.dir, file))
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return SchemaNotAppliedError{Schema: fmt.Sprintf("schema of organization %d", organizationId), Files: missing}
+	}
+	return nil
+}
+
+// execSchemaDir runs the .sql files of an embedded schema directory that were not applied to the schema
+// with the given name yet, in name order, and records them.
+func (q *Queries) execSchemaDir(ctx context.Context, dir string, schemaName string) error {
+	if _, err := q.db.ExecContext(ctx, schemaMigrationsTable); err != nil {
+		return mapError(fmt.Errorf("creating schema migrations table: %w", err))
+	}
+	// Deploys that apply the schema at the same time wait for each other instead of running a file twice.
+	if _, err := q.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('go_core_schema_migrations.' || $1))", schemaName); err != nil {
+		return mapError(err)
+	}
+
+	applied, err := q.appliedSchemaFiles(ctx, schemaName)
+	if err != nil {
+		return mapError(err)
+	}
+	files, err := schemaDirFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if applied[file] {
+			continue
+		}
+		statements, err := fs.ReadFile(schemaFiles, path.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, string(statements)); err != nil {
+			return mapError(fmt.Errorf("applying %s: %w", file, err))
+		}
+		if _, err := q.db.ExecContext(ctx, "INSERT INTO pennsieve.go_core_schema_migrations (schema_name, file_name) "+
+			"VALUES ($1, $2) ON CONFLICT DO NOTHING", schemaName, file); err != nil {
+			return mapError(fmt.Errorf("recording %s: %w", file, err))
+		}
+	}
+	return nil
+}
+
+// appliedSchemaFiles returns the names of the schema files applied to the schema with the given name.
+func (q *Queries) appliedSchemaFiles(ctx context.Context, schemaName string) (map[string]bool, error) {
+	rows, err := q.db.QueryContext(ctx,
+		"SELECT file_name FROM pennsieve.go_core_schema_migrations WHERE schema_name = $1", schemaName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, err
+		}
+		applied[file] = true
+	}
+	return applied, rows.Err()
+}
+
+// schemaDirFiles returns the names of the .sql files of an embedded schema directory, in name order.
+func schemaDirFiles(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(schemaFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	return files, nil
+}
+
+// schemaError returns err as a SchemaNotAppliedError if it is caused by a missing table of schemaDir,
+// and maps it with mapError otherwise.
+func schemaError(schemaDir string, err error) error {
+	if isUndefinedTable(err) {
+		return SchemaNotAppliedError{Schema: schemaDir, Err: err}
+	}
+	return mapError(err)
+}
+
+// isUndefinedTable returns true if err is caused by a query on a table that does not exist.
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == undefinedTableCode
+}
@@ -0,0 +1,6 @@
+-- Maximum storage of a dataset in bytes. A dataset without a row has unlimited storage.
+-- Runs with the search_path set to the organization schema.
+CREATE TABLE IF NOT EXISTS dataset_storage_quota (
+    dataset_id INTEGER PRIMARY KEY REFERENCES datasets (id) ON DELETE CASCADE,
+    max_size   BIGINT NOT NULL
+);
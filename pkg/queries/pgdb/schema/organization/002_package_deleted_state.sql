@@ -0,0 +1,6 @@
+-- State of a package before it was soft-deleted, so that a restore can return the package to it.
+-- Runs with the search_path set to the organization schema.
+CREATE TABLE IF NOT EXISTS package_deleted_state (
+    package_id INTEGER PRIMARY KEY REFERENCES packages (id) ON DELETE CASCADE,
+    state      VARCHAR(255) NOT NULL
+);
@@ -0,0 +1,14 @@
+-- A dataset has at most one owner. Concurrent inserts of a second owner fail with a unique violation.
+-- Runs with the search_path set to the organization schema.
+
+-- Nothing prevented datasets with several owners before this index. The earliest owner, the one
+-- getDatasetOwnerId returns, keeps the dataset; the others become managers, as in TransferOwnership.
+UPDATE dataset_user SET role = 'manager', permission_bit = 16, updated_at = now()
+FROM (SELECT dataset_id, user_id,
+             ROW_NUMBER() OVER (PARTITION BY dataset_id ORDER BY created_at, user_id) AS owner_rank
+      FROM dataset_user WHERE role = 'owner') AS owners
+WHERE owners.owner_rank > 1
+  AND dataset_user.dataset_id = owners.dataset_id
+  AND dataset_user.user_id = owners.user_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS dataset_user_single_owner_idx ON dataset_user (dataset_id) WHERE role = 'owner';
@@ -0,0 +1,6 @@
+-- Package that a package was copied from by CopyPackageTree. The source is cleared when it is purged.
+-- Runs with the search_path set to the organization schema.
+CREATE TABLE IF NOT EXISTS package_copy_source (
+    package_id        INTEGER PRIMARY KEY REFERENCES packages (id) ON DELETE CASCADE,
+    source_package_id INTEGER REFERENCES packages (id) ON DELETE SET NULL
+);
@@ -0,0 +1,7 @@
+-- Storage reserved by ReserveStorage for files that are not added yet, in bytes. It is not part of
+-- dataset_storage, and is released by ReleaseStorage.
+-- Runs with the search_path set to the organization schema.
+CREATE TABLE IF NOT EXISTS dataset_storage_reservation (
+    dataset_id INTEGER PRIMARY KEY REFERENCES datasets (id) ON DELETE CASCADE,
+    size       BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,5 @@
+-- Maximum storage of an organization in bytes. An organization without a row has unlimited storage.
+CREATE TABLE IF NOT EXISTS pennsieve.organization_storage_quota (
+    organization_id BIGINT PRIMARY KEY REFERENCES pennsieve.organizations (id) ON DELETE CASCADE,
+    max_size        BIGINT NOT NULL
+);
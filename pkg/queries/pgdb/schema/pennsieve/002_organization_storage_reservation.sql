@@ -0,0 +1,6 @@
+-- Storage reserved by ReserveStorage for files that are not added yet, in bytes. It is not part of
+-- pennsieve.organization_storage, and is released by ReleaseStorage.
+CREATE TABLE IF NOT EXISTS pennsieve.organization_storage_reservation (
+    organization_id BIGINT PRIMARY KEY REFERENCES pennsieve.organizations (id) ON DELETE CASCADE,
+    size            BIGINT NOT NULL DEFAULT 0
+);
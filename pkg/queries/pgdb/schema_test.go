@@ -0,0 +1,103 @@
+package pgdb
+
+import (
+	"context"
+	"errors"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestApplySchema(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(testDB[0])
+
+	// TestMain already applied the schema, applying it again has no effect.
+	require.NoError(t, store.ApplySchema(ctx))
+
+	for _, table := range []string{"pennsieve.organization_storage_quota", `"1".dataset_storage_quota`, `"3".dataset_storage_quota`, `"1".package_deleted_state`, `"1".package_copy_source`,
+		"pennsieve.organization_storage_reservation", `"1".dataset_storage_reservation`} {
+		var exists bool
+		require.NoError(t, store.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists))
+		assert.True(t, exists, table)
+	}
+
+	for _, organizationId := range []int64{1, 3} {
+		assert.NoError(t, store.CheckSchema(ctx, organizationId))
+	}
+
+	assert.ErrorIs(t, store.ApplyOrganizationSchema(ctx, 0), dbErrors.ErrInvalid)
+}
+
+func TestCheckSchema(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(testDB[0])
+	rollback := errors.New("rollback")
+
+	err := store.ExecTx(ctx, TxOptions{OrganizationId: 1}, func(qtx *TxQueries) error {
+		_, err := qtx.db.ExecContext(ctx, "DELETE FROM pennsieve.go_core_schema_migrations "+
+			"WHERE schema_name = '1' AND file_name = '002_package_deleted_state.sql'")
+		require.NoError(t, err)
+
+		var schemaErr SchemaNotAppliedError
+		if assert.ErrorAs(t, qtx.CheckSchema(ctx, 1), &schemaErr) {
+			assert.Equal(t, []string{"schema/organization/002_package_deleted_state.sql"}, schemaErr.Files)
+		}
+		assert.NoError(t, qtx.CheckSchema(ctx, 3))
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+}
+
+func TestMissingQuotaTable(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(testDB[0])
+	rollback := errors.New("rollback")
+
+	// DDL is transactional, the table is back after the rollback.
+	err := store.ExecTx(ctx, TxOptions{OrganizationId: 1}, func(qtx *TxQueries) error {
+		_, err := qtx.db.ExecContext(ctx, "DROP TABLE dataset_storage_quota")
+		require.NoError(t, err)
+
+		assert.NoError(t, qtx.CheckQuota(ctx, 1, 1, 100), "a missing quota table is no quota")
+
+		_, err = qtx.GetDatasetStorageQuota(ctx, 1)
+		var schemaErr SchemaNotAppliedError
+		if assert.ErrorAs(t, err, &schemaErr) {
+			assert.Equal(t, organizationSchemaDir, schemaErr.Schema)
+		}
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+}
+
+func TestApplySchemaDemotesExtraOwners(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(testDB[1])
+	datasetId := addTestDataset(store.db, "Test Dataset - ApplySchemaDemotesExtraOwners")
+	defer store.db.Exec("DELETE FROM datasets WHERE id = $1", datasetId)
+	defer store.db.Exec("DELETE FROM dataset_user WHERE dataset_id = $1", datasetId)
+
+	// Datasets with several owners predate the single owner index.
+	_, err := store.db.Exec(`DROP INDEX "1".dataset_user_single_owner_idx`)
+	require.NoError(t, err)
+	_, err = store.db.Exec("DELETE FROM pennsieve.go_core_schema_migrations " +
+		"WHERE schema_name = '1' AND file_name = '003_dataset_user_single_owner.sql'")
+	require.NoError(t, err)
+	_, err = store.db.Exec("INSERT INTO dataset_user (dataset_id, user_id, role, permission_bit, created_at) "+
+		"VALUES ($1, 1001, 'owner', 32, now() - interval '1 day'), ($1, 1002, 'owner', 32, now())", datasetId)
+	require.NoError(t, err)
+
+	require.NoError(t, store.ApplyOrganizationSchema(ctx, 1))
+
+	ownerId, err := store.getDatasetOwnerId(ctx, datasetId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001), ownerId)
+	datasetUser, err := store.getDatasetUser(ctx, datasetId, 1002)
+	require.NoError(t, err)
+	assert.Equal(t, "manager", datasetUser.Role)
+
+	_, err = store.db.Exec("UPDATE dataset_user SET role = 'owner' WHERE dataset_id = $1 AND user_id = 1002", datasetId)
+	assert.True(t, isSingleOwnerViolation(err), "expected the single owner index to be created")
+}
@@ -0,0 +1,223 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageType"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileTypeStorage is the storage used by the files of a single file type.
+type FileTypeStorage struct {
+	FileType  fileType.Type `json:"file_type"`
+	Size      int64         `json:"size"`
+	FileCount int64         `json:"file_count"`
+}
+
+// OwnerStorage is the storage used by the files in packages owned by a single user.
+type OwnerStorage struct {
+	OwnerId   int64 `json:"owner_id"`
+	Size      int64 `json:"size"`
+	FileCount int64 `json:"file_count"`
+}
+
+// FolderStorage is the storage used by the files in a top-level folder of a dataset.
+// Files in packages at the root of the dataset are grouped under PackageId 0.
+type FolderStorage struct {
+	PackageId int64  `json:"package_id"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	FileCount int64  `json:"file_count"`
+}
+
+// DatasetStorage is the storage used by the files in a single dataset.
+type DatasetStorage struct {
+	DatasetId int64  `json:"dataset_id"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	FileCount int64  `json:"file_count"`
+}
+
+// DatasetStorageBreakdown is the storage used by a dataset, grouped in multiple ways.
+type DatasetStorageBreakdown struct {
+	DatasetId        int64             `json:"dataset_id"`
+	Size             int64             `json:"size"`
+	FileCount        int64             `json:"file_count"`
+	ByFileType       []FileTypeStorage `json:"by_file_type"`
+	ByOwner          []OwnerStorage    `json:"by_owner"`
+	ByTopLevelFolder []FolderStorage   `json:"by_top_level_folder"`
+}
+
+// OrganizationStorageBreakdown is the storage used by all datasets of an organization, grouped in multiple ways.
+type OrganizationStorageBreakdown struct {
+	Size       int64             `json:"size"`
+	FileCount  int64             `json:"file_count"`
+	ByDataset  []DatasetStorage  `json:"by_dataset"`
+	ByFileType []FileTypeStorage `json:"by_file_type"`
+	ByOwner    []OwnerStorage    `json:"by_owner"`
+}
+
+// liveFilesFrom selects the files of non-deleted packages, optionally limited to a dataset.
+// Parameters: $1, $2 are the deleted package states, $3 is the dataset id or null.
+const liveFilesFrom = "FROM files f JOIN packages p ON p.id = f.package_id " +
+	"WHERE p.state NOT IN ($1, $2) AND ($3::integer IS NULL OR p.dataset_id = $3) "
+
+// GetDatasetStorageBreakdown returns the storage used by the files of a dataset, grouped by file type,
+// by package owner and by top-level folder. Files of deleted packages are not included.
+// The sizes are computed from the files table and do not use the storage counters.
+func (q *Queries) GetDatasetStorageBreakdown(ctx context.Context, datasetId int64) (*DatasetStorageBreakdown, error) {
+	dataset := sql.NullInt64{Int64: datasetId, Valid: true}
+	breakdown := DatasetStorageBreakdown{DatasetId: datasetId}
+
+	var err error
+	breakdown.ByFileType, err = q.storageByFileType(ctx, dataset)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	breakdown.ByOwner, err = q.storageByOwner(ctx, dataset)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	breakdown.ByTopLevelFolder, err = q.storageByTopLevelFolder(ctx, datasetId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	for _, s := range breakdown.ByFileType {
+		breakdown.Size += s.Size
+		breakdown.FileCount += s.FileCount
+	}
+	return &breakdown, nil
+}
+
+// GetOrganizationStorageBreakdown returns the storage used by the files of all datasets in the
+// organization, grouped by dataset, by file type and by package owner.
+// The queries run against the organization schema selected for the connection, see OrgQueries.
+func (q *Queries) GetOrganizationStorageBreakdown(ctx context.Context) (*OrganizationStorageBreakdown, error) {
+	all := sql.NullInt64{}
+	breakdown := OrganizationStorageBreakdown{}
+
+	var err error
+	breakdown.ByDataset, err = q.storageByDataset(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	breakdown.ByFileType, err = q.storageByFileType(ctx, all)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	breakdown.ByOwner, err = q.storageByOwner(ctx, all)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	for _, s := range breakdown.ByDataset {
+		breakdown.Size += s.Size
+		breakdown.FileCount += s.FileCount
+	}
+	return &breakdown, nil
+}
+
+func (q *Queries) storageByFileType(ctx context.Context, datasetId sql.NullInt64) ([]FileTypeStorage, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT f.file_type, COALESCE(SUM(f.size), 0), COUNT(*) "+
+		liveFilesFrom+"GROUP BY f.file_type ORDER BY 2 DESC, 1",
+		packageState.Deleting.String(), packageState.Deleted.String(), datasetId)
+	if err != nil {
+		log.Error("Error computing storage by file type: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var result []FileTypeStorage
+	for rows.Next() {
+		var s FileTypeStorage
+		var fType string
+		if err := rows.Scan(&fType, &s.Size, &s.FileCount); err != nil {
+			return nil, mapError(err)
+		}
+		s.FileType = fileType.Dict[fType]
+		result = append(result, s)
+	}
+	return result, mapError(rows.Err())
+}
+
+func (q *Queries) storageByOwner(ctx context.Context, datasetId sql.NullInt64) ([]OwnerStorage, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT p.owner_id, COALESCE(SUM(f.size), 0), COUNT(*) "+
+		liveFilesFrom+"GROUP BY p.owner_id ORDER BY 2 DESC, 1",
+		packageState.Deleting.String(), packageState.Deleted.String(), datasetId)
+	if err != nil {
+		log.Error("Error computing storage by owner: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var result []OwnerStorage
+	for rows.Next() {
+		var s OwnerStorage
+		if err := rows.Scan(&s.OwnerId, &s.Size, &s.FileCount); err != nil {
+			return nil, mapError(err)
+		}
+		result = append(result, s)
+	}
+	return result, mapError(rows.Err())
+}
+
+func (q *Queries) storageByDataset(ctx context.Context) ([]DatasetStorage, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT d.id, d.name, COALESCE(SUM(f.size), 0), COUNT(f.id) "+
+		"FROM datasets d "+
+		"LEFT JOIN packages p ON p.dataset_id = d.id AND p.state NOT IN ($1, $2) "+
+		"LEFT JOIN files f ON f.package_id = p.id "+
+		"GROUP BY d.id, d.name ORDER BY 3 DESC, 1",
+		packageState.Deleting.String(), packageState.Deleted.String())
+	if err != nil {
+		log.Error("Error computing storage by dataset: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var result []DatasetStorage
+	for rows.Next() {
+		var s DatasetStorage
+		if err := rows.Scan(&s.DatasetId, &s.Name, &s.Size, &s.FileCount); err != nil {
+			return nil, mapError(err)
+		}
+		result = append(result, s)
+	}
+	return result, mapError(rows.Err())
+}
+
+func (q *Queries) storageByTopLevelFolder(ctx context.Context, datasetId int64) ([]FolderStorage, error) {
+	queryStr := "" +
+		"WITH RECURSIVE tree(id, top_id) AS (" +
+		"SELECT id, CASE WHEN type = $3 THEN id END FROM packages " +
+		"WHERE dataset_id = $4 AND parent_id IS NULL AND state NOT IN ($1, $2) " +
+		"UNION ALL " +
+		"SELECT children.id, tree.top_id FROM packages children " +
+		"JOIN tree ON children.parent_id = tree.id " +
+		"WHERE children.state NOT IN ($1, $2)" +
+		") " +
+		"SELECT COALESCE(tree.top_id, 0), COALESCE(top.name, ''), COALESCE(SUM(f.size), 0), COUNT(*) " +
+		"FROM tree JOIN files f ON f.package_id = tree.id " +
+		"LEFT JOIN packages top ON top.id = tree.top_id " +
+		"GROUP BY tree.top_id, top.name ORDER BY 3 DESC, 1"
+
+	rows, err := q.db.QueryContext(ctx, queryStr,
+		packageState.Deleting.String(), packageState.Deleted.String(), packageType.Collection.String(), datasetId)
+	if err != nil {
+		log.Error("Error computing storage by top-level folder: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	var result []FolderStorage
+	for rows.Next() {
+		var s FolderStorage
+		if err := rows.Scan(&s.PackageId, &s.Name, &s.Size, &s.FileCount); err != nil {
+			return nil, mapError(err)
+		}
+		result = append(result, s)
+	}
+	return result, mapError(rows.Err())
+}
@@ -0,0 +1,91 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/fileInfo/fileType"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestStorageBreakdown is the main Test Suite function for storage breakdowns.
+func TestStorageBreakdown(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Get dataset storage breakdown":      testGetDatasetStorageBreakdown,
+		"Get organization storage breakdown": testGetOrganizationStorageBreakdown,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testGetDatasetStorageBreakdown(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "nested", ParentId: folder.Id},
+		{Name: "root", ParentId: -1},
+		{Name: "deleted", ParentId: -1},
+	}, 1))
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting packages", err)
+	}
+	addTestFiles(t, store, int(packages[0].Id), "nested-1.edf", "nested-2.edf")
+	addTestFiles(t, store, int(packages[1].Id), "root.edf")
+	addTestFiles(t, store, int(packages[2].Id), "deleted.edf")
+
+	_, err = store.TransitionPackageState(ctx, packages[2].Id, packageState.Unavailable, packageState.Deleted)
+	assert.NoError(t, err)
+
+	breakdown, err := store.GetDatasetStorageBreakdown(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3*1024), breakdown.Size, "files of deleted packages should not be counted")
+		assert.Equal(t, int64(3), breakdown.FileCount)
+
+		assert.Equal(t, []FileTypeStorage{{FileType: fileType.EDF, Size: 3 * 1024, FileCount: 3}}, breakdown.ByFileType)
+		assert.Equal(t, []OwnerStorage{{OwnerId: 1, Size: 3 * 1024, FileCount: 3}}, breakdown.ByOwner)
+		assert.Equal(t, []FolderStorage{
+			{PackageId: folder.Id, Name: "folder", Size: 2 * 1024, FileCount: 2},
+			{PackageId: 0, Name: "", Size: 1024, FileCount: 1},
+		}, breakdown.ByTopLevelFolder)
+	}
+}
+
+func testGetOrganizationStorageBreakdown(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "packages")
+	defer test.Truncate(t, store.db, orgId, "files")
+
+	ctx := context.Background()
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "package", ParentId: -1},
+	}, 1))
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting packages", err)
+	}
+	addTestFiles(t, store, int(packages[0].Id), "a.edf", "b.edf")
+
+	breakdown, err := store.GetOrganizationStorageBreakdown(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2*1024), breakdown.Size)
+		assert.Equal(t, int64(2), breakdown.FileCount)
+
+		var datasetSize int64
+		for _, d := range breakdown.ByDataset {
+			if d.DatasetId == 1 {
+				datasetSize = d.Size
+			}
+		}
+		assert.Equal(t, int64(2*1024), datasetSize)
+		assert.Equal(t, []FileTypeStorage{{FileType: fileType.EDF, Size: 2 * 1024, FileCount: 2}}, breakdown.ByFileType)
+		assert.Equal(t, []OwnerStorage{{OwnerId: 1, Size: 2 * 1024, FileCount: 2}}, breakdown.ByOwner)
+	}
+}
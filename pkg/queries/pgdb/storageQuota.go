@@ -0,0 +1,283 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Storage quotas are stored next to the storage counters they limit, in pennsieve.organization_storage_quota
+// and in the dataset_storage_quota table of each organization schema. These tables are not created by the
+// pennsieve-api migrations but by SQLStore.ApplySchema, see schema/pennsieve and schema/organization.
+//
+// An organization or dataset without a quota row has unlimited storage. CheckQuota also treats a missing
+// quota table as no quota; the other quota queries return a SchemaNotAppliedError.
+//
+// Storage reserved for files that are not added yet is kept in separate counters, see ReserveStorage.
+
+// QuotaExceededError is returned when storing additional bytes would exceed an organization or dataset quota.
+type QuotaExceededError struct {
+	Scope     StorageScope
+	Id        int64
+	Limit     int64
+	Used      int64 // Stored and reserved bytes.
+	Requested int64
+}
+
+func (e QuotaExceededError) Error() string {
+	return fmt.Sprintf("storage quota exceeded (error: %s %d uses %d of %d bytes, %d more requested)",
+		e.Scope, e.Id, e.Used, e.Limit, e.Requested)
+}
+
+// Is returns true for dbErrors.ErrConflict.
+func (e QuotaExceededError) Is(target error) bool {
+	return target == dbErrors.ErrConflict
+}
+
+// SetOrganizationStorageQuota sets the maximum storage of an organization in bytes.
+func (q *Queries) SetOrganizationStorageQuota(ctx context.Context, organizationId int64, maxSize int64) error {
+	queryStr := "INSERT INTO pennsieve.organization_storage_quota (organization_id, max_size) VALUES ($1, $2) " +
+		"ON CONFLICT (organization_id) DO UPDATE SET max_size = EXCLUDED.max_size"
+
+	_, err := q.db.ExecContext(ctx, queryStr, organizationId, maxSize)
+	if err != nil {
+		log.Println("Error setting organization storage quota: ", err)
+	}
+	return schemaError(pennsieveSchemaDir, err)
+}
+
+// DeleteOrganizationStorageQuota removes the storage quota of an organization.
+func (q *Queries) DeleteOrganizationStorageQuota(ctx context.Context, organizationId int64) error {
+	_, err := q.db.ExecContext(ctx, "DELETE FROM pennsieve.organization_storage_quota WHERE organization_id = $1", organizationId)
+	if err != nil {
+		log.Println("Error deleting organization storage quota: ", err)
+	}
+	return schemaError(pennsieveSchemaDir, err)
+}
+
+// GetOrganizationStorageQuota returns the maximum storage of an organization in bytes.
+// Returns an error with errors.Is(err, dbErrors.ErrNotFound) if the organization does not have a quota.
+func (q *Queries) GetOrganizationStorageQuota(ctx context.Context, organizationId int64) (int64, error) {
+	var maxSize int64
+	err := q.db.QueryRowContext(ctx,
+		"SELECT max_size FROM pennsieve.organization_storage_quota WHERE organization_id = $1",
+		organizationId).Scan(&maxSize)
+	return maxSize, schemaError(pennsieveSchemaDir, err)
+}
+
+// SetDatasetStorageQuota sets the maximum storage of a dataset in bytes.
+func (q *Queries) SetDatasetStorageQuota(ctx context.Context, datasetId int64, maxSize int64) error {
+	queryStr := "INSERT INTO dataset_storage_quota (dataset_id, max_size) VALUES ($1, $2) " +
+		"ON CONFLICT (dataset_id) DO UPDATE SET max_size = EXCLUDED.max_size"
+
+	_, err := q.db.ExecContext(ctx, queryStr, datasetId, maxSize)
+	if err != nil {
+		log.Println("Error setting dataset storage quota: ", err)
+	}
+	return schemaError(organizationSchemaDir, err)
+}
+
+// DeleteDatasetStorageQuota removes the storage quota of a dataset.
+func (q *Queries) DeleteDatasetStorageQuota(ctx context.Context, datasetId int64) error {
+	_, err := q.db.ExecContext(ctx, "DELETE FROM dataset_storage_quota WHERE dataset_id = $1", datasetId)
+	if err != nil {
+		log.Println("Error deleting dataset storage quota: ", err)
+	}
+	return schemaError(organizationSchemaDir, err)
+}
+
+// GetDatasetStorageQuota returns the maximum storage of a dataset in bytes.
+// Returns an error with errors.Is(err, dbErrors.ErrNotFound) if the dataset does not have a quota.
+func (q *Queries) GetDatasetStorageQuota(ctx context.Context, datasetId int64) (int64, error) {
+	var maxSize int64
+	err := q.db.QueryRowContext(ctx,
+		"SELECT max_size FROM dataset_storage_quota WHERE dataset_id = $1",
+		datasetId).Scan(&maxSize)
+	return maxSize, schemaError(organizationSchemaDir, err)
+}
+
+// CheckQuota returns a QuotaExceededError if storing additionalBytes in a dataset would exceed the
+// quota of the dataset or of its organization, counting the storage reserved with ReserveStorage.
+// It does not reserve the storage.
+// If the quota tables were not created yet, there is no quota.
+func (q *Queries) CheckQuota(ctx context.Context, organizationId int64, datasetId int64, additionalBytes int64) error {
+	err := q.checkDatasetQuota(ctx, datasetId, additionalBytes)
+	if err != nil {
+		return mapError(err)
+	}
+	return q.checkOrganizationQuota(ctx, organizationId, additionalBytes)
+}
+
+// ReserveStorage reserves storage in a dataset in a single transaction. See Queries.ReserveStorage.
+func (store *SQLStore) ReserveStorage(ctx context.Context, organizationId int64, datasetId int64, size int64) error {
+	return store.execTx(ctx, func(qtx *Queries) error {
+		return qtx.ReserveStorage(ctx, organizationId, datasetId, size)
+	})
+}
+
+// ReserveStorage reserves storage in a dataset for files that are not added yet, such as the files of an
+// upload manifest, but only if neither quota would be exceeded. Otherwise, it returns a QuotaExceededError.
+//   - Reservations are kept in their own counters, dataset_storage_reservation and
+//     pennsieve.organization_storage_reservation, so the dataset and organization storage do not change.
+//     Quotas are checked against the storage plus the reserved storage.
+//   - This call must be wrapped in a Transaction, so the dataset reservation is rolled back if the
+//     organization quota is exceeded.
+//   - Each reservation checks the quota under the row lock of the reservation counter, so concurrent
+//     reservations cannot exceed the quota together.
+//   - The files are counted as usual when they are added. Then, or if the upload does not complete,
+//     ReleaseStorage releases the reservation.
+func (q *Queries) ReserveStorage(ctx context.Context, organizationId int64, datasetId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot reserve a negative size: %d", size))
+	}
+
+	_, err := q.db.ExecContext(ctx,
+		"INSERT INTO dataset_storage_reservation (dataset_id, size) VALUES ($1, 0) ON CONFLICT (dataset_id) DO NOTHING",
+		datasetId)
+	if err != nil {
+		log.Error("Error reserving dataset storage: ", err)
+		return schemaError(organizationSchemaDir, err)
+	}
+
+	queryStr := "UPDATE dataset_storage_reservation AS r SET size = r.size + $2::bigint " +
+		"WHERE r.dataset_id = $1 AND NOT EXISTS (SELECT 1 FROM dataset_storage_quota quota " +
+		"WHERE quota.dataset_id = $1 AND quota.max_size < r.size + $2::bigint + " +
+		"(SELECT COALESCE(SUM(size), 0) FROM dataset_storage WHERE dataset_id = $1)) " +
+		"RETURNING r.dataset_id"
+
+	var id int64
+	err = q.db.QueryRowContext(ctx, queryStr, datasetId, size).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		limit, used, err := q.datasetQuotaUsage(ctx, datasetId)
+		if err != nil {
+			return mapError(err)
+		}
+		return QuotaExceededError{Scope: DatasetStorageScope, Id: datasetId, Limit: limit, Used: used, Requested: size}
+	}
+	if err != nil {
+		log.Error("Error reserving dataset storage: ", err)
+		return schemaError(organizationSchemaDir, err)
+	}
+
+	_, err = q.db.ExecContext(ctx,
+		"INSERT INTO pennsieve.organization_storage_reservation (organization_id, size) VALUES ($1, 0) "+
+			"ON CONFLICT (organization_id) DO NOTHING",
+		organizationId)
+	if err != nil {
+		log.Error("Error reserving organization storage: ", err)
+		return schemaError(pennsieveSchemaDir, err)
+	}
+
+	queryStr = "UPDATE pennsieve.organization_storage_reservation AS r SET size = r.size + $2::bigint " +
+		"WHERE r.organization_id = $1 AND NOT EXISTS (SELECT 1 FROM pennsieve.organization_storage_quota quota " +
+		"WHERE quota.organization_id = $1 AND quota.max_size < r.size + $2::bigint + " +
+		"(SELECT COALESCE(SUM(size), 0) FROM pennsieve.organization_storage WHERE organization_id = $1)) " +
+		"RETURNING r.organization_id"
+
+	err = q.db.QueryRowContext(ctx, queryStr, organizationId, size).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		limit, used, err := q.organizationQuotaUsage(ctx, organizationId)
+		if err != nil {
+			return mapError(err)
+		}
+		return QuotaExceededError{Scope: OrganizationStorageScope, Id: organizationId, Limit: limit, Used: used, Requested: size}
+	}
+	if err != nil {
+		log.Error("Error reserving organization storage: ", err)
+		return schemaError(pennsieveSchemaDir, err)
+	}
+	return nil
+}
+
+// ReleaseStorage releases storage reserved with ReserveStorage, once the files are counted in the dataset
+// and organization storage, or if the upload does not complete. Reservations do not go below 0.
+func (q *Queries) ReleaseStorage(ctx context.Context, organizationId int64, datasetId int64, size int64) error {
+	if size < 0 {
+		return dbErrors.New(dbErrors.ErrInvalid, fmt.Sprintf("cannot release a negative size: %d", size))
+	}
+
+	_, err := q.db.ExecContext(ctx,
+		"UPDATE dataset_storage_reservation SET size = GREATEST(size - $2, 0) WHERE dataset_id = $1", datasetId, size)
+	if err != nil {
+		log.Error("Error releasing dataset storage: ", err)
+		return schemaError(organizationSchemaDir, err)
+	}
+
+	_, err = q.db.ExecContext(ctx,
+		"UPDATE pennsieve.organization_storage_reservation SET size = GREATEST(size - $2, 0) WHERE organization_id = $1",
+		organizationId, size)
+	if err != nil {
+		log.Error("Error releasing organization storage: ", err)
+		return schemaError(pennsieveSchemaDir, err)
+	}
+	return nil
+}
+
+// checkDatasetQuota returns a QuotaExceededError if the dataset quota does not allow additionalBytes.
+func (q *Queries) checkDatasetQuota(ctx context.Context, datasetId int64, additionalBytes int64) error {
+	limit, used, err := q.datasetQuotaUsage(ctx, datasetId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.As(err, &SchemaNotAppliedError{}) {
+			return nil
+		}
+		return mapError(err)
+	}
+	if used > limit-additionalBytes {
+		return QuotaExceededError{Scope: DatasetStorageScope, Id: datasetId, Limit: limit, Used: used, Requested: additionalBytes}
+	}
+	return nil
+}
+
+// checkOrganizationQuota returns a QuotaExceededError if the organization quota does not allow additionalBytes.
+func (q *Queries) checkOrganizationQuota(ctx context.Context, organizationId int64, additionalBytes int64) error {
+	limit, used, err := q.organizationQuotaUsage(ctx, organizationId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.As(err, &SchemaNotAppliedError{}) {
+			return nil
+		}
+		return mapError(err)
+	}
+	if used > limit-additionalBytes {
+		return QuotaExceededError{Scope: OrganizationStorageScope, Id: organizationId, Limit: limit, Used: used, Requested: additionalBytes}
+	}
+	return nil
+}
+
+// datasetQuotaUsage returns the quota of a dataset and its storage, including reserved storage.
+// Returns an error with errors.Is(err, dbErrors.ErrNotFound) if the dataset does not have a quota.
+func (q *Queries) datasetQuotaUsage(ctx context.Context, datasetId int64) (int64, int64, error) {
+	limit, err := q.GetDatasetStorageQuota(ctx, datasetId)
+	if err != nil {
+		return 0, 0, mapError(err)
+	}
+	var used int64
+	err = q.db.QueryRowContext(ctx,
+		"SELECT (SELECT COALESCE(SUM(size), 0) FROM dataset_storage WHERE dataset_id = $1) + "+
+			"(SELECT COALESCE(SUM(size), 0) FROM dataset_storage_reservation WHERE dataset_id = $1)",
+		datasetId).Scan(&used)
+	if err != nil {
+		return 0, 0, schemaError(organizationSchemaDir, err)
+	}
+	return limit, used, nil
+}
+
+// organizationQuotaUsage returns the quota of an organization and its storage, including reserved storage.
+// Returns an error with errors.Is(err, dbErrors.ErrNotFound) if the organization does not have a quota.
+func (q *Queries) organizationQuotaUsage(ctx context.Context, organizationId int64) (int64, int64, error) {
+	limit, err := q.GetOrganizationStorageQuota(ctx, organizationId)
+	if err != nil {
+		return 0, 0, mapError(err)
+	}
+	var used int64
+	err = q.db.QueryRowContext(ctx,
+		"SELECT (SELECT COALESCE(SUM(size), 0) FROM pennsieve.organization_storage WHERE organization_id = $1) + "+
+			"(SELECT COALESCE(SUM(size), 0) FROM pennsieve.organization_storage_reservation WHERE organization_id = $1)",
+		organizationId).Scan(&used)
+	if err != nil {
+		return 0, 0, schemaError(pennsieveSchemaDir, err)
+	}
+	return limit, used, nil
+}
@@ -0,0 +1,106 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestStorageQuota is the main Test Suite function for storage quotas.
+func TestStorageQuota(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Check quota without quotas":     testCheckQuotaUnlimited,
+		"Check dataset quota":            testCheckDatasetQuota,
+		"Reserve storage":                testReserveStorage,
+		"Reserve storage over org quota": testReserveStorageOverOrganizationQuota,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			defer test.Truncate(t, store.db, orgId, "dataset_storage")
+			defer test.Truncate(t, store.db, orgId, "organization_storage")
+			defer test.Truncate(t, store.db, orgId, "dataset_storage_quota")
+			defer test.Truncate(t, store.db, orgId, "organization_storage_quota")
+			defer test.Truncate(t, store.db, orgId, "dataset_storage_reservation")
+			defer test.Truncate(t, store.db, orgId, "organization_storage_reservation")
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testCheckQuotaUnlimited(t *testing.T, store *SQLStore, _ int) {
+	err := store.CheckQuota(context.Background(), 1, 1, 1<<40)
+	assert.NoError(t, err)
+}
+
+func testCheckDatasetQuota(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	assert.NoError(t, store.SetDatasetStorageQuota(ctx, 1, 100))
+	assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 60))
+
+	assert.NoError(t, store.CheckQuota(ctx, 1, 1, 40))
+
+	err := store.CheckQuota(ctx, 1, 1, 41)
+	var quotaErr QuotaExceededError
+	if assert.ErrorAs(t, err, &quotaErr) {
+		assert.Equal(t, DatasetStorageScope, quotaErr.Scope)
+		assert.Equal(t, int64(100), quotaErr.Limit)
+		assert.Equal(t, int64(60), quotaErr.Used)
+		assert.Equal(t, int64(41), quotaErr.Requested)
+	}
+
+	assert.NoError(t, store.DeleteDatasetStorageQuota(ctx, 1))
+	assert.NoError(t, store.CheckQuota(ctx, 1, 1, 41))
+}
+
+func testReserveStorage(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	assert.NoError(t, store.SetDatasetStorageQuota(ctx, 1, 100))
+
+	assert.NoError(t, store.ReserveStorage(ctx, 1, 1, 70))
+	assert.ErrorAs(t, store.ReserveStorage(ctx, 1, 1, 31), &QuotaExceededError{})
+	assert.NoError(t, store.ReserveStorage(ctx, 1, 1, 30))
+
+	// Reservations are not part of the storage, but count against the quota.
+	_, err := store.GetDatasetStorageById(ctx, 1)
+	assert.ErrorIs(t, err, dbErrors.ErrNotFound)
+	err = store.CheckQuota(ctx, 1, 1, 1)
+	var quotaErr QuotaExceededError
+	if assert.ErrorAs(t, err, &quotaErr) {
+		assert.Equal(t, int64(100), quotaErr.Used)
+	}
+
+	// The upload of the first 30 bytes fails, the other 70 bytes are added and counted as usual.
+	assert.NoError(t, store.ReleaseStorage(ctx, 1, 1, 30))
+	assert.NoError(t, store.IncrementDatasetStorage(ctx, 1, 70))
+	assert.NoError(t, store.IncrementOrganizationStorage(ctx, 1, 70))
+	assert.NoError(t, store.ReleaseStorage(ctx, 1, 1, 70))
+
+	size, err := store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(70), size)
+	size, err = store.GetOrganizationStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(70), size)
+
+	assert.NoError(t, store.CheckQuota(ctx, 1, 1, 30))
+	assert.ErrorAs(t, store.CheckQuota(ctx, 1, 1, 31), &QuotaExceededError{})
+}
+
+func testReserveStorageOverOrganizationQuota(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	assert.NoError(t, store.SetOrganizationStorageQuota(ctx, 1, 50))
+
+	err := store.ReserveStorage(ctx, 1, 1, 51)
+	var quotaErr QuotaExceededError
+	if assert.ErrorAs(t, err, &quotaErr) {
+		assert.Equal(t, OrganizationStorageScope, quotaErr.Scope)
+	}
+
+	// The dataset reservation is rolled back with the transaction
+	assert.Equal(t, 0, countRows(t, store.db, "dataset_storage_reservation"))
+}
@@ -0,0 +1,222 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/packageInfo/packageState"
+	log "github.com/sirupsen/logrus"
+)
+
+// StorageScope identifies the counter a StorageDiscrepancy refers to.
+type StorageScope string
+
+const (
+	PackageStorageScope      StorageScope = "package"
+	DatasetStorageScope      StorageScope = "dataset"
+	OrganizationStorageScope StorageScope = "organization"
+)
+
+// StorageDiscrepancy is a storage counter whose recorded size does not match the size computed from the files table.
+type StorageDiscrepancy struct {
+	Scope    StorageScope `json:"scope"`
+	Id       int64        `json:"id"`
+	Recorded int64        `json:"recorded"` // 0 if there is no storage row.
+	Computed int64        `json:"computed"`
+}
+
+// StorageReconciliationReport is the result of ReconcileStorage.
+type StorageReconciliationReport struct {
+	OrganizationId   int64                `json:"organization_id"`
+	DatasetId        int64                `json:"dataset_id"`
+	DatasetSize      int64                `json:"dataset_size"`
+	OrganizationSize int64                `json:"organization_size"`
+	Discrepancies    []StorageDiscrepancy `json:"discrepancies"`
+	Fixed            bool                 `json:"fixed"`
+}
+
+// ReconcileStorage recomputes the storage counters of a dataset in a single transaction. See Queries.ReconcileStorage.
+func (store *SQLStore) ReconcileStorage(ctx context.Context, organizationId int64, datasetId int64, fix bool) (*StorageReconciliationReport, error) {
+	var report *StorageReconciliationReport
+	err := store.execTx(ctx, func(qtx *Queries) error {
+		var err error
+		report, err = qtx.ReconcileStorage(ctx, organizationId, datasetId, fix)
+		return mapError(err)
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return report, nil
+}
+
+// reconcilePackage is a package in the dataset with its direct file size and recorded storage.
+type reconcilePackage struct {
+	id       int64
+	parentId sql.NullInt64
+	deleted  bool
+	fileSize int64
+	recorded int64
+	computed int64
+	childIds []int64
+}
+
+// ReconcileStorage recomputes the storage counters of a dataset from the sizes in the files table.
+//   - This call should be wrapped in a Transaction as it reads and updates all counters of the dataset.
+//   - The storage of every non-deleted package is the size of its files plus the storage of its
+//     non-deleted children. Deleted packages are excluded from the totals, and their own counters,
+//     which are kept for restores, are not checked.
+//   - The dataset storage is the storage of its non-deleted top-level packages.
+//   - The organization storage is the sum of the storage of all datasets in the organization schema.
+//
+// It returns a report listing every counter that does not match. If fix is set, the counters are
+// updated to the computed values.
+func (q *Queries) ReconcileStorage(ctx context.Context, organizationId int64, datasetId int64, fix bool) (*StorageReconciliationReport, error) {
+	rows, err := q.db.QueryContext(ctx, "SELECT p.id, p.parent_id, p.state IN ($2, $3), "+
+		"COALESCE(f.size, 0), storage.size "+
+		"FROM packages p "+
+		"LEFT JOIN (SELECT package_id, SUM(size) AS size FROM files GROUP BY package_id) f ON f.package_id = p.id "+
+		"LEFT JOIN package_storage storage ON storage.package_id = p.id "+
+		"WHERE p.dataset_id = $1",
+		datasetId, packageState.Deleting.String(), packageState.Deleted.String())
+	if err != nil {
+		log.Error("Error reading packages for storage reconciliation: ", err)
+		return nil, mapError(err)
+	}
+	defer rows.Close()
+
+	packages := map[int64]*reconcilePackage{}
+	var order []int64
+	for rows.Next() {
+		var p reconcilePackage
+		var recorded sql.NullInt64
+		if err := rows.Scan(&p.id, &p.parentId, &p.deleted, &p.fileSize, &recorded); err != nil {
+			return nil, mapError(err)
+		}
+		p.recorded = recorded.Int64
+		packages[p.id] = &p
+		order = append(order, p.id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+
+	var roots []int64
+	for _, id := range order {
+		p := packages[id]
+		if p.parentId.Valid {
+			if parent, ok := packages[p.parentId.Int64]; ok {
+				parent.childIds = append(parent.childIds, id)
+				continue
+			}
+		}
+		roots = append(roots, id)
+	}
+
+	report := StorageReconciliationReport{OrganizationId: organizationId, DatasetId: datasetId, Fixed: fix}
+
+	// Compute sizes bottom-up, children before parents.
+	var compute func(id int64) int64
+	compute = func(id int64) int64 {
+		p := packages[id]
+		p.computed = p.fileSize
+		for _, childId := range p.childIds {
+			childSize := compute(childId)
+			if !packages[childId].deleted {
+				p.computed += childSize
+			}
+		}
+		return p.computed
+	}
+	for _, id := range roots {
+		size := compute(id)
+		if !packages[id].deleted {
+			report.DatasetSize += size
+		}
+	}
+
+	var fixIds []int64
+	var fixSizes []int64
+	for _, id := range order {
+		p := packages[id]
+		if p.deleted || p.recorded == p.computed {
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, StorageDiscrepancy{
+			Scope:    PackageStorageScope,
+			Id:       id,
+			Recorded: p.recorded,
+			Computed: p.computed,
+		})
+		fixIds = append(fixIds, id)
+		fixSizes = append(fixSizes, p.computed)
+	}
+
+	recordedDatasetSize, err := q.GetDatasetStorageById(ctx, datasetId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, mapError(err)
+	}
+	if recordedDatasetSize != report.DatasetSize {
+		report.Discrepancies = append(report.Discrepancies, StorageDiscrepancy{
+			Scope:    DatasetStorageScope,
+			Id:       datasetId,
+			Recorded: recordedDatasetSize,
+			Computed: report.DatasetSize,
+		})
+	}
+
+	var otherDatasetsSize int64
+	err = q.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(size), 0) FROM dataset_storage WHERE dataset_id <> $1", datasetId).Scan(&otherDatasetsSize)
+	if err != nil {
+		log.Error("Error reading dataset storage for storage reconciliation: ", err)
+		return nil, mapError(err)
+	}
+	report.OrganizationSize = otherDatasetsSize + report.DatasetSize
+
+	recordedOrganizationSize, err := q.GetOrganizationStorageById(ctx, organizationId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, mapError(err)
+	}
+	if recordedOrganizationSize != report.OrganizationSize {
+		report.Discrepancies = append(report.Discrepancies, StorageDiscrepancy{
+			Scope:    OrganizationStorageScope,
+			Id:       organizationId,
+			Recorded: recordedOrganizationSize,
+			Computed: report.OrganizationSize,
+		})
+	}
+
+	if !fix {
+		return &report, nil
+	}
+
+	if len(fixIds) > 0 {
+		_, err = q.db.ExecContext(ctx, "INSERT INTO package_storage AS package_storage (package_id, size) "+
+			"SELECT * FROM unnest($1::bigint[], $2::bigint[]) "+
+			"ON CONFLICT (package_id) DO UPDATE SET size = EXCLUDED.size",
+			pq.Array(fixIds), pq.Array(fixSizes))
+		if err != nil {
+			log.Error("Error fixing package storage: ", err)
+			return nil, mapError(err)
+		}
+	}
+
+	_, err = q.db.ExecContext(ctx, "INSERT INTO dataset_storage AS dataset_storage (dataset_id, size) "+
+		"VALUES ($1, $2) ON CONFLICT (dataset_id) DO UPDATE SET size = EXCLUDED.size",
+		datasetId, report.DatasetSize)
+	if err != nil {
+		log.Error("Error fixing dataset storage: ", err)
+		return nil, mapError(err)
+	}
+
+	_, err = q.db.ExecContext(ctx, "INSERT INTO pennsieve.organization_storage AS organization_storage (organization_id, size) "+
+		"VALUES ($1, $2) ON CONFLICT (organization_id) DO UPDATE SET size = EXCLUDED.size",
+		organizationId, report.OrganizationSize)
+	if err != nil {
+		log.Error("Error fixing organization storage: ", err)
+		return nil, mapError(err)
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,105 @@
+package pgdb
+
+import (
+	"context"
+	"github.com/pennsieve/pennsieve-go-core/test"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestStorageReconciliation is the main Test Suite function for reconciling storage counters.
+func TestStorageReconciliation(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Report storage discrepancies": testReportStorageDiscrepancies,
+		"Fix storage discrepancies":    testFixStorageDiscrepancies,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			defer test.Truncate(t, store.db, orgId, "packages")
+			defer test.Truncate(t, store.db, orgId, "files")
+			defer test.Truncate(t, store.db, orgId, "package_storage")
+			defer test.Truncate(t, store.db, orgId, "dataset_storage")
+			defer test.Truncate(t, store.db, orgId, "organization_storage")
+			fn(t, store, orgId)
+		})
+	}
+}
+
+// addReconcileTestTree adds folder/{a.edf, sub/b.edf, deleted.edf} to dataset 1 with files of 1024 bytes
+// each and returns the folder id. Only the storage of a.edf is recorded.
+func addReconcileTestTree(t *testing.T, store *SQLStore) int64 {
+	ctx := context.Background()
+	folder := addTestFolder(t, store, "folder", -1)
+	sub := addTestFolder(t, store, "sub", folder.Id)
+	packages, err := store.AddPackages(ctx, test.GenerateTestPackages([]test.PackageParams{
+		{Name: "a.edf", ParentId: folder.Id},
+		{Name: "b.edf", ParentId: sub.Id},
+		{Name: "deleted.edf", ParentId: folder.Id},
+	}, 1))
+	if err != nil {
+		assert.FailNow(t, "unable to set up test; error inserting packages", err)
+	}
+	for _, p := range packages {
+		addTestFiles(t, store, int(p.Id), p.Name)
+	}
+	if err := store.IncrementPackageStorage(ctx, packages[0].Id, 1024); err != nil {
+		assert.FailNow(t, "unable to set up test; error incrementing storage", err)
+	}
+	if _, err := store.SoftDeletePackages(ctx, 1, 1, []int64{packages[2].Id}); err != nil {
+		assert.FailNow(t, "unable to set up test; error deleting package", err)
+	}
+	return folder.Id
+}
+
+func testReportStorageDiscrepancies(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	folderId := addReconcileTestTree(t, store)
+
+	report, err := store.ReconcileStorage(ctx, 1, 1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), report.DatasetSize)
+	assert.False(t, report.Fixed)
+
+	discrepancies := map[StorageScope]int{}
+	for _, d := range report.Discrepancies {
+		discrepancies[d.Scope]++
+		if d.Scope == PackageStorageScope && d.Id == folderId {
+			assert.Equal(t, int64(2048), d.Computed)
+		}
+	}
+	assert.Equal(t, 3, discrepancies[PackageStorageScope], "folder, sub and b.edf should be reported")
+	assert.Equal(t, 1, discrepancies[DatasetStorageScope])
+	assert.Equal(t, 1, discrepancies[OrganizationStorageScope])
+
+	size, err := store.GetPackageStorageById(ctx, folderId)
+	if assert.Error(t, err, "storage should not be fixed") {
+		assert.Equal(t, int64(0), size)
+	}
+}
+
+func testFixStorageDiscrepancies(t *testing.T, store *SQLStore, _ int) {
+	ctx := context.Background()
+	folderId := addReconcileTestTree(t, store)
+
+	_, err := store.ReconcileStorage(ctx, 1, 1, true)
+	assert.NoError(t, err)
+
+	size, err := store.GetPackageStorageById(ctx, folderId)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), size)
+
+	size, err = store.GetDatasetStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), size)
+
+	size, err = store.GetOrganizationStorageById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), size)
+
+	report, err := store.ReconcileStorage(ctx, 1, 1, false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Discrepancies)
+}
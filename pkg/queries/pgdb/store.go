@@ -3,7 +3,27 @@ package pgdb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultTxMaxRetries is a suggested TxOptions.MaxRetries for transactions that can safely run again.
+	DefaultTxMaxRetries = 3
+	// DefaultTxRetryBackoff is the wait before the first retry if TxOptions.RetryBackoff is not set.
+	// The wait doubles for every further retry, up to maxTxRetryBackoff.
+	DefaultTxRetryBackoff = 50 * time.Millisecond
+	maxTxRetryBackoff     = 2 * time.Second
+)
+
+// PostgreSQL error codes of transactions that can succeed if they are retried.
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
 )
 
 // SQLStore provides the Queries interface and a db instance.
@@ -21,6 +41,135 @@ func NewSQLStore(db *sql.DB) *SQLStore {
 	}
 }
 
+// TxOptions configures a transaction run by ExecTx.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction. The zero value uses the database default.
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// OrganizationId selects the organization schema for the transaction, see OrgQueries.
+	// If it is 0, the search_path of the connection is not changed.
+	OrganizationId int64
+	// MaxRetries is the number of retries after a serialization failure or deadlock.
+	// Retries are opt-in: 0 or a negative value disables them.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry. 0 uses DefaultTxRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// TxQueries are the Queries of a transaction run by ExecTx.
+type TxQueries struct {
+	*OrgQueries
+	savepoints *int
+}
+
+// ExecTx runs fn in a transaction configured by opts, and commits it if fn returns no error.
+//   - If opts.MaxRetries is set and the transaction fails with a serialization failure (40001) or a
+//     deadlock (40P01), it is rolled back and fn runs again in a new transaction, after a backoff with
+//     jitter. fn must then not have side effects outside the transaction, and must reset any results it
+//     captures.
+//   - fn must ONLY use the provided TxQueries, the Queries of the store are not part of the transaction.
+//   - Use TxQueries.Savepoint to run part of fn in a nested transaction.
+func (store *SQLStore) ExecTx(ctx context.Context, opts TxOptions, fn func(*TxQueries) error) error {
+	var schema string
+	if opts.OrganizationId != 0 {
+		var err error
+		schema, err = orgSchema(opts.OrganizationId)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+
+	maxRetries := opts.MaxRetries
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultTxRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := store.execTxAttempt(ctx, opts, schema, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= maxRetries {
+			return mapError(err)
+		}
+
+		wait := backoff << attempt
+		if wait <= 0 || wait > maxTxRetryBackoff {
+			wait = maxTxRetryBackoff
+		}
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		log.Warn(fmt.Sprintf("retrying transaction in %v after attempt %d failed: %v", wait, attempt+1, err))
+
+		select {
+		case <-ctx.Done():
+			// Both errors are wrapped, so the kind of the failed attempt and the context error still match.
+			return errors.Join(mapError(err), fmt.Errorf("retry cancelled: %w", ctx.Err()))
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (store *SQLStore) execTxAttempt(ctx context.Context, opts TxOptions, schema string, fn func(*TxQueries) error) error {
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return mapError(err)
+	}
+
+	q := &TxQueries{
+		OrgQueries: &OrgQueries{Queries: New(tx), organizationId: opts.OrganizationId},
+		savepoints: new(int),
+	}
+
+	if schema != "" {
+		// Like SET LOCAL, the search_path ends with the transaction.
+		_, err = tx.ExecContext(ctx, "SELECT set_config('search_path', $1, true)", schema)
+		if err != nil {
+			log.Error(fmt.Sprintf("Unable to set search_path to %d in transaction: %v", opts.OrganizationId, err))
+		}
+	}
+	if err == nil {
+		err = fn(q)
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return mapError(fmt.Errorf("tx err: %w, rb err: %v", err, rbErr))
+		}
+		return mapError(err)
+	}
+
+	return mapError(tx.Commit())
+}
+
+// isRetryableTxError returns true if err is a serialization failure or a deadlock.
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailureCode || pqErr.Code == deadlockDetectedCode
+}
+
+// Savepoint runs fn in a nested transaction. If fn returns an error, only the changes made by fn are
+// rolled back and the error is returned; the enclosing transaction can continue. Savepoints can be nested.
+func (q *TxQueries) Savepoint(ctx context.Context, fn func(*TxQueries) error) error {
+	*q.savepoints++
+	name := fmt.Sprintf("sp_%d", *q.savepoints)
+
+	if _, err := q.db.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return mapError(err)
+	}
+
+	err := fn(q)
+	if err != nil {
+		if _, rbErr := q.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return mapError(fmt.Errorf("savepoint err: %w, rb err: %v", err, rbErr))
+		}
+		return mapError(err)
+	}
+
+	_, err = q.db.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return mapError(err)
+}
+
+// execTx runs fn in a transaction with the default TxOptions, so failed transactions are not retried.
 func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
 
 	// NOTE: When you create a new transaction (as below), the s.pgdb is NOT part of the transaction.
@@ -32,19 +181,7 @@ func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) erro
 	//    You can use the exposed db property of the Queries' struct to create custom database interactions.
 	//	  See the "upload-service-v2/upload lambda" for an example
 
-	tx, err := store.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	q := New(tx)
-	err = fn(q)
-	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
-		}
-		return err
-	}
-
-	return tx.Commit()
+	return store.ExecTx(ctx, TxOptions{}, func(qtx *TxQueries) error {
+		return fn(qtx.Queries)
+	})
 }
@@ -1,13 +1,20 @@
 package pgdb
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"github.com/pennsieve/pennsieve-go-core/pkg/models/dbErrors"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/nodeId"
 	"github.com/pennsieve/pennsieve-go-core/pkg/models/pgdb"
+	"github.com/pennsieve/pennsieve-go-core/test"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 var testDB map[int]*sql.DB
@@ -80,6 +87,11 @@ func TestMain(m *testing.M) {
 	addDataUseAgreements(db3)
 	addContributors(db3)
 
+	// Create the tables of the schema directory, which are not part of the seed DB.
+	if err := NewSQLStore(db0).ApplySchema(context.Background()); err != nil {
+		logFatalError("unable to apply schema", err)
+	}
+
 	os.Exit(m.Run())
 }
 
@@ -389,3 +401,147 @@ func TestStore(t *testing.T) {
 		})
 	}
 }
+
+// TestExecTx is the main Test Suite function for transactions.
+func TestExecTx(t *testing.T) {
+	for scenario, fn := range map[string]func(
+		tt *testing.T, store *SQLStore, orgId int,
+	){
+		"Retry serialization failures":       testExecTxRetry,
+		"Do not retry other errors":          testExecTxNoRetry,
+		"Cancel retry during backoff":        testExecTxRetryCancelled,
+		"Read-only transaction":              testExecTxReadOnly,
+		"Organization schema in transaction": testExecTxOrganization,
+		"Nested savepoints":                  testExecTxSavepoints,
+	} {
+		t.Run(scenario, func(t *testing.T) {
+			orgId := 1
+			store := NewSQLStore(testDB[orgId])
+			fn(t, store, orgId)
+		})
+	}
+}
+
+func testExecTxRetry(t *testing.T, store *SQLStore, orgId int) {
+	ctx := context.Background()
+
+	for _, code := range []pq.ErrorCode{serializationFailureCode, deadlockDetectedCode} {
+		attempts := 0
+		err := store.ExecTx(ctx, TxOptions{Isolation: sql.LevelSerializable, MaxRetries: DefaultTxMaxRetries, RetryBackoff: time.Millisecond}, func(q *TxQueries) error {
+			attempts++
+			if attempts < 3 {
+				return &pq.Error{Code: code}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	}
+
+	attempts := 0
+	err := store.ExecTx(ctx, TxOptions{MaxRetries: 1, RetryBackoff: time.Millisecond}, func(q *TxQueries) error {
+		attempts++
+		return &pq.Error{Code: serializationFailureCode}
+	})
+	assert.True(t, isRetryableTxError(err))
+	assert.Equal(t, 2, attempts, "expected the first attempt and one retry")
+
+	attempts = 0
+	err = store.ExecTx(ctx, TxOptions{MaxRetries: -1}, func(q *TxQueries) error {
+		attempts++
+		return &pq.Error{Code: deadlockDetectedCode}
+	})
+	assert.True(t, isRetryableTxError(err))
+	assert.Equal(t, 1, attempts, "expected retries to be disabled")
+
+	attempts = 0
+	err = store.execTx(ctx, func(q *Queries) error {
+		attempts++
+		return &pq.Error{Code: serializationFailureCode}
+	})
+	assert.True(t, isRetryableTxError(err))
+	assert.Equal(t, 1, attempts, "expected retries to be opt-in")
+}
+
+func testExecTxRetryCancelled(t *testing.T, store *SQLStore, orgId int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := 0
+	err := store.ExecTx(ctx, TxOptions{MaxRetries: 1, RetryBackoff: time.Minute}, func(q *TxQueries) error {
+		attempts++
+		cancel()
+		return &pq.Error{Code: serializationFailureCode}
+	})
+	assert.Equal(t, 1, attempts, "expected the retry to be cancelled during the backoff")
+	assert.ErrorIs(t, err, dbErrors.ErrTransient)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func testExecTxNoRetry(t *testing.T, store *SQLStore, orgId int) {
+	failure := errors.New("failure")
+	attempts := 0
+	err := store.ExecTx(context.Background(), TxOptions{}, func(q *TxQueries) error {
+		attempts++
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, attempts)
+}
+
+func testExecTxReadOnly(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+
+	err := store.ExecTx(context.Background(), TxOptions{ReadOnly: true}, func(q *TxQueries) error {
+		return q.IncrementDatasetStorage(context.Background(), 1, 10)
+	})
+	assert.Error(t, err)
+}
+
+func testExecTxOrganization(t *testing.T, store *SQLStore, orgId int) {
+	err := store.ExecTx(context.Background(), TxOptions{OrganizationId: 2}, func(q *TxQueries) error {
+		assert.Equal(t, int64(2), q.OrganizationId())
+		var schema string
+		err := q.db.QueryRowContext(context.Background(), "SELECT current_schema()").Scan(&schema)
+		assert.Equal(t, "2", schema)
+		return err
+	})
+	assert.NoError(t, err)
+
+	err = store.ExecTx(context.Background(), TxOptions{OrganizationId: -1}, func(q *TxQueries) error {
+		return nil
+	})
+	assert.ErrorAs(t, err, new(InvalidOrganizationIdError))
+}
+
+func testExecTxSavepoints(t *testing.T, store *SQLStore, orgId int) {
+	defer test.Truncate(t, store.db, orgId, "dataset_storage")
+
+	ctx := context.Background()
+	datasetId := int64(1)
+	failure := errors.New("failure")
+
+	err := store.ExecTx(ctx, TxOptions{}, func(q *TxQueries) error {
+		if err := q.IncrementDatasetStorage(ctx, datasetId, 10); err != nil {
+			return err
+		}
+		return q.Savepoint(ctx, func(q *TxQueries) error {
+			if err := q.IncrementDatasetStorage(ctx, datasetId, 5); err != nil {
+				return err
+			}
+			err := q.Savepoint(ctx, func(q *TxQueries) error {
+				if err := q.IncrementDatasetStorage(ctx, datasetId, 100); err != nil {
+					return err
+				}
+				return failure
+			})
+			assert.ErrorIs(t, err, failure)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+
+	size, err := store.GetDatasetStorageById(ctx, datasetId)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), size, "only the failed savepoint should be rolled back")
+}
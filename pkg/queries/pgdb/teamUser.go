@@ -51,7 +51,7 @@ func (q *Queries) GetTeamMemberships(ctx context.Context, userId int64) ([]UserT
 	rows, err := q.db.QueryContext(ctx, query, userId)
 	if err != nil {
 		log.Error(fmt.Sprintf("error querying for user team memberships (error: %+v)", err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	// iterate over rows, scan to struct
@@ -80,7 +80,7 @@ func (q *Queries) GetTeamMemberships(ctx context.Context, userId int64) ([]UserT
 
 	if err != nil {
 		log.Error(fmt.Sprintf("unable to check user team membership (error: %+v)", err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	return userTeamMemberships, nil
@@ -90,7 +90,7 @@ func (q *Queries) GetTeamClaims(ctx context.Context, userId int64) ([]teamUser.C
 	userTeamMemberships, err := q.GetTeamMemberships(ctx, userId)
 	if err != nil {
 		log.Error(fmt.Sprintf("unable to get user team memberships (error: %+v)", err))
-		return nil, err
+		return nil, mapError(err)
 	}
 
 	var teamClaims []teamUser.Claim
@@ -7,7 +7,7 @@ import (
 
 // GetTokenByCognitoId returns a user from Postgress based on his/her cognito-id
 // This function also returns the preferred org and whether the user is a super-admin.
-// Returns (nil, sql.ErrNoRows) if no user with the given cognito id exists.
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no user with the given cognito id exists.
 func (q *Queries) GetTokenByCognitoId(ctx context.Context, id string) (*pgdb.Token, error) {
 
 	queryStr := "SELECT id, name, token, organization_id, user_id, cognito_id, last_used, created_at, updated_at " +
@@ -27,13 +27,13 @@ func (q *Queries) GetTokenByCognitoId(ctx context.Context, id string) (*pgdb.Tok
 		&token.UpdatedAt)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &token, nil
 }
 
 // GetUserByCognitoId returns a Pennsieve User based on the cognito id in the token pool.
-// Returns (nil, sql.ErrNoRows) if no user with the given token exists
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no user with the given token exists.
 func (q *Queries) GetUserByCognitoId(ctx context.Context, id string) (*pgdb.User, error) {
 
 	queryStr := "SELECT pennsieve.users.id, pennsieve.users.node_id, email, first_name, last_name, is_super_admin, pennsieve.tokens.organization_id as preferred_org_id " +
@@ -51,7 +51,7 @@ func (q *Queries) GetUserByCognitoId(ctx context.Context, id string) (*pgdb.User
 		&user.PreferredOrg)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
@@ -7,7 +7,7 @@ import (
 
 // GetByCognitoId returns a user from Postgress based on his/her cognito-id
 // This function also returns the preferred org and whether the user is a super-admin.
-// Returns (nil, sql.ErrNoRows) if no user with the given cognito id exists
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no user with the given cognito id exists.
 func (q *Queries) GetByCognitoId(ctx context.Context, id string) (*pgdb.User, error) {
 
 	queryStr := "SELECT id, node_id, email, first_name, last_name, is_super_admin, COALESCE(preferred_org_id, -1) as preferred_org_id " +
@@ -25,14 +25,14 @@ func (q *Queries) GetByCognitoId(ctx context.Context, id string) (*pgdb.User, er
 		&user.PreferredOrg)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
 
 // GetUserById returns a user from Postgres based on the user's int id
 // This function also returns the preferred org and whether the user is a super-admin.
-// Returns (nil, sql.ErrNoRows) if no user with the given id exists.
+// Returns (nil, err) with errors.Is(err, dbErrors.ErrNotFound) if no user with the given id exists.
 func (q *Queries) GetUserById(ctx context.Context, id int64) (*pgdb.User, error) {
 
 	queryStr := "SELECT id, node_id, email, first_name, last_name, is_super_admin, COALESCE(preferred_org_id, -1) as preferred_org_id " +
@@ -50,7 +50,7 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (*pgdb.User, error)
 		&user.PreferredOrg)
 
 	if err != nil {
-		return nil, err
+		return nil, mapError(err)
 	}
 	return &user, nil
 }
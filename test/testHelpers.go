@@ -246,12 +246,12 @@ func GenerateTestPackages(params []PackageParams, datasetId int) []pgdb.PackageP
 	return result
 }
 
-func Truncate(t *testing.T, db *sql.DB, orgID int, table string) {
+func Truncate(t testing.TB, db *sql.DB, orgID int, table string) {
 
 	var query string
 
 	switch table {
-	case "organization_storage":
+	case "organization_storage", "organization_storage_quota", "organization_storage_reservation":
 		query = fmt.Sprintf("TRUNCATE TABLE pennsieve.%s CASCADE", table)
 	default:
 		query = fmt.Sprintf("TRUNCATE TABLE \"%d\".%s CASCADE", orgID, table)